@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/monoidic/rir/rir"
+	"go4.org/netipx"
+)
+
+// abuseTTL is how long abuse contacts are cached.
+var abuseTTL = 7 * 24 * time.Hour
+
+type rdapEntity struct {
+	Roles      []string          `json:"roles"`
+	VcardArray []json.RawMessage `json:"vcardArray"`
+	Entities   []rdapEntity      `json:"entities"`
+}
+
+// email returns the first email address of the entity's jCard (RFC 7095).
+func (e rdapEntity) email() string {
+	if len(e.VcardArray) < 2 {
+		return ""
+	}
+	var properties [][]any
+	if err := json.Unmarshal(e.VcardArray[1], &properties); err != nil {
+		return ""
+	}
+	for _, property := range properties {
+		if len(property) >= 4 && property[0] == "email" {
+			if email, ok := property[3].(string); ok {
+				return email
+			}
+		}
+	}
+	return ""
+}
+
+// abuseEmail looks for the abuse mailbox among the entities of a network,
+// which registries nest at different depths.
+func abuseEmail(entities []rdapEntity) string {
+	for _, entity := range entities {
+		if slices.Contains(entity.Roles, "abuse") {
+			if email := entity.email(); email != "" {
+				return email
+			}
+		}
+		if email := abuseEmail(entity.Entities); email != "" {
+			return email
+		}
+	}
+	return ""
+}
+
+type abuseEntry struct {
+	Range   string    `json:"range"`
+	Email   string    `json:"email"`
+	Fetched time.Time `json:"fetched"`
+}
+
+// abuseCache holds the abuse contacts of the netblocks looked up so far, so
+// that addresses of the same netblock are only looked up once per TTL.
+type abuseCache struct {
+	path    string
+	entries []abuseEntry
+}
+
+func loadAbuseCache() *abuseCache {
+	c := &abuseCache{path: filepath.Join(rir.GetCacheDir(), "abuse.json")}
+	content, err := os.ReadFile(c.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c
+	}
+	check(err)
+	check(json.Unmarshal(content, &c.entries))
+	return c
+}
+
+func (c *abuseCache) lookup(addr netip.Addr) (string, bool) {
+	for _, entry := range c.entries {
+		r, err := netipx.ParseIPRange(entry.Range)
+		if err == nil && r.Contains(addr) && time.Since(entry.Fetched) < abuseTTL {
+			return entry.Email, true
+		}
+	}
+	return "", false
+}
+
+// add records the contact of a netblock, replacing expired entries.
+func (c *abuseCache) add(r netipx.IPRange, email string) {
+	c.entries = slices.DeleteFunc(c.entries, func(entry abuseEntry) bool {
+		return entry.Range == r.String() || time.Since(entry.Fetched) >= abuseTTL
+	})
+	c.entries = append(c.entries, abuseEntry{Range: r.String(), Email: email, Fetched: time.Now().UTC()})
+
+	tmp := c.path + ".tmp"
+	check(os.WriteFile(tmp, check1(json.MarshalIndent(c.entries, "", "  ")), 0o600))
+	check(os.Rename(tmp, c.path))
+}
+
+// abuseContact returns the abuse mailbox of the netblock of addr, from the
+// cache or from RDAP.
+func abuseContact(ctx context.Context, s *rdapSource, addr netip.Addr) (string, error) {
+	cache := loadAbuseCache()
+	if email, ok := cache.lookup(addr); ok {
+		return email, nil
+	}
+
+	var network struct {
+		StartAddress string       `json:"startAddress"`
+		EndAddress   string       `json:"endAddress"`
+		Entities     []rdapEntity `json:"entities"`
+	}
+	if err := s.get(ctx, addr, &network); err != nil {
+		return "", err
+	}
+	email := strings.ToLower(abuseEmail(network.Entities))
+
+	r, err := netipx.ParseIPRange(network.StartAddress + "-" + network.EndAddress)
+	if err != nil {
+		// cache the address alone when the netblock is not given
+		r = netipx.IPRangeFrom(addr, addr)
+	}
+	cache.add(r, email)
+	return email, nil
+}
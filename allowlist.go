@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"net/netip"
+	"os"
+
+	"github.com/monoidic/rir/rir"
+	"go4.org/netipx"
+)
+
+// privateRanges are merged into allowlists as practically every firewall
+// needs to let internal traffic through.
+var privateRanges = []netip.Prefix{
+	netip.MustParsePrefix("10.0.0.0/8"),     // RFC 1918
+	netip.MustParsePrefix("172.16.0.0/12"),  // RFC 1918
+	netip.MustParsePrefix("192.168.0.0/16"), // RFC 1918
+	netip.MustParsePrefix("fc00::/7"),       // RFC 4193 unique local addresses
+}
+
+// allowlistCommand exports the aggregated space of one or more countries
+// merged with private ranges and user supplied extra prefixes.
+func allowlistCommand(ctx context.Context, args []string) {
+	fset := flag.NewFlagSet("allowlist", flag.ExitOnError)
+	countries := fset.String("country", "", `comma separated 2 letters strings of the countries (ISO 3166), or of the countries to leave out of all the delegated space as "!CC"`)
+	private := fset.Bool("private", true, "include RFC 1918 and ULA ranges")
+	extra := fset.String("extra", "", "file of extra prefixes to include, one per line")
+	format := fset.String("format", "plain", "export format, one of "+exporterNames())
+	name := fset.String("name", "allowlist", "name of the generated set or list")
+	fset.StringVar(&diffAgainst, "diff-against", diffAgainst, `only print the changes relative to "previous", the last generated export, or to a file of prefixes`)
+	fset.StringVar(&nftablesTable, "table", nftablesTable, "nftables table holding the sets, for diffs")
+	check(fset.Parse(args))
+
+	if _, ok := exporters[*format]; *countries == "" || !ok {
+		usageError("usage: rir allowlist -country CC[,CC...] [-private=false] [-extra file] [-format %s] [-name name] [-diff-against previous|file]", exporterNames())
+	}
+
+	listed, negated := parseCountries(*countries)
+	if len(listed) > 0 && len(negated) > 0 {
+		usageError("-country cannot both list and negate countries")
+	}
+	var b netipx.IPSetBuilder
+	b.AddSet(countrySet(ctx, listed...))
+	if len(negated) > 0 {
+		b.AddSet(complementSet(ctx, rir.Filter{}, negated))
+	}
+	if *private {
+		for _, prefix := range privateRanges {
+			b.AddPrefix(prefix)
+		}
+	}
+	if *extra != "" {
+		b.AddSet(prefixListSet(readPrefixList(*extra)))
+	}
+	restrictFamily(&b)
+
+	writeExport(ctx, os.Stdout, *format, *name, subtractExcluded(check1(b.IPSet())).Prefixes())
+}
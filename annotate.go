@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"iter"
+	"net/netip"
+	"regexp"
+	"strings"
+)
+
+// addrLiteral matches candidate IPv4 and IPv6 addresses in free text; matches
+// that do not parse as addresses, such as times, are left alone.
+var (
+	addrLiteral  = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b|[0-9A-Fa-f]*:[0-9A-Fa-f:]*:(?:(?:\d{1,3}\.){3}\d{1,3}\b|[0-9A-Fa-f]*)`)
+	dottedNumber = regexp.MustCompile(`^\d\.$|^\.\d$`)
+)
+
+// lineAddrs yields the addresses found in line along with the offset of
+// their end.
+func lineAddrs(line string) iter.Seq2[netip.Addr, int] {
+	return func(yield func(netip.Addr, int) bool) {
+		for _, loc := range addrLiteral.FindAllStringIndex(line, -1) {
+			start, end := loc[0], loc[1]
+			// part of a longer dotted number, such as a version
+			if dottedNumber.MatchString(line[max(start-2, 0):start]) || dottedNumber.MatchString(line[end:min(end+2, len(line))]) {
+				continue
+			}
+			addr, err := netip.ParseAddr(line[start:end])
+			if err != nil {
+				continue
+			}
+			if !yield(addr.Unmap(), end) {
+				return
+			}
+		}
+	}
+}
+
+// annotateLine appends the country of every address of line to it, as
+// 1.2.3.4[AU]. Addresses out of any delegation are left as is.
+func annotateLine(lookup enrichLookup, line string) string {
+	var b strings.Builder
+	last := 0
+	for addr, end := range lineAddrs(line) {
+		record, ok := lookup(addr)
+		if !ok {
+			continue
+		}
+		b.WriteString(line[last:end])
+		b.WriteString("[" + record.Cc + "]")
+		last = end
+	}
+	b.WriteString(line[last:])
+	return b.String()
+}
+
+// annotateCommand copies text, such as web server logs, appending the
+// country of every address it contains. It is enrich -input-format lines.
+func annotateCommand(ctx context.Context, args []string) {
+	fset := flag.NewFlagSet("annotate", flag.ExitOnError)
+	check(fset.Parse(args))
+
+	if fset.NArg() > 1 {
+		usageError("usage: rir annotate [file] (stdin by default)")
+	}
+	path := "-"
+	if fset.NArg() == 1 {
+		path = fset.Arg(0)
+	}
+	runEnrich(ctx, lineCodec(annotateLine), path)
+}
@@ -0,0 +1,20 @@
+package main
+
+import "testing"
+
+func TestAnnotateLine(t *testing.T) {
+	lookup := holderLookup(t)
+	for _, test := range []struct{ line, want string }{
+		{
+			`2.3.4.5 - - [16/Oct/2026:08:57:58 +0000] "GET / HTTP/1.1" 200`,
+			`2.3.4.5[FR] - - [16/Oct/2026:08:57:58 +0000] "GET / HTTP/1.1" 200`,
+		},
+		{"from 193.18.1.1:443 and 192.0.2.1.", "from 193.18.1.1[DE]:443 and 192.0.2.1."},
+		{"mapped ::ffff:194.146.24.9 at 12:30:45", "mapped ::ffff:194.146.24.9[FR] at 12:30:45"},
+		{"version 2.3.4.5.6 6.2.3.4.5 999.1.1.1", "version 2.3.4.5.6 6.2.3.4.5 999.1.1.1"},
+	} {
+		if got := annotateLine(lookup, test.line); got != test.want {
+			t.Errorf("annotateLine(%q): got %q, want %q", test.line, got, test.want)
+		}
+	}
+}
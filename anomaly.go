@@ -0,0 +1,141 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/monoidic/rir/rir"
+)
+
+// AllocationAnomaly is a country whose delegated space in an address family
+// changed unusually between two serials of a registry file. IPv4 space is
+// counted in addresses and IPv6 space in /48s.
+type AllocationAnomaly struct {
+	Registry       string  `json:"registry"`
+	PreviousSerial string  `json:"previous_serial"`
+	Serial         string  `json:"serial"`
+	Country        string  `json:"country"`
+	Family         string  `json:"family"`
+	Before         int64   `json:"before"`
+	After          int64   `json:"after"`
+	Change         float64 `json:"change"`
+}
+
+func (a AllocationAnomaly) String() string {
+	return tsvLine(a.Registry, a.PreviousSerial, a.Serial, a.Country, a.Family, a.Before, a.After, fmt.Sprintf("%+.1f%%", a.Change*100))
+}
+
+// anomalyThresholds select the changes reported as anomalies: a relative
+// change of at least Change, of at least MinV4 addresses or MinV6 /48s.
+type anomalyThresholds struct {
+	Change       float64
+	MinV4, MinV6 int64
+}
+
+type countryFamily struct {
+	country, family string
+}
+
+// delegatedSpace sums the delegated space of every country and family.
+func delegatedSpace(records rir.Records) map[countryFamily]int64 {
+	space := make(map[countryFamily]int64)
+	for _, ip := range records.Ips {
+		if !isDelegated(ip.Record) || ip.Cc == "" {
+			continue
+		}
+		key := countryFamily{ip.Cc, ip.Type}
+		switch ip.Type {
+		case rir.IPv4:
+			space[key] += int64(ip.Value)
+		case rir.IPv6:
+			if ip.Value <= 48 {
+				space[key] += 1 << (48 - ip.Value)
+			}
+		}
+	}
+	return space
+}
+
+// allocationAnomalies compares the space of every country between two serials
+// of a registry file.
+func allocationAnomalies(previous, current rir.Records, t anomalyThresholds) []AllocationAnomaly {
+	before, after := delegatedSpace(previous), delegatedSpace(current)
+	keys := make(map[countryFamily]bool)
+	for key := range before {
+		keys[key] = true
+	}
+	for key := range after {
+		keys[key] = true
+	}
+
+	var anomalies []AllocationAnomaly
+	for key := range keys {
+		b, a := before[key], after[key]
+		delta := a - b
+		minimum := t.MinV4
+		if key.family == rir.IPv6 {
+			minimum = t.MinV6
+		}
+		if max(delta, -delta) < minimum {
+			continue
+		}
+		// space appearing from nothing is an infinite change
+		change := 1.0
+		if b > 0 {
+			change = float64(delta) / float64(b)
+		}
+		if max(change, -change) < t.Change {
+			continue
+		}
+		anomalies = append(anomalies, AllocationAnomaly{
+			Registry:       current.Registry,
+			PreviousSerial: previous.Serial,
+			Serial:         current.Serial,
+			Country:        key.country,
+			Family:         key.family,
+			Before:         b,
+			After:          a,
+			Change:         change,
+		})
+	}
+	slices.SortFunc(anomalies, func(a, b AllocationAnomaly) int {
+		return cmp.Or(strings.Compare(a.Country, b.Country), strings.Compare(a.Family, b.Family))
+	})
+	return anomalies
+}
+
+// anomaliesCommand flags the countries whose delegated space changed
+// unusually between the latest serial of each registry file and the one
+// retained before it.
+func anomaliesCommand(ctx context.Context, args []string) {
+	fset := flag.NewFlagSet("anomalies", flag.ExitOnError)
+	var t anomalyThresholds
+	fset.Float64Var(&t.Change, "change", 0.1, "minimum relative change of the space of a country, 0.1 being 10%")
+	fset.Int64Var(&t.MinV4, "min-v4", 1024, "minimum change in IPv4 addresses")
+	fset.Int64Var(&t.MinV6, "min-v6", 65536, "minimum change in IPv6 /48s")
+	registry := fset.String("registry", "", "only check this registry")
+	check(fset.Parse(args))
+
+	if fset.NArg() != 0 {
+		usageError("usage: rir anomalies [-change fraction] [-min-v4 addresses] [-min-v6 /48s] [-registry name]")
+	}
+
+	for _, p := range rir.AllProviders {
+		if *registry != "" && p.Name() != *registry {
+			continue
+		}
+		files := historyFiles(p)
+		if len(files) < 2 {
+			log.Printf("No previous %s file to compare with, see -keep", p.Name())
+			continue
+		}
+		for _, a := range allocationAnomalies(files[len(files)-2].records(), files[len(files)-1].records(), t) {
+			emit(a)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/monoidic/rir/rir"
+)
+
+func TestAllocationAnomalies(t *testing.T) {
+	ip := func(start string, value int, cc string) rir.IpRecord {
+		typ := rir.IPv4
+		if netip.MustParseAddr(start).Is6() {
+			typ = rir.IPv6
+		}
+		return rir.IpRecord{Record: rir.Record{Registry: "ripencc", Cc: cc, Type: typ, Value: value, Status: "allocated"}, Start: netip.MustParseAddr(start)}
+	}
+	previous := rir.Records{Registry: "ripencc", Serial: "1", Ips: []rir.IpRecord{
+		ip("10.0.0.0", 65536, "FR"),
+		ip("10.1.0.0", 65536, "DE"),
+		ip("10.2.0.0", 1024, "NL"),
+	}}
+	current := rir.Records{Registry: "ripencc", Serial: "2", Ips: []rir.IpRecord{
+		// FR grows by 1.5%, DE by 50%, NL by 25% but only 256 addresses
+		ip("10.0.0.0", 65536, "FR"),
+		ip("10.3.0.0", 1024, "FR"),
+		ip("10.1.0.0", 65536, "DE"),
+		ip("10.4.0.0", 32768, "DE"),
+		ip("10.2.0.0", 1024, "NL"),
+		ip("10.5.0.0", 256, "NL"),
+		ip("2001:db8::", 32, "BE"),
+	}}
+
+	anomalies := allocationAnomalies(previous, current, anomalyThresholds{Change: 0.1, MinV4: 1024, MinV6: 65536})
+	if len(anomalies) != 2 {
+		t.Fatalf("got %d anomalies %+v, want 2", len(anomalies), anomalies)
+	}
+	if a := anomalies[0]; a.Country != "BE" || a.Family != rir.IPv6 || a.After != 65536 || a.Change != 1 {
+		t.Errorf("BE: got %+v", a)
+	}
+	if a := anomalies[1]; a.Country != "DE" || a.Before != 65536 || a.After != 98304 || a.Change != 0.5 {
+		t.Errorf("DE: got %+v", a)
+	}
+}
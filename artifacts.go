@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	htmltemplate "html/template"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// An artifact is the latest export of a daemon job.
+type artifact struct {
+	job       ExportJob
+	content   []byte
+	signature []byte
+	etag      string
+	modTime   time.Time
+}
+
+// artifactServer serves the latest exports of the daemon jobs at stable
+// URLs, /exports/<name>, for devices to pull them.
+type artifactServer struct {
+	mu        sync.RWMutex
+	artifacts map[string]artifact
+	serials   string
+}
+
+func newArtifactServer() *artifactServer {
+	return &artifactServer{artifacts: map[string]artifact{}}
+}
+
+// publish replaces the served export of a job.
+func (s *artifactServer) publish(job ExportJob, content []byte, serials string) {
+	sum := sha256.Sum256(content)
+	a := artifact{
+		job:     job,
+		content: content,
+		etag:    `"` + hex.EncodeToString(sum[:16]) + `"`,
+		modTime: time.Now(),
+	}
+	if artifactKey != nil {
+		a.signature = artifactKey.signature(job.Name, content)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.artifacts[job.Name] = a
+	s.serials = serials
+}
+
+func (s *artifactServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", s.handleIndex)
+	mux.HandleFunc("GET /exports/{name}", s.handleExport)
+	return mux
+}
+
+var artifactIndex = htmltemplate.Must(htmltemplate.New("index").Funcs(htmltemplate.FuncMap{"join": strings.Join}).Parse(`<!DOCTYPE html>
+<html>
+<head><title>rir exports</title></head>
+<body>
+<h1>rir exports</h1>
+<p>Registry data: {{.Serials}}</p>
+<table>
+<tr><th>Name</th><th>Format</th><th>Countries</th><th>Updated</th><th>Size</th></tr>
+{{- range .Artifacts}}
+<tr><td><a href="/exports/{{.Name}}">{{.Name}}</a>{{if .Signed}} (<a href="/exports/{{.Name}}.minisig">signature</a>){{end}}</td><td>{{.Format}}</td><td>{{join .Countries ", "}}</td><td>{{.Updated.UTC.Format "2006-01-02 15:04:05"}}</td><td>{{.Size}}</td></tr>
+{{- end}}
+</table>
+</body>
+</html>
+`))
+
+// indexEntry is an export as listed on the index page.
+type indexEntry struct {
+	ExportJob
+	Signed  bool
+	Updated time.Time
+	Size    int
+}
+
+// handleIndex lists the served exports.
+func (s *artifactServer) handleIndex(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	entries := make([]indexEntry, 0, len(s.artifacts))
+	for _, a := range s.artifacts {
+		entries = append(entries, indexEntry{a.job, a.signature != nil, a.modTime, len(a.content)})
+	}
+	serials := s.serials
+	s.mu.RUnlock()
+	slices.SortFunc(entries, func(a, b indexEntry) int { return strings.Compare(a.Name, b.Name) })
+
+	var b bytes.Buffer
+	if err := artifactIndex.Execute(&b, map[string]any{"Serials": serials, "Artifacts": entries}); err != nil {
+		log.Printf("Writing index: %v", err)
+		http.Error(w, "cannot build index", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(b.Bytes())
+}
+
+// handleExport serves an export, or its signature with the .minisig
+// extension. Its ETag changes with its content, for clients to only fetch it
+// again when it changed.
+func (s *artifactServer) handleExport(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	base, signature := strings.CutSuffix(name, ".minisig")
+
+	s.mu.RLock()
+	a, ok := s.artifacts[base]
+	s.mu.RUnlock()
+	if !ok || (signature && a.signature == nil) {
+		http.NotFound(w, r)
+		return
+	}
+
+	content, etag := a.content, a.etag
+	if signature {
+		content, etag = a.signature, strings.TrimSuffix(etag, `"`)+`-minisig"`
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("ETag", etag)
+	http.ServeContent(w, r, "", a.modTime, bytes.NewReader(content))
+}
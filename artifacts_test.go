@@ -0,0 +1,61 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestArtifactServer(t *testing.T) {
+	s := newArtifactServer()
+	job := ExportJob{Name: "geo", Format: "nftables", Countries: []string{"FR", "DE"}}
+	s.publish(job, []byte("192.0.2.0/23\n"), "ripencc-20250101")
+	srv := httptest.NewServer(s.handler())
+	defer srv.Close()
+
+	get := func(path, etag string) (*http.Response, string) {
+		req, err := http.NewRequest("GET", srv.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp, string(body)
+	}
+
+	resp, body := get("/exports/geo", "")
+	etag := resp.Header.Get("ETag")
+	if resp.StatusCode != http.StatusOK || body != "192.0.2.0/23\n" || etag == "" {
+		t.Fatalf("export: got %d %q, ETag %q", resp.StatusCode, body, etag)
+	}
+	if resp, _ := get("/exports/geo", etag); resp.StatusCode != http.StatusNotModified {
+		t.Errorf("unchanged export: expected 304 got %d", resp.StatusCode)
+	}
+
+	s.publish(job, []byte("192.0.2.0/24\n"), "ripencc-20250102")
+	if resp, body := get("/exports/geo", etag); resp.StatusCode != http.StatusOK || body != "192.0.2.0/24\n" {
+		t.Errorf("changed export: got %d %q", resp.StatusCode, body)
+	}
+
+	for _, path := range []string{"/exports/other", "/exports/geo.minisig"} {
+		if resp, _ := get(path, ""); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("%s: expected 404 got %d", path, resp.StatusCode)
+		}
+	}
+
+	if resp, body := get("/", ""); resp.StatusCode != http.StatusOK || !strings.Contains(body, `<a href="/exports/geo">geo</a>`) || !strings.Contains(body, "FR, DE") || !strings.Contains(body, "ripencc-20250102") {
+		t.Errorf("index: got %d %q", resp.StatusCode, body)
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"iter"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+
+	"github.com/monoidic/rir/rir"
+	"go4.org/netipx"
+)
+
+// bigqueryRow is a row of the BigQuery table of delegations: a prefix of an
+// ip record or an AS number record.
+type bigqueryRow struct {
+	Registry string `json:"registry"`
+	Serial   string `json:"serial"`
+	Country  string `json:"country"`
+	Type     string `json:"type"`
+	Prefix   string `json:"prefix,omitempty"`
+	Start    string `json:"start"`
+	End      string `json:"end"`
+	Date     string `json:"date,omitempty"`
+	Status   string `json:"status"`
+	OpaqueId string `json:"opaque_id,omitempty"`
+}
+
+type bigqueryField struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Mode        string `json:"mode"`
+	Description string `json:"description"`
+}
+
+// bigquerySchema is the schema of the rows of bigqueryRow.
+var bigquerySchema = []bigqueryField{
+	{"registry", "STRING", "REQUIRED", "registry the delegation comes from"},
+	{"serial", "STRING", "REQUIRED", "serial of the registry file"},
+	{"country", "STRING", "REQUIRED", "ISO 3166 country code, ZZ when unknown"},
+	{"type", "STRING", "REQUIRED", "ipv4, ipv6 or asn"},
+	{"prefix", "STRING", "NULLABLE", "CIDR prefix, for ipv4 and ipv6"},
+	{"start", "STRING", "REQUIRED", "first address or AS number"},
+	{"end", "STRING", "REQUIRED", "last address or AS number"},
+	{"date", "DATE", "NULLABLE", "date of the delegation"},
+	{"status", "STRING", "REQUIRED", "allocated, assigned, available or reserved"},
+	{"opaque_id", "STRING", "NULLABLE", "opaque ID of the holder"},
+}
+
+// bigqueryRows yields the rows of the records of a registry.
+func bigqueryRows(records rir.Records) iter.Seq[bigqueryRow] {
+	return func(yield func(bigqueryRow) bool) {
+		row := func(r rir.Record) bigqueryRow {
+			row := bigqueryRow{
+				Registry: r.Registry,
+				Serial:   records.Serial,
+				Country:  cmp.Or(r.Cc, unknownCountry),
+				Type:     r.Type,
+				Status:   r.Status,
+				OpaqueId: r.OpaqueId,
+			}
+			if !r.Time.IsZero() {
+				row.Date = r.Time.Format("2006-01-02")
+			}
+			return row
+		}
+		for _, record := range records.Asns {
+			r := row(record.Record)
+			r.Start, r.End = fmt.Sprint(record.Start), fmt.Sprint(record.Start+max(record.Value, 1)-1)
+			if !yield(r) {
+				return
+			}
+		}
+		for _, record := range records.Ips {
+			for prefix, err := range record.Prefixes() {
+				if err != nil {
+					report("warning", codeInvalidRecord, &ProviderError{Provider: records.Registry, Err: err})
+					break
+				}
+				r := row(record.Record)
+				rng := netipx.RangeOfPrefix(prefix)
+				r.Prefix, r.Start, r.End = prefix.String(), rng.From().String(), rng.To().String()
+				if !yield(r) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// bigqueryAPI is the endpoint of the BigQuery API, replaced in tests.
+var bigqueryAPI = "https://bigquery.googleapis.com"
+
+// bigqueryLoad starts a load job of rows, as newline delimited JSON, into a
+// table, replacing its content, and returns the ID of the job.
+func bigqueryLoad(ctx context.Context, token, project, dataset, table string, rows []byte) (string, error) {
+	job := map[string]any{
+		"configuration": map[string]any{
+			"load": map[string]any{
+				"destinationTable":  map[string]string{"projectId": project, "datasetId": dataset, "tableId": table},
+				"sourceFormat":      "NEWLINE_DELIMITED_JSON",
+				"schema":            map[string]any{"fields": bigquerySchema},
+				"writeDisposition":  "WRITE_TRUNCATE",
+				"createDisposition": "CREATE_IF_NEEDED",
+			},
+		},
+	}
+
+	// the job configuration and the data are uploaded together as a
+	// multipart/related body
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	part, err := w.CreatePart(textproto.MIMEHeader{"Content-Type": {"application/json; charset=UTF-8"}})
+	if err != nil {
+		return "", err
+	}
+	if err := json.NewEncoder(part).Encode(job); err != nil {
+		return "", err
+	}
+	if part, err = w.CreatePart(textproto.MIMEHeader{"Content-Type": {"application/octet-stream"}}); err != nil {
+		return "", err
+	}
+	if _, err := part.Write(rows); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+
+	endpoint := fmt.Sprintf("%s/upload/bigquery/v2/projects/%s/jobs?uploadType=multipart", bigqueryAPI, url.PathEscape(project))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, &body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "multipart/related; boundary="+w.Boundary())
+	resp, err := rir.HTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	content, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("BigQuery load job: %s: %s", resp.Status, bytes.TrimSpace(content))
+	}
+	var created struct {
+		JobReference struct {
+			JobId string `json:"jobId"`
+		} `json:"jobReference"`
+	}
+	if err := json.Unmarshal(content, &created); err != nil {
+		return "", fmt.Errorf("BigQuery load job: %w", err)
+	}
+	return created.JobReference.JobId, nil
+}
+
+// bigqueryCommand exports every delegation as newline delimited JSON rows
+// for BigQuery along with the table schema, and optionally loads them into a
+// table.
+func bigqueryCommand(ctx context.Context, args []string) {
+	fset := flag.NewFlagSet("bigquery", flag.ExitOnError)
+	output := fset.String("o", "", "file of the rows, instead of the standard output")
+	schema := fset.String("schema", "", "file to write the table schema to, as for bq load --schema")
+	project := fset.String("project", "", "Google Cloud project of the table to load the rows into")
+	dataset := fset.String("dataset", "", "dataset of the table")
+	table := fset.String("table", "rir_delegations", "table the rows replace the content of")
+	token := fset.String("token", os.Getenv("BIGQUERY_ACCESS_TOKEN"), "OAuth access token of the load job, e.g. from gcloud auth print-access-token (default $BIGQUERY_ACCESS_TOKEN)")
+	check(fset.Parse(args))
+
+	if fset.NArg() > 0 || (*project != "" && (*dataset == "" || *token == "")) {
+		usageError("usage: rir bigquery [-o rows.json] [-schema schema.json] [-project id -dataset id [-table name] [-token token]]")
+	}
+
+	if *schema != "" {
+		content := check1(json.MarshalIndent(bigquerySchema, "", "  "))
+		check(os.WriteFile(*schema, append(content, '\n'), 0o644))
+	}
+
+	var rows bytes.Buffer
+	e := json.NewEncoder(&rows)
+	var n int
+	for records := range retrieveData(ctx) {
+		for row := range bigqueryRows(records) {
+			check(e.Encode(row))
+			n++
+		}
+	}
+
+	if *output == "" {
+		check1(os.Stdout.Write(rows.Bytes()))
+	} else {
+		check(os.WriteFile(*output, rows.Bytes(), 0o644))
+		check(signArtifact(*output, rows.Bytes()))
+	}
+	exportWritten("bigquery", *table, *output, n)
+
+	if *project != "" {
+		id, err := bigqueryLoad(ctx, *token, *project, *dataset, *table, rows.Bytes())
+		check(err)
+		log.Printf("Started BigQuery load job %s of %d rows into %s.%s.%s", id, n, *project, *dataset, *table)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/monoidic/rir/rir"
+)
+
+func TestBigqueryRows(t *testing.T) {
+	records, err := rir.NewReader(strings.NewReader(holderData)).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for row := range bigqueryRows(records) {
+		got = append(got, string(check1(json.Marshal(row))))
+	}
+	want := []string{
+		`{"registry":"ripencc","serial":"20240102","country":"FR","type":"asn","start":"3215","end":"3216","date":"1994-01-01","status":"allocated","opaque_id":"b8f0a8c3"}`,
+		`{"registry":"ripencc","serial":"20240102","country":"FR","type":"ipv4","prefix":"2.0.0.0/12","start":"2.0.0.0","end":"2.15.255.255","date":"2010-07-12","status":"allocated","opaque_id":"b8f0a8c3"}`,
+	}
+	if len(got) != 4 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("bigqueryRows: got %q, want 4 rows starting with %q", got, want)
+	}
+}
+
+func TestBigqueryLoad(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/upload/bigquery/v2/projects/proj/jobs" || r.URL.Query().Get("uploadType") != "multipart" || r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unexpected request "+r.URL.String(), http.StatusBadRequest)
+			return
+		}
+		_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		parts := multipart.NewReader(r.Body, params["boundary"])
+		var job struct {
+			Configuration struct {
+				Load struct {
+					DestinationTable struct{ TableId string }
+					SourceFormat     string
+				}
+			}
+		}
+		part, err := parts.NextPart()
+		if err == nil {
+			err = json.NewDecoder(part).Decode(&job)
+		}
+		if err == nil {
+			part, err = parts.NextPart()
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		data, _ := io.ReadAll(part)
+		load := job.Configuration.Load
+		if load.DestinationTable.TableId != "delegations" || load.SourceFormat != "NEWLINE_DELIMITED_JSON" || string(data) != "{}\n" {
+			http.Error(w, "unexpected job", http.StatusBadRequest)
+			return
+		}
+		io.WriteString(w, `{"jobReference":{"projectId":"proj","jobId":"job_42"}}`)
+	}))
+	defer server.Close()
+	defer func(api string) { bigqueryAPI = api }(bigqueryAPI)
+	bigqueryAPI = server.URL
+
+	id, err := bigqueryLoad(context.Background(), "secret", "proj", "rir", "delegations", []byte("{}\n"))
+	if err != nil || id != "job_42" {
+		t.Errorf("bigqueryLoad: got %q, %v", id, err)
+	}
+	if _, err := bigqueryLoad(context.Background(), "wrong", "proj", "rir", "delegations", []byte("{}\n")); err == nil {
+		t.Error("bigqueryLoad: unexpected success of a rejected job")
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"os"
+	"strings"
+
+	"github.com/monoidic/rir/rir"
+	"go4.org/netipx"
+)
+
+// bloomCommand exports the space of countries as a rir.PrefixFilter, for
+// "probably in the country" checks too frequent for a prefix table.
+func bloomCommand(ctx context.Context, args []string) {
+	fset := flag.NewFlagSet("bloom", flag.ExitOnError)
+	countries := fset.String("country", "", "comma separated 2 letters strings of the countries (ISO 3166)")
+	rate := fset.Float64("fp-rate", 0.001, "false positive rate of the lookups of addresses out of the countries")
+	output := fset.String("o", "", "file to write the filter to, instead of the standard output")
+	check(fset.Parse(args))
+
+	if *countries == "" || *rate <= 0 || *rate >= 1 {
+		usageError("usage: rir bloom -country CC[,CC...] [-fp-rate 0.001] [-o file]")
+	}
+
+	var b netipx.IPSetBuilder
+	b.AddSet(countrySet(ctx, countryList(*countries)...))
+	restrictFamily(&b)
+	prefixes := subtractExcluded(check1(b.IPSet())).Prefixes()
+
+	var buf bytes.Buffer
+	check1(check1(rir.NewPrefixFilter(prefixes, *rate)).WriteTo(&buf))
+	name := strings.ToLower(strings.ReplaceAll(*countries, ",", "_"))
+	if *output == "" {
+		check1(os.Stdout.Write(buf.Bytes()))
+	} else {
+		tmp := *output + ".tmp"
+		check(os.WriteFile(tmp, buf.Bytes(), 0o644))
+		check(os.Rename(tmp, *output))
+		check(signArtifact(*output, buf.Bytes()))
+	}
+	exportWritten("bloom", name, *output, len(prefixes))
+	runExportHook(ctx, "bloom", name, false, len(prefixes), buf.Bytes())
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/monoidic/rir/rir"
+)
+
+// bootstrapURL is the /snapshot.bin of a rir serve instance that the data is
+// loaded from instead of the registry files, set by -bootstrap.
+var bootstrapURL string
+
+func bootstrapPaths() (index, etag string) {
+	return filepath.Join(rir.GetCacheDir(), "bootstrap.idx"), filepath.Join(rir.GetCacheDir(), "bootstrap.etag")
+}
+
+// fetchBootstrap loads the dataset index served at url. The last download is
+// kept in the cache and only fetched again when the server ETag changed.
+func fetchBootstrap(ctx context.Context, url string) ([]rir.Records, error) {
+	indexPath, etagPath := bootstrapPaths()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	// the etag file holds the URL and the ETag of the cached index
+	if content, err := os.ReadFile(etagPath); err == nil {
+		if cachedURL, etag, ok := strings.Cut(string(content), "\n"); ok && cachedURL == url {
+			req.Header.Set("If-None-Match", etag)
+		}
+	}
+
+	resp, err := rir.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var content []byte
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		if content, err = os.ReadFile(indexPath); err != nil {
+			return nil, err
+		}
+	case http.StatusOK:
+		if content, err = io.ReadAll(resp.Body); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("fetching %s: HTTP call returned %d", url, resp.StatusCode)
+	}
+
+	dataset, err := rir.ReadDatasetIndex(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", url, err)
+	}
+	if resp.StatusCode == http.StatusOK {
+		// the cached copy only saves a download on the next run
+		if err := os.MkdirAll(rir.GetCacheDir(), 0o700); err == nil && os.WriteFile(indexPath, content, 0o600) == nil {
+			os.WriteFile(etagPath, []byte(url+"\n"+resp.Header.Get("ETag")), 0o600)
+		}
+	}
+
+	records := dataset.Sources()
+	for i := range records {
+		records[i].Source = url
+	}
+	return records, nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/monoidic/rir/rir"
+)
+
+const bundleManifestName = "MANIFEST.json"
+
+// BundleManifest is stored as the first entry of a cache bundle and lists
+// every other entry with its checksum.
+type BundleManifest struct {
+	Created time.Time    `json:"created"`
+	Files   []BundleFile `json:"files"`
+}
+
+type BundleFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	Sha256 string `json:"sha256"`
+}
+
+func sha256File(path string) string {
+	f := check1(os.Open(path))
+	defer f.Close()
+
+	h := sha256.New()
+	check1(io.Copy(h, f))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ExportCache writes the whole cache directory to a zstd compressed tarball
+// that can be imported on another, possibly offline, machine.
+func ExportCache(bundlePath string) {
+	cacheDir := rir.GetCacheDir()
+	manifest := BundleManifest{Created: time.Now().UTC()}
+
+	check(filepath.WalkDir(cacheDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info := check1(d.Info())
+		manifest.Files = append(manifest.Files, BundleFile{
+			Path:   filepath.ToSlash(check1(filepath.Rel(cacheDir, path))),
+			Size:   info.Size(),
+			Sha256: sha256File(path),
+		})
+		return nil
+	}))
+
+	out := check1(os.Create(bundlePath))
+	defer out.Close()
+	zw := check1(zstd.NewWriter(out))
+	tw := tar.NewWriter(zw)
+
+	manifestContent := check1(json.MarshalIndent(manifest, "", "  "))
+	check(tw.WriteHeader(&tar.Header{
+		Name:    bundleManifestName,
+		Mode:    0o600,
+		Size:    int64(len(manifestContent)),
+		ModTime: manifest.Created,
+	}))
+	check1(tw.Write(manifestContent))
+
+	for _, file := range manifest.Files {
+		path := filepath.Join(cacheDir, filepath.FromSlash(file.Path))
+		info := check1(os.Stat(path))
+		check(tw.WriteHeader(&tar.Header{
+			Name:    file.Path,
+			Mode:    0o600,
+			Size:    file.Size,
+			ModTime: info.ModTime(),
+		}))
+		f := check1(os.Open(path))
+		check1(io.Copy(tw, f))
+		f.Close()
+	}
+
+	check(tw.Close())
+	check(zw.Close())
+	log.Printf("Exported %d files to %s", len(manifest.Files), bundlePath)
+}
+
+// ImportCache unpacks a bundle created by ExportCache into the cache
+// directory. Nothing is written to the cache unless every file matches the
+// manifest.
+func ImportCache(bundlePath string) {
+	in := check1(os.Open(bundlePath))
+	defer in.Close()
+	zr := check1(zstd.NewReader(in))
+	defer zr.Close()
+	tr := tar.NewReader(zr)
+
+	header := check1(tr.Next())
+	if header.Name != bundleManifestName {
+		fatalf("%s is not a cache bundle: first entry is %q", bundlePath, header.Name)
+	}
+	var manifest BundleManifest
+	check(json.NewDecoder(tr).Decode(&manifest))
+
+	expected := make(map[string]BundleFile, len(manifest.Files))
+	for _, file := range manifest.Files {
+		if !filepath.IsLocal(filepath.FromSlash(file.Path)) {
+			fatalf("Refusing to import %q from outside the cache directory", file.Path)
+		}
+		expected[file.Path] = file
+	}
+
+	staging := check1(os.MkdirTemp(rir.GetCacheDir(), ".import-"))
+	defer os.RemoveAll(staging)
+
+	seen := make(map[string]bool, len(expected))
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		check(err)
+
+		file, ok := expected[header.Name]
+		if !ok {
+			fatalf("Bundle entry %q is not listed in the manifest", header.Name)
+		}
+
+		path := filepath.Join(staging, filepath.FromSlash(file.Path))
+		check(os.MkdirAll(filepath.Dir(path), 0o700))
+		f := check1(os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o700))
+		h := sha256.New()
+		n := check1(io.Copy(io.MultiWriter(f, h), tr))
+		f.Close()
+		check(os.Chtimes(path, header.ModTime, header.ModTime))
+
+		if sum := hex.EncodeToString(h.Sum(nil)); n != file.Size || sum != file.Sha256 {
+			fatalf("Checksum mismatch for %s: expected %d bytes %s got %d bytes %s", file.Path, file.Size, file.Sha256, n, sum)
+		}
+		seen[file.Path] = true
+	}
+
+	if len(seen) != len(expected) {
+		missing := 0
+		for path := range expected {
+			if !seen[path] {
+				log.Printf("Missing %s from bundle", path)
+				missing++
+			}
+		}
+		fatalf("Bundle is incomplete: %d files missing", missing)
+	}
+
+	for path := range seen {
+		dst := filepath.Join(rir.GetCacheDir(), filepath.FromSlash(path))
+		check(os.MkdirAll(filepath.Dir(dst), 0o700))
+		check(os.Rename(filepath.Join(staging, filepath.FromSlash(path)), dst))
+	}
+	fmt.Printf("Imported %d files created %s\n", len(seen), manifest.Created.Format(time.RFC3339))
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"github.com/monoidic/rir/rir"
+)
+
+const cacheUsage = `usage:
+	rir cache prune [-max-size bytes] [-max-age duration]
+	rir cache export bundle.tar.zst
+	rir cache import bundle.tar.zst`
+
+func cacheCommand(ctx context.Context, args []string) {
+	if len(args) == 0 {
+		usageError(cacheUsage)
+	}
+
+	switch args[0] {
+	case "prune":
+		pruneCommand(args[1:])
+	case "export", "import":
+		if len(args) != 2 {
+			usageError(cacheUsage)
+		}
+		requireDirStorage("cache " + args[0])
+		if args[0] == "export" {
+			ExportCache(args[1])
+		} else {
+			ImportCache(args[1])
+		}
+	default:
+		usageError(cacheUsage)
+	}
+}
+
+func pruneCommand(args []string) {
+	fset := flag.NewFlagSet("cache prune", flag.ExitOnError)
+	policy := rir.AutoPrune
+	fset.Int64Var(&policy.MaxSize, "max-size", policy.MaxSize, "maximum total size in bytes of the registry files, snapshots and indexes")
+	fset.DurationVar(&policy.MaxAge, "max-age", policy.MaxAge, "maximum age of snapshots and indexes kept in the cache directory")
+	check(fset.Parse(args))
+
+	for _, path := range check1(rir.PruneCache(rir.Store, policy)) {
+		log.Printf("Pruned %s from cache", path)
+	}
+}
+
+// requireDirStorage stops commands walking the cache directory when the
+// registry files are stored elsewhere.
+func requireDirStorage(command string) {
+	if _, ok := rir.StoreDir(); !ok {
+		usageError("rir %s needs a directory storage, see -storage", command)
+	}
+}
+
+// setStorage selects the storage of the registry files, see rir.ParseStorage.
+func setStorage(spec string) error {
+	storage, err := rir.ParseStorage(spec)
+	if err == nil {
+		rir.Store = storage
+	}
+	return err
+}
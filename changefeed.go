@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+
+	"github.com/monoidic/rir/rir"
+)
+
+// RecordDocument is a registry record in the change feeds. The feeds describe
+// a document holding every record of a registry file under its recordKey.
+type RecordDocument struct {
+	Registry string `json:"registry"`
+	Country  string `json:"country,omitempty"`
+	Type     string `json:"type"`
+	Start    string `json:"start"`
+	Value    int    `json:"value"`
+	Date     string `json:"date,omitempty"`
+	Status   string `json:"status"`
+	OpaqueId string `json:"opaque_id,omitempty"`
+}
+
+func newRecordDocument(r rir.Record, start string) RecordDocument {
+	return RecordDocument{
+		Registry: r.Registry,
+		Country:  r.Cc,
+		Type:     r.Type,
+		Start:    start,
+		Value:    r.Value,
+		Date:     r.Date,
+		Status:   r.Status,
+		OpaqueId: r.OpaqueId,
+	}
+}
+
+// recordKey identifies a record across serials by its resource, e.g.
+// "ipv4|2.0.0.0|1048576". It needs no escaping in a JSON pointer.
+func (d RecordDocument) recordKey() string {
+	return d.Type + "|" + d.Start + "|" + strconv.Itoa(d.Value)
+}
+
+// recordDocuments lists the IP and ASN records of a file in order.
+func recordDocuments(records rir.Records) []RecordDocument {
+	var documents []RecordDocument
+	for _, ip := range records.Ips {
+		documents = append(documents, newRecordDocument(ip.Record, ip.Start.String()))
+	}
+	for _, asn := range records.Asns {
+		documents = append(documents, newRecordDocument(asn.Record, strconv.Itoa(asn.Start)))
+	}
+	return documents
+}
+
+// RecordChange is a record added, removed or replaced between two serials.
+type RecordChange struct {
+	Op       string          `json:"op"`
+	Record   RecordDocument  `json:"record"`
+	Previous *RecordDocument `json:"previous,omitempty"`
+}
+
+// recordChanges compares the records of two serials of a registry file:
+// removals and replacements in the order of the previous file, then additions
+// in the order of the current one.
+func recordChanges(previous, current rir.Records) []RecordChange {
+	after := make(map[string]RecordDocument)
+	for _, d := range recordDocuments(current) {
+		after[d.recordKey()] = d
+	}
+
+	var changes []RecordChange
+	before := make(map[string]bool)
+	for _, d := range recordDocuments(previous) {
+		key := d.recordKey()
+		before[key] = true
+		switch updated, ok := after[key]; {
+		case !ok:
+			changes = append(changes, RecordChange{Op: "remove", Record: d})
+		case updated != d:
+			changes = append(changes, RecordChange{Op: "replace", Record: updated, Previous: &d})
+		}
+	}
+	for _, d := range recordDocuments(current) {
+		if !before[d.recordKey()] {
+			changes = append(changes, RecordChange{Op: "add", Record: d})
+		}
+	}
+	return changes
+}
+
+// JSONPatchOperation is an RFC 6902 operation on the record document.
+type JSONPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value *RecordDocument `json:"value,omitempty"`
+}
+
+func jsonPatch(changes []RecordChange) []JSONPatchOperation {
+	patch := []JSONPatchOperation{}
+	for _, c := range changes {
+		op := JSONPatchOperation{Op: c.Op, Path: "/" + c.Record.recordKey()}
+		if c.Op != "remove" {
+			op.Value = &c.Record
+		}
+		patch = append(patch, op)
+	}
+	return patch
+}
+
+// writeJSONPatch writes the changes as a single JSON Patch document.
+func writeJSONPatch(w io.Writer, changes []RecordChange) error {
+	content, err := json.MarshalIndent(jsonPatch(changes), "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(content, '\n'))
+	return err
+}
+
+// writeRecordChanges writes one RecordChange object per line.
+func writeRecordChanges(w io.Writer, changes []RecordChange) error {
+	enc := json.NewEncoder(w)
+	for _, c := range changes {
+		if err := enc.Encode(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// serialIndex returns the position of the retained file of a serial, the
+// latest one when serial is empty, or -1.
+func serialIndex(files []snapshotFile, serial string) int {
+	if serial == "" {
+		return len(files) - 1
+	}
+	return slices.IndexFunc(files, func(f snapshotFile) bool { return f.Version.Serial == serial })
+}
+
+// changesCommand exports the record changes of a registry between two
+// retained serials, by default the latest one and the one before it.
+func changesCommand(ctx context.Context, args []string) {
+	fset := flag.NewFlagSet("changes", flag.ExitOnError)
+	registry := fset.String("registry", "", "registry whose changes are exported")
+	from := fset.String("from", "", "serial the changes start from (default the serial before -to)")
+	to := fset.String("to", "", "serial the changes lead to (default the latest)")
+	format := fset.String("format", "jsonpatch", "output format, jsonpatch (RFC 6902) or records (one change per line)")
+	check(fset.Parse(args))
+
+	writers := map[string]func(io.Writer, []RecordChange) error{
+		"jsonpatch": writeJSONPatch,
+		"records":   writeRecordChanges,
+	}
+	write, ok := writers[*format]
+	p, found := rir.FindProvider(*registry)
+	if !ok || !found || fset.NArg() != 0 {
+		usageError("usage: rir changes -registry name [-from serial] [-to serial] [-format jsonpatch|records]")
+	}
+
+	files := historyFiles(p)
+	current := serialIndex(files, *to)
+	if current < 0 {
+		fatalf("No retained %s file of serial %q", *registry, *to)
+	}
+	previous := current - 1
+	if *from != "" {
+		previous = serialIndex(files, *from)
+	}
+	if previous < 0 {
+		fatalf("No retained %s file to compare serial %s with, see -keep", *registry, files[current].Version.Serial)
+	}
+
+	changes := recordChanges(files[previous].records(), files[current].records())
+	var b bytes.Buffer
+	check(write(&b, changes))
+	check1(os.Stdout.Write(b.Bytes()))
+	name := fmt.Sprintf("%s-%s-%s", *registry, files[previous].Version.Serial, files[current].Version.Serial)
+	exportWritten(*format, name, "", len(changes))
+	runExportHook(ctx, *format, name, true, len(changes), b.Bytes())
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/monoidic/rir/rir"
+)
+
+func TestRecordChanges(t *testing.T) {
+	ip := func(start, cc, status string) rir.IpRecord {
+		return rir.IpRecord{Record: rir.Record{Registry: "ripencc", Cc: cc, Type: rir.IPv4, Value: 256, Status: status}, Start: netip.MustParseAddr(start)}
+	}
+	previous := rir.Records{Ips: []rir.IpRecord{ip("192.0.2.0", "FR", "allocated"), ip("198.51.100.0", "DE", "assigned"), ip("203.0.113.0", "NL", "assigned")}}
+	current := rir.Records{
+		Ips:  []rir.IpRecord{ip("192.0.2.0", "FR", "allocated"), ip("198.51.100.0", "DE", "allocated")},
+		Asns: []rir.AsnRecord{{Record: rir.Record{Registry: "ripencc", Cc: "FR", Type: rir.ASN, Value: 1, Status: "assigned"}, Start: 64500}},
+	}
+
+	want := []JSONPatchOperation{
+		{Op: "replace", Path: "/ipv4|198.51.100.0|256"},
+		{Op: "remove", Path: "/ipv4|203.0.113.0|256"},
+		{Op: "add", Path: "/asn|64500|1"},
+	}
+	changes := recordChanges(previous, current)
+	patch := jsonPatch(changes)
+	if len(patch) != len(want) {
+		t.Fatalf("got %d operations %+v, want %d", len(patch), patch, len(want))
+	}
+	for i, op := range patch {
+		if op.Op != want[i].Op || op.Path != want[i].Path {
+			t.Errorf("operation %d: got %s %s, want %s %s", i, op.Op, op.Path, want[i].Op, want[i].Path)
+		}
+		if (op.Value == nil) != (op.Op == "remove") {
+			t.Errorf("operation %d: value %v", i, op.Value)
+		}
+	}
+	if changes[0].Previous == nil || changes[0].Previous.Status != "assigned" || changes[0].Record.Status != "allocated" {
+		t.Errorf("replace: got %+v", changes[0])
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"log"
+	"net/netip"
+	"strings"
+)
+
+// A classifyTarget is a prefix to classify, along with the hostname it was
+// resolved from, if any.
+type classifyTarget struct {
+	prefix netip.Prefix
+	host   string
+}
+
+// readTargetList reads a file of prefixes, addresses and hostnames, one per
+// line, ignoring blank lines and # comments. Hostnames are resolved in batch
+// and yield a target per address; unresolved ones are reported and skipped.
+func readTargetList(ctx context.Context, path string) []classifyTarget {
+	f := openInput(path)
+	defer f.Close()
+
+	var lines, hosts []string
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line, _, _ := strings.Cut(s.Text(), "#")
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		if _, err := parsePrefixOrAddr(line); err != nil {
+			hosts = append(hosts, line)
+		}
+		lines = append(lines, line)
+	}
+	check(s.Err())
+
+	var resolved map[string][]netip.Addr
+	if len(hosts) > 0 {
+		resolved = resolveHosts(ctx, hosts)
+	}
+	var targets []classifyTarget
+	for _, line := range lines {
+		if prefix, err := parsePrefixOrAddr(line); err == nil {
+			targets = append(targets, classifyTarget{prefix: prefix})
+			continue
+		}
+		if len(resolved[line]) == 0 {
+			log.Printf("Cannot resolve %s", line)
+		}
+		for _, addr := range resolved[line] {
+			targets = append(targets, classifyTarget{netip.PrefixFrom(addr, addr.BitLen()), line})
+		}
+	}
+	return targets
+}
+
+// Classification is the delegation covering a prefix of a classified list,
+// if any.
+type Classification struct {
+	Prefix      netip.Prefix  `json:"prefix"`
+	Unallocated bool          `json:"unallocated,omitempty"`
+	Country     string        `json:"country,omitempty"`
+	Registry    string        `json:"registry,omitempty"`
+	Delegation  *netip.Prefix `json:"delegation,omitempty"`
+	CountryName string        `json:"country_name,omitempty"`
+}
+
+func (c Classification) String() string {
+	switch {
+	case c.Unallocated:
+		return tsvLine(c.Prefix, "unallocated")
+	case c.CountryName != "":
+		return tsvLine(c.Prefix, c.Country, c.Registry, c.Delegation, c.CountryName)
+	default:
+		return tsvLine(c.Prefix, c.Country, c.Registry, c.Delegation)
+	}
+}
+
+// classifyCommand reports the country and registry of the delegation
+// covering each prefix, address or hostname of a user supplied list.
+func classifyCommand(ctx context.Context, args []string) {
+	fset := flag.NewFlagSet("classify", flag.ExitOnError)
+	rdns := fset.Bool("rdns", false, "include the PTR records of single addresses")
+	fset.IntVar(&resolveWorkers, "resolve-workers", resolveWorkers, "maximum number of concurrent hostname lookups")
+	fset.DurationVar(&dnsTTL, "dns-ttl", dnsTTL, "how long resolved hostnames are cached")
+	check(fset.Parse(args))
+
+	if fset.NArg() != 1 {
+		usageError("usage: rir classify [-rdns] [-resolve-workers n] [-dns-ttl duration] prefixes.txt (- for stdin)")
+	}
+
+	targets := readTargetList(ctx, fset.Arg(0))
+	table := loadPrefixTable(ctx)
+
+	var ptrs map[netip.Addr][]string
+	if *rdns {
+		var addrs []netip.Addr
+		for _, t := range targets {
+			if t.prefix.IsSingleIP() {
+				addrs = append(addrs, t.prefix.Addr())
+			}
+		}
+		ptrs = reverseDNS(ctx, addrs)
+	}
+
+	for _, t := range targets {
+		prefix := t.prefix
+		c := Classification{Prefix: prefix}
+		if d, ok := table.covering(prefix); !ok || d.Record.Status == "available" {
+			c.Unallocated = true
+		} else {
+			c.Country, c.Registry, c.Delegation = d.Record.Cc, d.Record.Registry, &d.Prefix
+			c.CountryName = countryName(d.Record.Cc)
+		}
+		var result any = c
+		if t.host != "" {
+			result = annotate(result, "host", t.host)
+		}
+		if *rdns && prefix.IsSingleIP() {
+			result = annotate(result, "ptr", strings.Join(ptrs[prefix.Addr()], ","))
+		}
+		emit(result)
+	}
+}
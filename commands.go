@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/monoidic/rir/rir"
+)
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, `usage: rir [flags] command [arguments]
+
+commands:
+  lookup address... country and prefix of addresses or hosts (-q), or
+                    delegations overlapping prefixes and ranges
+  country [-n] CC   prefixes or, with -n, hosts count of a country (-c, -n);
+                    with -asn, its AS numbers
+  asn number        delegation of an AS number (-asn)
+  all               every prefix and its country (-a)
+  %s
+
+flags:
+`, strings.Join(slices.Sorted(maps.Keys(commands)), "\n  "))
+	flag.PrintDefaults()
+}
+
+var commands = map[string]func(ctx context.Context, args []string){
+	"allowlist":    allowlistCommand,
+	"annotate":     annotateCommand,
+	"anomalies":    anomaliesCommand,
+	"bigquery":     bigqueryCommand,
+	"bloom":        bloomCommand,
+	"cache":        cacheCommand,
+	"changes":      changesCommand,
+	"classify":     classifyCommand,
+	"coverage":     coverageCommand,
+	"daemon":       daemonCommand,
+	"enrich":       enrichCommand,
+	"gen-testdata": genTestdataCommand,
+	"grep":         grepCommand,
+	"history":      historyCommand,
+	"irr":          irrCommand,
+	"origins":      originsCommand,
+	"overlap":      overlapCommand,
+	"probe":        probeCommand,
+	"report":       reportCommand,
+	"run":          runCommand,
+	"serve":        serveCommand,
+	"sign":         signCommand,
+	"snapshot":     snapshotCommand,
+	"stats":        statsCommand,
+	"targets":      targetsCommand,
+	"transfers":    transfersCommand,
+	"whoami":       whoamiCommand,
+	"zone":         zoneCommand,
+}
+
+// streamingCommands make a single pass over the full registry files, where
+// parsing them as they are read beats loading the index.
+var streamingCommands = map[string]bool{
+	"all":    true,
+	"report": true,
+	"stats":  true,
+}
+
+// dateFlag parses a date flag into t, given as 2006-01-02 or as in the
+// registry files.
+func dateFlag(t *time.Time) func(string) error {
+	return func(value string) error {
+		var err error
+		for _, layout := range []string{"2006-01-02", "20060102"} {
+			if *t, err = time.Parse(layout, value); err == nil {
+				return nil
+			}
+		}
+		return fmt.Errorf("invalid date %q, expected 2006-01-02", value)
+	}
+}
+
+// autoEngine chooses how to load the registry files for a command: the index
+// for lookups, streaming for full dumps.
+func autoEngine(command string, all bool) string {
+	if (all && commands[command] == nil) || streamingCommands[command] {
+		return rir.EngineStream
+	}
+	return rir.EngineIndex
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/big"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/monoidic/rir/rir"
+	"go4.org/netipx"
+)
+
+// countrySets builds the set of every address delegated to each of
+// countries, in the family selected by -4 or -6 if any, reading the records
+// once for all of them.
+func countrySets(ctx context.Context, countries []string) map[string]*netipx.IPSet {
+	sets := make(map[string]*netipx.IPSet, len(countries))
+	if len(countries) == 0 {
+		return sets
+	}
+	builders := make(map[string]*netipx.IPSetBuilder, len(countries))
+	for _, cc := range countries {
+		builders[cc] = &netipx.IPSetBuilder{}
+	}
+	for records := range retrieveData(ctx) {
+		for _, ip := range records.Ips {
+			b, ok := builders[ip.Cc]
+			if !ok {
+				continue
+			}
+			for prefix, err := range ip.Prefixes() {
+				if err == nil {
+					b.AddPrefix(prefix)
+				}
+			}
+		}
+	}
+	for cc, b := range builders {
+		restrictFamily(b)
+		sets[cc] = check1(b.IPSet())
+	}
+	return sets
+}
+
+// countrySet builds the set of every address delegated to any of countries,
+// in the family selected by -4 or -6 if any.
+func countrySet(ctx context.Context, countries ...string) *netipx.IPSet {
+	var b netipx.IPSetBuilder
+	for _, set := range countrySets(ctx, countries) {
+		b.AddSet(set)
+	}
+	return check1(b.IPSet())
+}
+
+// countryList splits a comma separated list of countries.
+func countryList(list string) []string {
+	var countries []string
+	for _, cc := range strings.Split(strings.ToUpper(list), ",") {
+		countries = append(countries, strings.TrimSpace(cc))
+	}
+	return countries
+}
+
+// parseCountries splits a comma separated list of countries into the listed
+// and the negated ones, written with a leading "!".
+func parseCountries(list string) (countries, negated []string) {
+	for _, cc := range strings.Split(strings.ToUpper(list), ",") {
+		cc = strings.TrimSpace(cc)
+		if cc, ok := strings.CutPrefix(cc, "!"); ok {
+			negated = append(negated, cc)
+		} else if cc != "" {
+			countries = append(countries, cc)
+		}
+	}
+	return countries, negated
+}
+
+// complementSet builds the set of every address delegated to a country other
+// than the given ones, among the records selected by filter.
+func complementSet(ctx context.Context, filter rir.Filter, countries []string) *netipx.IPSet {
+	var delegated, excluded netipx.IPSetBuilder
+	for r := range filteredPrefixes(ctx, filter) {
+		if slices.Contains(countries, r.Country) {
+			excluded.AddPrefix(r.Prefix)
+		} else {
+			delegated.AddPrefix(r.Prefix)
+		}
+	}
+	// excluded space goes away even where nested in space of another country
+	delegated.RemoveSet(check1(excluded.IPSet()))
+	restrictFamily(&delegated)
+	return subtractExcluded(check1(delegated.IPSet()))
+}
+
+// restrictFamily removes the addresses of the other family when -4 or -6 is
+// set.
+func restrictFamily(b *netipx.IPSetBuilder) {
+	switch addressFamily {
+	case rir.IPv4:
+		b.RemovePrefix(netip.MustParsePrefix("::/0"))
+	case rir.IPv6:
+		b.RemovePrefix(netip.MustParsePrefix("0.0.0.0/0"))
+	}
+}
+
+func prefixListSet(prefixes []netip.Prefix) *netipx.IPSet {
+	var b netipx.IPSetBuilder
+	for _, prefix := range prefixes {
+		b.AddPrefix(prefix.Masked())
+	}
+	return check1(b.IPSet())
+}
+
+// setSize counts the addresses of an IP set.
+func setSize(set *netipx.IPSet) *big.Int {
+	size := big.NewInt(0)
+	one := big.NewInt(1)
+	for _, prefix := range set.Prefixes() {
+		size.Add(size, new(big.Int).Lsh(one, uint(prefix.Addr().BitLen()-prefix.Bits())))
+	}
+	return size
+}
+
+func percentage(part, whole *big.Int) string {
+	if whole.Sign() == 0 {
+		return "n/a"
+	}
+	ratio := new(big.Rat).SetFrac(new(big.Int).Mul(part, big.NewInt(100)), whole)
+	return ratio.FloatString(2) + "%"
+}
+
+// CoverageSummary is how much of a prefix list lies inside the address space
+// of a country, and how much of that space it covers.
+type CoverageSummary struct {
+	InsidePrefixes   int    `json:"inside_prefixes"`
+	InputPrefixes    int    `json:"input_prefixes"`
+	InputAddresses   string `json:"input_addresses_inside"`
+	CountryAddresses string `json:"country_addresses_covered"`
+}
+
+func (s CoverageSummary) String() string {
+	return strings.Join([]string{
+		tsvLine("input prefixes inside country:", fmt.Sprintf("%d/%d", s.InsidePrefixes, s.InputPrefixes)),
+		tsvLine("input addresses inside country:", s.InputAddresses),
+		tsvLine("country addresses covered:", s.CountryAddresses),
+	}, "\n")
+}
+
+func (s CoverageSummary) csvRow() []string {
+	return []string{fmt.Sprint(s.InsidePrefixes), fmt.Sprint(s.InputPrefixes), s.InputAddresses, s.CountryAddresses}
+}
+
+// CoverageDifference is a prefix of the country missing from the list, or a
+// prefix of the list outside the country.
+type CoverageDifference struct {
+	Kind   string       `json:"kind"` // "missing" or "extra"
+	Prefix netip.Prefix `json:"prefix"`
+}
+
+func (d CoverageDifference) String() string {
+	return tsvLine(d.Kind, d.Prefix)
+}
+
+// coverageCommand compares a prefix list, typically an existing firewall
+// geo-set, with the address space delegated to a country.
+func coverageCommand(ctx context.Context, args []string) {
+	fset := flag.NewFlagSet("coverage", flag.ExitOnError)
+	country := fset.String("country", "", "2 letters string of the country (ISO 3166)")
+	input := fset.String("input", "", "file of prefixes to check, one per line (- for stdin)")
+	check(fset.Parse(args))
+
+	if *country == "" || *input == "" {
+		usageError("usage: rir coverage -country CC -input prefixes.txt")
+	}
+
+	prefixes := readPrefixList(*input)
+	inputSet := prefixListSet(prefixes)
+	ccSet := countrySet(ctx, strings.ToUpper(*country))
+
+	var b netipx.IPSetBuilder
+	b.AddSet(inputSet)
+	b.Intersect(ccSet)
+	covered := check1(b.IPSet())
+
+	inside := 0
+	for _, prefix := range prefixes {
+		if ccSet.ContainsPrefix(prefix.Masked()) {
+			inside++
+		}
+	}
+
+	coveredSize := setSize(covered)
+	emit(CoverageSummary{
+		InsidePrefixes:   inside,
+		InputPrefixes:    len(prefixes),
+		InputAddresses:   percentage(coveredSize, setSize(inputSet)),
+		CountryAddresses: percentage(coveredSize, setSize(ccSet)),
+	})
+
+	b = netipx.IPSetBuilder{}
+	b.AddSet(ccSet)
+	b.RemoveSet(inputSet)
+	for _, prefix := range check1(b.IPSet()).Prefixes() {
+		emit(CoverageDifference{"missing", prefix})
+	}
+
+	b = netipx.IPSetBuilder{}
+	b.AddSet(inputSet)
+	b.RemoveSet(ccSet)
+	for _, prefix := range check1(b.IPSet()).Prefixes() {
+		emit(CoverageDifference{"extra", prefix})
+	}
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseCountries(t *testing.T) {
+	for _, test := range []struct {
+		list               string
+		countries, negated []string
+	}{
+		{"fr", []string{"FR"}, nil},
+		{"FR, de", []string{"FR", "DE"}, nil},
+		{"!cn,!RU", nil, []string{"CN", "RU"}},
+		{"FR,!RU,", []string{"FR"}, []string{"RU"}},
+	} {
+		countries, negated := parseCountries(test.list)
+		if !slices.Equal(countries, test.countries) || !slices.Equal(negated, test.negated) {
+			t.Errorf("parseCountries(%q): got %v %v, want %v %v", test.list, countries, negated, test.countries, test.negated)
+		}
+	}
+}
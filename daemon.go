@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/monoidic/rir/rir"
+	"go4.org/netipx"
+)
+
+// ExportJob is a named export regenerated by the daemon whenever the registry
+// data changes.
+type ExportJob struct {
+	Name      string   `json:"name"`
+	Format    string   `json:"format"`
+	Countries []string `json:"countries"`
+	// Path is the file the export is written to
+	Path string `json:"path"`
+	// Hook is a shell command run after the export is written, as with
+	// -export-hook
+	Hook string `json:"hook,omitempty"`
+	// Diff makes the file only hold the changes since the previous export
+	// of the job, as with -diff-against previous
+	Diff bool `json:"diff,omitempty"`
+}
+
+// DaemonConfig is the JSON configuration file of the daemon.
+type DaemonConfig struct {
+	Jobs    []ExportJob    `json:"jobs"`
+	SMTP    *SMTPConfig    `json:"smtp,omitempty"`
+	Digests []DigestConfig `json:"digests,omitempty"`
+}
+
+func readDaemonConfig(path string) DaemonConfig {
+	var config DaemonConfig
+	check(json.Unmarshal(check1(os.ReadFile(path)), &config))
+	for _, job := range config.Jobs {
+		if _, ok := exporters[job.Format]; !ok {
+			fatalf("Export job %q: unknown format %q, expected one of %s", job.Name, job.Format, exporterNames())
+		}
+		if job.Name == "" || job.Path == "" || len(job.Countries) == 0 {
+			fatalf("Export job %q: name, path and countries are required", job.Name)
+		}
+	}
+	for _, digest := range config.Digests {
+		if len(digest.To) == 0 {
+			fatalf("Digest: to is required")
+		}
+		if config.SMTP == nil || config.SMTP.Addr == "" || config.SMTP.From == "" {
+			fatalf("Digests need the addr and from of an smtp server")
+		}
+	}
+	return config
+}
+
+// dataSerials identifies the loaded registry files, to tell when they change.
+func dataSerials(all []rir.Records) string {
+	var serials []string
+	for _, records := range all {
+		serials = append(serials, records.Registry+"-"+records.Serial)
+	}
+	slices.Sort(serials)
+	return strings.Join(serials, ".")
+}
+
+// runExportJob writes the export of a job and runs its hooks, returning the
+// export once written, even if its hook failed.
+func runExportJob(ctx context.Context, all []rir.Records, job ExportJob) ([]byte, error) {
+	var b netipx.IPSetBuilder
+	for _, country := range job.Countries {
+		for _, records := range all {
+			set, err := records.CountrySet(strings.ToUpper(country))
+			if err != nil {
+				return nil, err
+			}
+			b.AddSet(set)
+		}
+	}
+	set, err := b.IPSet()
+	if err != nil {
+		return nil, err
+	}
+	prefixes := subtractExcluded(set).Prefixes()
+
+	var content bytes.Buffer
+	sources := make([]sourceInfo, len(all))
+	for i, records := range all {
+		sources[i] = newSourceInfo(records)
+	}
+	content.Write(artifactHeader(job.Format, sources))
+	against := ""
+	if job.Diff {
+		against = "previous"
+	}
+	if err := renderExport(&content, job.Format, job.Name, against, prefixes); err != nil {
+		return nil, err
+	}
+	if err := storeArtifact(job.Format, job.Name, prefixes); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(job.Path), 0o755); err != nil {
+		return nil, err
+	}
+	tmp := job.Path + ".tmp"
+	if err := os.WriteFile(tmp, content.Bytes(), 0o644); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, job.Path); err != nil {
+		return nil, err
+	}
+	if err := signArtifact(job.Path, content.Bytes()); err != nil {
+		return nil, err
+	}
+	exportWritten(job.Format, job.Name, job.Path, len(prefixes))
+
+	if job.Hook != "" {
+		if err := execHook(ctx, job.Hook, job.Path, job.Format, job.Name, job.Diff, len(prefixes)); err != nil {
+			return content.Bytes(), fmt.Errorf("hook: %w", err)
+		}
+	}
+	runExportHook(ctx, job.Format, job.Name, job.Diff, len(prefixes), content.Bytes())
+	return content.Bytes(), nil
+}
+
+// daemonCommand regenerates the export jobs of a configuration file every
+// time the registry files change, checking for new files at an interval, and
+// mails the digests of the changes every day.
+func daemonCommand(ctx context.Context, args []string) {
+	fset := flag.NewFlagSet("daemon", flag.ExitOnError)
+	configPath := fset.String("config", "", "JSON file of the export jobs")
+	interval := fset.Duration("interval", time.Hour, "how often to check for new registry files")
+	listen := fset.String("listen", "", "address to serve the latest exports on over HTTP, at /exports/<name>")
+	check(fset.Parse(args))
+
+	if *configPath == "" || fset.NArg() != 0 {
+		usageError("usage: rir daemon -config jobs.json [-interval duration] [-listen address]")
+	}
+	config := readDaemonConfig(*configPath)
+
+	artifacts := newArtifactServer()
+	if *listen != "" {
+		srv := &http.Server{Addr: *listen, Handler: artifacts.handler()}
+		go func() {
+			<-ctx.Done()
+			srv.Shutdown(context.Background())
+		}()
+		go func() {
+			log.Printf("Serving exports on %s", *listen)
+			// check would panic outside of the reach of recoverFatal
+			if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				fatal(codeFatal, err)
+			}
+		}()
+	}
+
+	digests := newDigester(config, time.Now())
+	var previous string
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		all := slices.Collect(retrieveData(ctx))
+		if serials := dataSerials(all); serials != previous {
+			log.Printf("Registry data changed, running %d export jobs", len(config.Jobs))
+			for _, job := range config.Jobs {
+				// a failing job must not stop the others
+				content, err := runExportJob(ctx, all, job)
+				if err != nil {
+					log.Printf("Export job %s: %v", job.Name, err)
+				}
+				if content != nil {
+					artifacts.publish(job, content, serials)
+				}
+			}
+			previous = serials
+			digests.collect(all)
+		}
+		if len(config.Digests) > 0 {
+			digests.flush(time.Now())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/monoidic/rir/rir"
+)
+
+func TestRunExportJob(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	all := []rir.Records{{Registry: "ripencc", Serial: "20250101", Ips: []rir.IpRecord{
+		{Record: rir.Record{Registry: "ripencc", Cc: "FR", Type: rir.IPv4, Value: 512, Status: "allocated"}, Start: netip.MustParseAddr("192.0.2.0")},
+		{Record: rir.Record{Registry: "ripencc", Cc: "DE", Type: rir.IPv4, Value: 256, Status: "allocated"}, Start: netip.MustParseAddr("198.51.100.0")},
+	}}}
+	job := ExportJob{
+		Name:      "geo",
+		Format:    "plain",
+		Countries: []string{"fr"},
+		Path:      filepath.Join(dir, "out", "geo.txt"),
+		Hook:      `echo "$RIR_EXPORT_NAME $RIR_EXPORT_ENTRIES" > "$1.hook"`,
+	}
+
+	exported, err := runExportJob(context.Background(), all, job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content, err := os.ReadFile(job.Path); err != nil || string(content) != string(exported) || string(content) != "# generated by rir (devel)\n# source ripencc serial 20250101 from https://ftp.ripe.net/pub/stats/ripencc/delegated-ripencc-extended-latest\n192.0.2.0/23\n" {
+		t.Errorf("export: got %q, %v", content, err)
+	}
+	if content, err := os.ReadFile(job.Path + ".hook"); err != nil || string(content) != "geo 1\n" {
+		t.Errorf("hook: got %q, %v", content, err)
+	}
+
+	if dataSerials(all) != "ripencc-20250101" {
+		t.Errorf("serials: got %q", dataSerials(all))
+	}
+
+	// the next export of a diff job only holds the changes
+	job.Diff, job.Hook = true, ""
+	all[0].Ips[1].Cc = "FR"
+	exported, err = runExportJob(context.Background(), all, job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := strings.Join(strings.SplitAfter(string(exported), "\n")[2:], ""); diff != "+198.51.100.0/24\n" {
+		t.Errorf("diff export: got %q", exported)
+	}
+}
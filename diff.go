@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/monoidic/rir/rir"
+)
+
+// A differ renders the operations turning a device loaded with the previous
+// export into one loaded with the current export, so that it can be updated
+// incrementally instead of reloaded.
+type differ func(w io.Writer, name string, previous, current []netip.Prefix) error
+
+var differs = map[string]differ{
+	"plain":       diffPlain,
+	"nftables":    diffNftables,
+	"ipset":       diffIpset,
+	"prefix-list": diffPrefixList,
+	"zmap":        diffPlain,
+	"masscan":     diffPlain,
+}
+
+// nftablesTable is the table holding the sets updated by nftables diffs.
+var nftablesTable = "inet filter"
+
+// prefixDelta returns the entries of current missing from previous and the
+// entries of previous missing from current. Devices hold the exact entries
+// they were given, so entries are compared as is rather than as address
+// ranges.
+func prefixDelta(previous, current []netip.Prefix) (added, removed []netip.Prefix) {
+	in := func(list []netip.Prefix) map[netip.Prefix]bool {
+		set := make(map[netip.Prefix]bool, len(list))
+		for _, prefix := range list {
+			set[prefix] = true
+		}
+		return set
+	}
+	previousSet, currentSet := in(previous), in(current)
+
+	for _, prefix := range current {
+		if !previousSet[prefix] {
+			added = append(added, prefix)
+		}
+	}
+	for _, prefix := range previous {
+		if !currentSet[prefix] {
+			removed = append(removed, prefix)
+		}
+	}
+	return added, removed
+}
+
+func diffPlain(w io.Writer, name string, previous, current []netip.Prefix) error {
+	added, removed := prefixDelta(previous, current)
+	for _, op := range []struct {
+		sign     string
+		prefixes []netip.Prefix
+	}{{"-", removed}, {"+", added}} {
+		for _, prefix := range op.prefixes {
+			if _, err := fmt.Fprintf(w, "%s%s\n", op.sign, prefix); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func diffNftables(w io.Writer, name string, previous, current []netip.Prefix) error {
+	added, removed := prefixDelta(previous, current)
+	for _, op := range []struct {
+		verb     string
+		prefixes []netip.Prefix
+	}{{"delete", removed}, {"add", added}} {
+		v4, v6 := splitFamilies(op.prefixes)
+		for _, family := range []struct {
+			suffix   string
+			prefixes []netip.Prefix
+		}{{"v4", v4}, {"v6", v6}} {
+			if len(family.prefixes) == 0 {
+				continue
+			}
+			elements := make([]string, len(family.prefixes))
+			for i, prefix := range family.prefixes {
+				elements[i] = prefix.String()
+			}
+			_, err := fmt.Fprintf(w, "%s element %s %s_%s { %s }\n",
+				op.verb, nftablesTable, name, family.suffix, strings.Join(elements, ", "))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func diffIpset(w io.Writer, name string, previous, current []netip.Prefix) error {
+	added, removed := prefixDelta(previous, current)
+	for _, op := range []struct {
+		verb     string
+		prefixes []netip.Prefix
+	}{{"del", removed}, {"add", added}} {
+		for _, prefix := range op.prefixes {
+			suffix := "v6"
+			if prefix.Addr().Is4() {
+				suffix = "v4"
+			}
+			if _, err := fmt.Fprintf(w, "%s %s_%s %s -exist\n", op.verb, name, suffix, prefix); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// diffPrefixList compares the numbered entries of the prefix lists, as an
+// inserted prefix shifts the sequence numbers of the entries after it.
+func diffPrefixList(w io.Writer, name string, previous, current []netip.Prefix) error {
+	type entry struct {
+		seq    int
+		prefix netip.Prefix
+	}
+	entries := func(prefixes []netip.Prefix) map[entry]bool {
+		v4, v6 := splitFamilies(prefixes)
+		set := make(map[entry]bool)
+		for _, family := range [][]netip.Prefix{v4, v6} {
+			for i, prefix := range family {
+				set[entry{(i + 1) * 5, prefix}] = true
+			}
+		}
+		return set
+	}
+	previousEntries, currentEntries := entries(previous), entries(current)
+
+	for _, op := range []struct {
+		no       string
+		from, to map[entry]bool
+	}{{"no ", previousEntries, currentEntries}, {"", currentEntries, previousEntries}} {
+		var changed []entry
+		for e := range op.from {
+			if !op.to[e] {
+				changed = append(changed, e)
+			}
+		}
+		slices.SortFunc(changed, func(a, b entry) int {
+			if a.prefix.Addr().Is4() != b.prefix.Addr().Is4() {
+				if a.prefix.Addr().Is4() {
+					return -1
+				}
+				return 1
+			}
+			return a.seq - b.seq
+		})
+		for _, e := range changed {
+			keyword := "ipv6"
+			if e.prefix.Addr().Is4() {
+				keyword = "ip"
+			}
+			if _, err := fmt.Fprintf(w, "%s%s prefix-list %s seq %d permit %s\n", op.no, keyword, name, e.seq, e.prefix); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// artifactPath is where the last export of a set or list in a format is
+// recorded, for later exports to be diffed against it.
+func artifactPath(format, name string) string {
+	return filepath.Join(rir.GetCacheDir(), "exports", format, name)
+}
+
+// previousArtifact reads the prefixes of the last generated export. An export
+// that was never generated is empty, so diffing against it adds everything.
+func previousArtifact(format, name string) []netip.Prefix {
+	path := artifactPath(format, name)
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		log.Printf("No previous %s export named %s, diffing against an empty one", format, name)
+		return nil
+	}
+	return readPrefixList(path)
+}
+
+// storeArtifact records the prefixes of an export as the reference of the
+// next diff.
+func storeArtifact(format, name string, prefixes []netip.Prefix) error {
+	path := artifactPath(format, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	var b bytes.Buffer
+	if err := exportPlain(&b, name, prefixes); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b.Bytes(), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// diffAgainst is set by -diff-against to "previous", the last generated
+// export, or to a file of prefixes, for exports to only hold the changes
+// relative to it.
+var diffAgainst string
+
+// renderExport writes prefixes in format, or only their changes relative to
+// against when set, as diffAgainst.
+func renderExport(w io.Writer, format, name, against string, prefixes []netip.Prefix) error {
+	switch against {
+	case "":
+		return exporters[format](w, name, prefixes)
+	case "previous":
+		return differs[format](w, name, previousArtifact(format, name), prefixes)
+	default:
+		return differs[format](w, name, readPrefixList(against), prefixes)
+	}
+}
+
+// writeExport writes prefixes in format, or only their changes with
+// -diff-against, records them for the next diff and runs the export hook.
+func writeExport(ctx context.Context, w io.Writer, format, name string, prefixes []netip.Prefix) {
+	var b bytes.Buffer
+	b.Write(artifactHeader(format, loaded()))
+	check(renderExport(&b, format, name, diffAgainst, prefixes))
+	check1(w.Write(b.Bytes()))
+	check(storeArtifact(format, name, prefixes))
+	exportWritten(format, name, "", len(prefixes))
+	runExportHook(ctx, format, name, diffAgainst != "", len(prefixes), b.Bytes())
+}
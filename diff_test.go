@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"net/netip"
+	"testing"
+)
+
+func TestDiffers(t *testing.T) {
+	for name := range exporters {
+		if differs[name] == nil {
+			t.Errorf("export format %s has no differ", name)
+		}
+	}
+
+	previous := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("192.0.2.0/24"),
+		netip.MustParsePrefix("2001:db8::/32"),
+	}
+	current := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("198.51.100.0/24"),
+		netip.MustParsePrefix("2001:db8::/32"),
+	}
+
+	for format, want := range map[string]string{
+		"plain": "-192.0.2.0/24\n+198.51.100.0/24\n",
+		"nftables": "delete element inet filter geo_v4 { 192.0.2.0/24 }\n" +
+			"add element inet filter geo_v4 { 198.51.100.0/24 }\n",
+		"ipset": "del geo_v4 192.0.2.0/24 -exist\nadd geo_v4 198.51.100.0/24 -exist\n",
+		"prefix-list": "no ip prefix-list geo seq 10 permit 192.0.2.0/24\n" +
+			"ip prefix-list geo seq 10 permit 198.51.100.0/24\n",
+	} {
+		var b bytes.Buffer
+		if err := differs[format](&b, "geo", previous, current); err != nil {
+			t.Fatal(err)
+		}
+		if got := b.String(); got != want {
+			t.Errorf("%s diff:\ngot  %q\nwant %q", format, got, want)
+		}
+
+		b.Reset()
+		if err := differs[format](&b, "geo", current, current); err != nil {
+			t.Fatal(err)
+		}
+		if b.Len() != 0 {
+			t.Errorf("%s diff of identical exports: %q", format, b.String())
+		}
+	}
+}
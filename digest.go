@@ -0,0 +1,158 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"log"
+	"maps"
+	"net"
+	"net/netip"
+	"net/smtp"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/monoidic/rir/rir"
+)
+
+// SMTPConfig is the mail server the daemon sends its digests through.
+type SMTPConfig struct {
+	// Addr is the host:port of the server
+	Addr     string `json:"addr"`
+	From     string `json:"from"`
+	Username string `json:"username,omitempty"`
+	// Password defaults to $RIR_SMTP_PASSWORD
+	Password string `json:"password,omitempty"`
+}
+
+// DigestConfig is a daily email of the delegation changes of the watched
+// countries and registries.
+type DigestConfig struct {
+	To         []string `json:"to"`
+	Countries  []string `json:"countries,omitempty"`
+	Registries []string `json:"registries,omitempty"`
+	// LargeV4 and LargeV6 are the longest prefixes listed as notable blocks,
+	// /16 and /32 by default
+	LargeV4 int `json:"large_v4,omitempty"`
+	LargeV6 int `json:"large_v6,omitempty"`
+}
+
+// watches tells whether a change of a registry is in the digest.
+func (d DigestConfig) watches(registry, country string) bool {
+	return (len(d.Registries) == 0 || slices.Contains(d.Registries, registry)) &&
+		(len(d.Countries) == 0 || slices.ContainsFunc(d.Countries, func(cc string) bool { return strings.EqualFold(cc, country) }))
+}
+
+// large tells whether a delegated prefix is a notable block.
+func (d DigestConfig) large(prefix netip.Prefix) bool {
+	if prefix.Addr().Is4() {
+		return prefix.Bits() <= cmp.Or(d.LargeV4, 16)
+	}
+	return prefix.Bits() <= cmp.Or(d.LargeV6, 32)
+}
+
+// buildDigest writes the digest of the changes of the day, counting the new
+// and removed delegations of each watched country by registry and listing
+// the large blocks. It returns false when no change is watched.
+func buildDigest(d DigestConfig, day string, changes []registryChanges) (subject, body string, ok bool) {
+	var b, notable strings.Builder
+	countries := map[string]bool{}
+	for _, c := range changes {
+		counts := map[string]*[2]int{}
+		for i, list := range [][]delegationChange{c.Added, c.Removed} {
+			for _, change := range list {
+				if !d.watches(c.Registry, change.Country) {
+					continue
+				}
+				if counts[change.Country] == nil {
+					counts[change.Country] = new([2]int)
+				}
+				counts[change.Country][i]++
+				if prefix, err := netip.ParsePrefix(change.Resource); err == nil && d.large(prefix) {
+					fmt.Fprintf(&notable, "  %c%s %s %s\n", "+-"[i], change.Country, change.Resource, c.Registry)
+				}
+			}
+		}
+		if len(counts) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "%s serial %s (previous %s)\n", c.Registry, c.Serial, c.PreviousSerial)
+		for _, cc := range slices.Sorted(maps.Keys(counts)) {
+			fmt.Fprintf(&b, "  %s\t%d new\t%d removed\n", cc, counts[cc][0], counts[cc][1])
+			countries[cc] = true
+		}
+		b.WriteByte('\n')
+	}
+	if len(countries) == 0 {
+		return "", "", false
+	}
+	if notable.Len() > 0 {
+		fmt.Fprintf(&b, "Large blocks\n%s", notable.String())
+	}
+	subject = fmt.Sprintf("rir digest %s: %s", day, strings.Join(slices.Sorted(maps.Keys(countries)), ", "))
+	return subject, b.String(), true
+}
+
+// sendMail is smtp.SendMail, replaced in tests.
+var sendMail = smtp.SendMail
+
+func sendDigest(config SMTPConfig, to []string, subject, body string) error {
+	var auth smtp.Auth
+	if config.Username != "" {
+		host, _, err := net.SplitHostPort(config.Addr)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", config.Username, cmp.Or(config.Password, os.Getenv("RIR_SMTP_PASSWORD")), host)
+	}
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s",
+		config.From, strings.Join(to, ", "), subject, time.Now().Format(time.RFC1123Z), strings.ReplaceAll(body, "\n", "\r\n"))
+	return sendMail(config.Addr, auth, config.From, to, []byte(msg))
+}
+
+// digester collects the changes of the registry files over a day, and mails
+// them once the day is over.
+type digester struct {
+	config  DaemonConfig
+	day     string
+	serials map[string]string
+	pending []registryChanges
+}
+
+func newDigester(config DaemonConfig, now time.Time) *digester {
+	return &digester{config: config, day: now.Format(time.DateOnly), serials: map[string]string{}}
+}
+
+// collect adds the changes of the registry files whose serial is new.
+func (d *digester) collect(all []rir.Records) {
+	for _, records := range all {
+		previous, seen := d.serials[records.Registry]
+		d.serials[records.Registry] = records.Serial
+		// the files loaded at startup are not changes of the day
+		if !seen || previous == records.Serial {
+			continue
+		}
+		if changes, ok := latestChanges(records); ok {
+			d.pending = append(d.pending, changes)
+		}
+	}
+}
+
+// flush sends the digests of the previous day once it is over.
+func (d *digester) flush(now time.Time) {
+	today := now.Format(time.DateOnly)
+	if today == d.day {
+		return
+	}
+	for _, digest := range d.config.Digests {
+		subject, body, ok := buildDigest(digest, d.day, d.pending)
+		if !ok {
+			continue
+		}
+		if err := sendDigest(*d.config.SMTP, digest.To, subject, body); err != nil {
+			log.Printf("Sending digest to %s: %v", strings.Join(digest.To, ", "), err)
+		}
+	}
+	d.day, d.pending = today, nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildDigest(t *testing.T) {
+	changes := []registryChanges{{
+		Registry:       "ripencc",
+		Serial:         "20250102",
+		PreviousSerial: "20250101",
+		Added:          []delegationChange{{"FR", "2.0.0.0/12"}, {"FR", "192.0.2.0/24"}, {"DE", "198.51.100.0/24"}},
+		Removed:        []delegationChange{{"FR", "AS64496"}},
+	}, {
+		Registry: "arin",
+		Serial:   "20250102",
+		Added:    []delegationChange{{"US", "3.0.0.0/8"}},
+	}}
+
+	subject, body, ok := buildDigest(DigestConfig{Countries: []string{"fr"}}, "2025-01-02", changes)
+	if !ok || subject != "rir digest 2025-01-02: FR" {
+		t.Errorf("subject: got %q, %t", subject, ok)
+	}
+	want := "ripencc serial 20250102 (previous 20250101)\n  FR\t2 new\t1 removed\n\nLarge blocks\n  +FR 2.0.0.0/12 ripencc\n"
+	if body != want {
+		t.Errorf("body: got %q, want %q", body, want)
+	}
+
+	subject, body, _ = buildDigest(DigestConfig{Registries: []string{"ripencc"}, LargeV4: 8}, "2025-01-02", changes)
+	if subject != "rir digest 2025-01-02: DE, FR" || strings.Contains(body, "Large blocks") {
+		t.Errorf("registry digest: got %q %q", subject, body)
+	}
+
+	if _, _, ok := buildDigest(DigestConfig{Countries: []string{"JP"}}, "2025-01-02", changes); ok {
+		t.Error("digest without watched changes")
+	}
+}
+
+func TestDigesterFlush(t *testing.T) {
+	defer func(f func(string, smtp.Auth, string, []string, []byte) error) { sendMail = f }(sendMail)
+	var sent []string
+	sendMail = func(addr string, _ smtp.Auth, from string, to []string, msg []byte) error {
+		sent = append(sent, string(msg))
+		return nil
+	}
+
+	config := DaemonConfig{
+		SMTP:    &SMTPConfig{Addr: "mail.example:25", From: "rir@example.com"},
+		Digests: []DigestConfig{{To: []string{"noc@example.com"}, Countries: []string{"FR"}}},
+	}
+	start := time.Date(2025, 1, 2, 10, 0, 0, 0, time.UTC)
+	d := newDigester(config, start)
+	d.pending = []registryChanges{{Registry: "ripencc", Serial: "20250102", Added: []delegationChange{{"FR", "192.0.2.0/24"}}}}
+
+	d.flush(start.Add(time.Hour))
+	if len(sent) != 0 {
+		t.Errorf("sent before the end of the day: %q", sent)
+	}
+	d.flush(start.Add(24 * time.Hour))
+	if len(sent) != 1 || !strings.Contains(sent[0], "To: noc@example.com\r\nSubject: rir digest 2025-01-02: FR\r\n") {
+		t.Errorf("digest: got %q", sent)
+	}
+	if d.pending != nil || d.day != "2025-01-03" {
+		t.Errorf("after flush: pending %v, day %s", d.pending, d.day)
+	}
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"net/netip"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/monoidic/rir/rir"
+)
+
+// enrichLookup returns the delegation of an address, its country set to
+// unknownCountry when missing. Available space is not delegated.
+type enrichLookup func(netip.Addr) (rir.IpRecord, bool)
+
+func tableLookup(table *prefixTable[delegation]) enrichLookup {
+	return func(addr netip.Addr) (rir.IpRecord, bool) {
+		addr = addr.Unmap()
+		d, ok := table.covering(netip.PrefixFrom(addr, addr.BitLen()))
+		if !ok || d.Record.Status == "available" {
+			return rir.IpRecord{}, false
+		}
+		d.Record.Cc = cmp.Or(d.Record.Cc, unknownCountry)
+		return d.Record, true
+	}
+}
+
+// An enrichCodec copies records of an input format from r to w, adding the
+// country and registry of the addresses they hold.
+type enrichCodec interface {
+	enrich(r io.Reader, w io.Writer, lookup enrichLookup) error
+}
+
+// enrichCodecs make the codec of an -input-format, given the argument
+// following its name and a colon.
+var enrichCodecs = map[string]func(arg string) (enrichCodec, error){
+	"lines": func(string) (enrichCodec, error) { return lineCodec(annotateLine), nil },
+	"csv":   newCSVCodec,
+	"json": func(arg string) (enrichCodec, error) {
+		if arg == "" {
+			return nil, errors.New("json needs the field of the address, as json:field")
+		}
+		return jsonCodec{strings.Split(arg, ",")}, nil
+	},
+	"clf": func(string) (enrichCodec, error) { return lineCodec(enrichCLF), nil },
+	// Suricata EVE JSON
+	"eve": func(string) (enrichCodec, error) { return jsonCodec{[]string{"src_ip", "dest_ip"}}, nil },
+}
+
+func enrichFormatNames() string {
+	return strings.Join(slices.Sorted(maps.Keys(enrichCodecs)), ", ")
+}
+
+// lineCodec enriches text line by line.
+type lineCodec func(lookup enrichLookup, line string) string
+
+func (c lineCodec) enrich(r io.Reader, w io.Writer, lookup enrichLookup) error {
+	return copyLines(r, w, func(line string) (string, bool) {
+		return c(lookup, line), true
+	})
+}
+
+// copyLines copies the lines of r to w as rewritten by f, without their
+// line terminator, leaving out those it rejects.
+func copyLines(r io.Reader, w io.Writer, f func(line string) (string, bool)) error {
+	br := bufio.NewReader(r)
+	bw := bufio.NewWriter(w)
+	for {
+		line, err := br.ReadString('\n')
+		if line != "" {
+			text, newline := strings.CutSuffix(line, "\n")
+			if text, ok := f(text); ok {
+				bw.WriteString(text)
+				if newline {
+					bw.WriteByte('\n')
+				}
+			}
+		}
+		// flush whenever the input runs dry, so that piping tail -f
+		// shows lines as they come
+		if br.Buffered() == 0 || err != nil {
+			if err := bw.Flush(); err != nil {
+				return err
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// enrichCLF appends the country and registry of the client of a Common (or
+// Combined) Log Format line as two quoted fields, "-" when unknown.
+func enrichCLF(lookup enrichLookup, line string) string {
+	country, registry := "-", "-"
+	host, _, _ := strings.Cut(line, " ")
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if record, ok := lookup(addr); ok {
+			country, registry = record.Cc, record.Registry
+		}
+	}
+	if strings.TrimSpace(line) == "" {
+		return line
+	}
+	return fmt.Sprintf("%s %q %q", line, country, registry)
+}
+
+// csvCodec appends country and registry columns to CSV rows, for the
+// address of a column given by its header name or its number from 1, in
+// which case the input has no header.
+type csvCodec struct {
+	name   string
+	column int
+}
+
+func newCSVCodec(arg string) (enrichCodec, error) {
+	if arg == "" {
+		return nil, errors.New("csv needs the column of the address, as csv:name or csv:number")
+	}
+	if n, err := strconv.Atoi(arg); err == nil {
+		if n < 1 {
+			return nil, fmt.Errorf("invalid csv column %d, columns are numbered from 1", n)
+		}
+		return csvCodec{column: n - 1}, nil
+	}
+	return csvCodec{name: arg}, nil
+}
+
+func (c csvCodec) enrich(r io.Reader, w io.Writer, lookup enrichLookup) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cw := csv.NewWriter(w)
+	column := c.column
+	if c.name != "" {
+		header, err := cr.Read()
+		if err != nil {
+			return err
+		}
+		if column = slices.Index(header, c.name); column < 0 {
+			return fmt.Errorf("no csv column %q in %q", c.name, header)
+		}
+		if err := cw.Write(append(header, "country", "registry")); err != nil {
+			return err
+		}
+	}
+	for {
+		row, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return err
+		}
+		var country, registry string
+		if column < len(row) {
+			if addr, err := netip.ParseAddr(strings.TrimSpace(row[column])); err == nil {
+				if record, ok := lookup(addr); ok {
+					country, registry = record.Cc, record.Registry
+				}
+			}
+		}
+		if err := cw.Write(append(row, country, registry)); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// jsonCodec enriches JSON lines, setting field_country and field_registry
+// next to each field holding an address. Fields of nested objects are given
+// as dotted paths.
+type jsonCodec struct {
+	fields []string
+}
+
+func (c jsonCodec) enrich(r io.Reader, w io.Writer, lookup enrichLookup) error {
+	return lineCodec(func(lookup enrichLookup, line string) string {
+		if strings.TrimSpace(line) == "" {
+			return line
+		}
+		d := json.NewDecoder(strings.NewReader(line))
+		d.UseNumber()
+		var object map[string]any
+		if err := d.Decode(&object); err != nil {
+			// pass through what is not an object, such as a
+			// truncated line
+			log.Printf("Not enriching %.40q: %v", line, err)
+			return line
+		}
+		for _, field := range c.fields {
+			enrichJSONField(object, strings.Split(field, "."), lookup)
+		}
+		var b bytes.Buffer
+		e := json.NewEncoder(&b)
+		e.SetEscapeHTML(false)
+		check(e.Encode(object))
+		return strings.TrimSuffix(b.String(), "\n")
+	}).enrich(r, w, lookup)
+}
+
+func enrichJSONField(object map[string]any, path []string, lookup enrichLookup) {
+	for _, key := range path[:len(path)-1] {
+		next, ok := object[key].(map[string]any)
+		if !ok {
+			return
+		}
+		object = next
+	}
+	key := path[len(path)-1]
+	value, _ := object[key].(string)
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return
+	}
+	if record, ok := lookup(addr); ok {
+		object[key+"_country"] = record.Cc
+		object[key+"_registry"] = record.Registry
+	}
+}
+
+// runEnrich enriches the input at path, - for stdin, to the standard output.
+func runEnrich(ctx context.Context, codec enrichCodec, path string) {
+	lookup := tableLookup(loadPrefixTable(ctx))
+	f := openInput(path)
+	defer f.Close()
+	check(codec.enrich(f, os.Stdout, lookup))
+}
+
+// enrichCommand adds the country and registry of addresses to structured
+// input, such as logs, of the format chosen with -input-format.
+func enrichCommand(ctx context.Context, args []string) {
+	fset := flag.NewFlagSet("enrich", flag.ExitOnError)
+	format := fset.String("input-format", "lines", "format of the input, one of "+enrichFormatNames()+`; csv takes the column of the addresses as csv:name or csv:number, json their fields as json:field[,field...]`)
+	check(fset.Parse(args))
+
+	name, arg, _ := strings.Cut(*format, ":")
+	newCodec, ok := enrichCodecs[name]
+	if !ok || fset.NArg() > 1 {
+		usageError("usage: rir enrich [-input-format %s] [file] (stdin by default)", enrichFormatNames())
+	}
+	codec, err := newCodec(arg)
+	if err != nil {
+		usageError("-input-format %s: %v", *format, err)
+	}
+	path := "-"
+	if fset.NArg() == 1 {
+		path = fset.Arg(0)
+	}
+	runEnrich(ctx, codec, path)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/monoidic/rir/rir"
+)
+
+// holderLookup looks up addresses in the delegations of holderData.
+func holderLookup(t *testing.T) enrichLookup {
+	records, err := rir.NewReader(strings.NewReader(holderData)).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	table := newPrefixTable[delegation]()
+	for _, record := range records.Ips {
+		for prefix := range record.Net() {
+			table.add(prefix, delegation{Prefix: prefix, Record: record})
+		}
+	}
+	return tableLookup(table)
+}
+
+func TestEnrichCodecs(t *testing.T) {
+	lookup := holderLookup(t)
+	for _, test := range []struct {
+		format, input, want string
+	}{
+		{
+			"lines",
+			"from 2.3.4.5 to 192.0.2.1\npartial 193.18.0.1",
+			"from 2.3.4.5[FR] to 192.0.2.1\npartial 193.18.0.1[DE]",
+		},
+		{
+			"clf",
+			"2.3.4.5 - - [16/Oct/2026:08:57:58 +0000] \"GET / HTTP/1.1\" 200 612\n192.0.2.1 - - [16/Oct/2026:08:57:59 +0000] \"GET / HTTP/1.1\" 404 0\n",
+			"2.3.4.5 - - [16/Oct/2026:08:57:58 +0000] \"GET / HTTP/1.1\" 200 612 \"FR\" \"ripencc\"\n192.0.2.1 - - [16/Oct/2026:08:57:59 +0000] \"GET / HTTP/1.1\" 404 0 \"-\" \"-\"\n",
+		},
+		{
+			"csv:client",
+			"time,client\n1,2.3.4.5\n2,\"193.18.0.1\"\n3,nope\n",
+			"time,client,country,registry\n1,2.3.4.5,FR,ripencc\n2,193.18.0.1,DE,ripencc\n3,nope,,\n",
+		},
+		{
+			"csv:2",
+			"1,2.3.4.5\n",
+			"1,2.3.4.5,FR,ripencc\n",
+		},
+		{
+			"json:req.ip",
+			`{"req":{"ip":"2.3.4.5"},"n":12345678901234567890}` + "\n",
+			`{"n":12345678901234567890,"req":{"ip":"2.3.4.5","ip_country":"FR","ip_registry":"ripencc"}}` + "\n",
+		},
+		{
+			"eve",
+			`{"src_ip":"193.18.0.1","dest_ip":"192.0.2.1","event_type":"alert"}` + "\n",
+			`{"dest_ip":"192.0.2.1","event_type":"alert","src_ip":"193.18.0.1","src_ip_country":"DE","src_ip_registry":"ripencc"}` + "\n",
+		},
+	} {
+		name, arg, _ := strings.Cut(test.format, ":")
+		codec, err := enrichCodecs[name](arg)
+		if err != nil {
+			t.Fatalf("%s: %v", test.format, err)
+		}
+		var b strings.Builder
+		if err := codec.enrich(strings.NewReader(test.input), &b, lookup); err != nil {
+			t.Errorf("%s: %v", test.format, err)
+		}
+		if b.String() != test.want {
+			t.Errorf("%s: got %q, want %q", test.format, b.String(), test.want)
+		}
+	}
+
+	for _, format := range []string{"csv", "csv:0", "json"} {
+		name, arg, _ := strings.Cut(format, ":")
+		if _, err := enrichCodecs[name](arg); err == nil {
+			t.Errorf("%s: unexpected success", format)
+		}
+	}
+}
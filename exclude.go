@@ -0,0 +1,194 @@
+package main
+
+import (
+	"iter"
+	"net/netip"
+	"slices"
+
+	"go4.org/netipx"
+)
+
+// excludeSet holds the prefixes subtracted from every country and export
+// output, nil when there are none.
+var excludeSet *netipx.IPSet
+
+// loadExcludeFile adds the prefixes of a file to the excluded ones, so that
+// -exclude-file can be repeated.
+func loadExcludeFile(path string) error {
+	var b netipx.IPSetBuilder
+	if excludeSet != nil {
+		b.AddSet(excludeSet)
+	}
+	b.AddSet(prefixListSet(readPrefixList(path)))
+	excludeSet = check1(b.IPSet())
+	return nil
+}
+
+// intersectSet holds the prefixes every country and export output is
+// restricted to, nil when unrestricted.
+var intersectSet *netipx.IPSet
+
+// loadIntersectFile adds the prefixes of a file to those output is
+// restricted to.
+func loadIntersectFile(path string) error {
+	var b netipx.IPSetBuilder
+	if intersectSet != nil {
+		b.AddSet(intersectSet)
+	}
+	b.AddSet(prefixListSet(readPrefixList(path)))
+	intersectSet = check1(b.IPSet())
+	return nil
+}
+
+// restrictOutput applies -intersect and -exclude-file to b.
+func restrictOutput(b *netipx.IPSetBuilder) {
+	if intersectSet != nil {
+		b.Intersect(intersectSet)
+	}
+	if excludeSet != nil {
+		b.RemoveSet(excludeSet)
+	}
+}
+
+// subtractExcluded restricts set to the -intersect prefixes and removes the
+// excluded prefixes from it.
+func subtractExcluded(set *netipx.IPSet) *netipx.IPSet {
+	if excludeSet == nil && intersectSet == nil {
+		return set
+	}
+	var b netipx.IPSetBuilder
+	b.AddSet(set)
+	restrictOutput(&b)
+	return check1(b.IPSet())
+}
+
+// excludeByCountry restricts the prefixes of seq to the -intersect prefixes
+// and subtracts the excluded prefixes from them, then merges the remaining
+// pieces of each country, which may be contiguous once cut, in the order the
+// countries first appear. Pieces keep the record and provenance of the
+// delegation they come from, unless merged across several delegations. seq
+// is returned unchanged when nothing is excluded nor intersected.
+func excludeByCountry(seq iter.Seq[CountryPrefix]) iter.Seq[CountryPrefix] {
+	if excludeSet == nil && intersectSet == nil {
+		return seq
+	}
+	return func(yield func(CountryPrefix) bool) {
+		var countries []string
+		pieces := make(map[string][]CountryPrefix)
+		for r := range seq {
+			if intersectSet != nil && !intersectSet.OverlapsPrefix(r.Prefix) {
+				continue
+			}
+			if _, ok := pieces[r.Country]; !ok {
+				countries = append(countries, r.Country)
+				pieces[r.Country] = nil
+			}
+			if (intersectSet == nil || intersectSet.ContainsPrefix(r.Prefix)) && (excludeSet == nil || !excludeSet.OverlapsPrefix(r.Prefix)) {
+				pieces[r.Country] = append(pieces[r.Country], r)
+				continue
+			}
+
+			var b netipx.IPSetBuilder
+			b.AddPrefix(r.Prefix)
+			restrictOutput(&b)
+			for _, prefix := range check1(b.IPSet()).Prefixes() {
+				piece := r
+				piece.Prefix = prefix
+				pieces[r.Country] = append(pieces[r.Country], piece)
+			}
+		}
+
+		for _, cc := range countries {
+			for piece := range mergePieces(pieces[cc]) {
+				if !yield(piece) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// mergePieces aggregates the pieces of a single country, yielding the merged
+// prefixes in address order.
+func mergePieces(pieces []CountryPrefix) iter.Seq[CountryPrefix] {
+	return func(yield func(CountryPrefix) bool) {
+		slices.SortFunc(pieces, func(a, b CountryPrefix) int {
+			return a.Prefix.Addr().Compare(b.Prefix.Addr())
+		})
+		var b netipx.IPSetBuilder
+		for _, piece := range pieces {
+			b.AddPrefix(piece.Prefix)
+		}
+
+		for _, prefix := range check1(b.IPSet()).Prefixes() {
+			// every piece lies in a single merged prefix
+			merged := pieces[0]
+			merged.Prefix = prefix
+			for len(pieces) > 0 && prefix.Contains(pieces[0].Prefix.Addr()) {
+				if pieces[0].provenance != merged.provenance {
+					merged = newCountryPrefix(merged.Country, prefix)
+				}
+				pieces = pieces[1:]
+			}
+			if !yield(merged) {
+				return
+			}
+		}
+	}
+}
+
+// aggregateByCountry merges the contiguous and overlapping prefixes of each
+// country of seq, restricted by -intersect and -exclude-file, and yields the
+// result ordered by country.
+func aggregateByCountry(seq iter.Seq[CountryPrefix]) iter.Seq[CountryPrefix] {
+	return func(yield func(CountryPrefix) bool) {
+		builders := make(map[string]*netipx.IPSetBuilder)
+		for r := range seq {
+			b, ok := builders[r.Country]
+			if !ok {
+				b = &netipx.IPSetBuilder{}
+				builders[r.Country] = b
+			}
+			b.AddPrefix(r.Prefix)
+		}
+
+		countries := make([]string, 0, len(builders))
+		for cc := range builders {
+			countries = append(countries, cc)
+		}
+		slices.Sort(countries)
+
+		for _, cc := range countries {
+			b := builders[cc]
+			restrictOutput(b)
+			for _, prefix := range check1(b.IPSet()).Prefixes() {
+				if !yield(newCountryPrefix(cc, prefix)) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// uniqueByCountry yields the first of the prefixes of seq delegated to the same
+// country by several records, e.g. transferred or ERX space listed by two
+// registries.
+func uniqueByCountry(seq iter.Seq[CountryPrefix]) iter.Seq[CountryPrefix] {
+	return func(yield func(CountryPrefix) bool) {
+		type key struct {
+			country string
+			prefix  netip.Prefix
+		}
+		seen := make(map[key]bool)
+		for r := range seq {
+			k := key{r.Country, r.Prefix}
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+			if !yield(r) {
+				return
+			}
+		}
+	}
+}
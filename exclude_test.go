@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/monoidic/rir/rir"
+)
+
+func TestAggregateByCountry(t *testing.T) {
+	defer func() { excludeSet, intersectSet = nil, nil }()
+	seq := slices.Values([]CountryPrefix{
+		newCountryPrefix("FR", netip.MustParsePrefix("2.0.0.0/13")),
+		newCountryPrefix("DE", netip.MustParsePrefix("193.18.0.0/16")),
+		newCountryPrefix("FR", netip.MustParsePrefix("2.8.0.0/13")),
+		newCountryPrefix("FR", netip.MustParsePrefix("2.8.0.0/24")),
+		newCountryPrefix("DE", netip.MustParsePrefix("193.19.0.0/19")),
+	})
+
+	for _, test := range []struct {
+		exclude, intersect string
+		want               string
+	}{
+		{"", "", "[DE 193.18.0.0/16 DE 193.19.0.0/19 FR 2.0.0.0/12]"},
+		{"2.0.0.0/13", "", "[DE 193.18.0.0/16 DE 193.19.0.0/19 FR 2.8.0.0/13]"},
+		{"", "193.0.0.0/8", "[DE 193.18.0.0/16 DE 193.19.0.0/19]"},
+		{"193.18.0.0/17", "193.18.0.0/16", "[DE 193.18.128.0/17]"},
+	} {
+		excludeSet, intersectSet = nil, nil
+		if test.exclude != "" {
+			excludeSet = prefixListSet([]netip.Prefix{netip.MustParsePrefix(test.exclude)})
+		}
+		if test.intersect != "" {
+			intersectSet = prefixListSet([]netip.Prefix{netip.MustParsePrefix(test.intersect)})
+		}
+		var got []string
+		for r := range aggregateByCountry(seq) {
+			got = append(got, r.Country+" "+r.Prefix.String())
+		}
+		if fmt.Sprint(got) != test.want {
+			t.Errorf("aggregateByCountry (exclude %q, intersect %q): got %v, want %s", test.exclude, test.intersect, got, test.want)
+		}
+	}
+}
+
+func TestLoadExcludeFile(t *testing.T) {
+	defer func() { excludeSet = nil }()
+	dir := t.TempDir()
+	for i, content := range []string{"2.0.0.0/13\n", "# partners\n193.18.0.0/16\n"} {
+		path := filepath.Join(dir, fmt.Sprint(i))
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := loadExcludeFile(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := fmt.Sprint(excludeSet.Prefixes()); got != "[2.0.0.0/13 193.18.0.0/16]" {
+		t.Errorf("excluded prefixes: got %s", got)
+	}
+}
+
+func TestUniqueByCountry(t *testing.T) {
+	seq := slices.Values([]CountryPrefix{
+		newCountryPrefix("US", netip.MustParsePrefix("192.0.2.0/24")),
+		newCountryPrefix("US", netip.MustParsePrefix("198.51.100.0/24")),
+		newCountryPrefix("US", netip.MustParsePrefix("192.0.2.0/24")),
+		newCountryPrefix("CA", netip.MustParsePrefix("192.0.2.0/24")),
+	})
+	var got []string
+	for r := range uniqueByCountry(seq) {
+		got = append(got, r.Country+" "+r.Prefix.String())
+	}
+	if want := "[US 192.0.2.0/24 US 198.51.100.0/24 CA 192.0.2.0/24]"; fmt.Sprint(got) != want {
+		t.Errorf("got %v, want %s", got, want)
+	}
+}
+
+func TestExcludeProvenance(t *testing.T) {
+	defer func() { excludeSet, intersectSet = nil, nil }()
+	excludeSet = prefixListSet([]netip.Prefix{netip.MustParsePrefix("2.0.0.0/13")})
+	records := rir.Records{Source: "/cache/ripencc/latest", Serial: "20240101"}
+	fr := rir.IpRecord{Record: rir.Record{Registry: "ripencc", Cc: "FR", Line: 7}}
+	de := rir.IpRecord{Record: rir.Record{Registry: "ripencc", Cc: "DE", Line: 9}}
+	seq := slices.Values([]CountryPrefix{
+		recordPrefix(records, fr, netip.MustParsePrefix("2.0.0.0/12")),
+		recordPrefix(records, de, netip.MustParsePrefix("193.18.0.0/16")),
+	})
+
+	var got []string
+	for r := range excludeByCountry(seq) {
+		got = append(got, fmt.Sprint(withProvenance(r.Prefix, r)))
+	}
+	want := []string{
+		"2.8.0.0/13\tfile=/cache/ripencc/latest\tserial=20240101\tline=7",
+		"193.18.0.0/16\tfile=/cache/ripencc/latest\tserial=20240101\tline=9",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// TestExcludeMerge checks that the remainder of a country is re-aggregated,
+// keeping the provenance of pieces of a single delegation only.
+func TestExcludeMerge(t *testing.T) {
+	defer func() { excludeSet = nil }()
+	excludeSet = prefixListSet([]netip.Prefix{netip.MustParsePrefix("2.0.3.0/24")})
+	records := rir.Records{Source: "/cache/ripencc/latest", Serial: "20240101"}
+	var seq []CountryPrefix
+	for i, prefix := range []string{"2.0.2.0/23", "2.0.1.0/24", "2.0.0.0/24"} {
+		ip := rir.IpRecord{Record: rir.Record{Registry: "ripencc", Cc: "FR", Line: 7 + i}}
+		seq = append(seq, recordPrefix(records, ip, netip.MustParsePrefix(prefix)))
+	}
+
+	var got []string
+	for r := range excludeByCountry(slices.Values(seq)) {
+		got = append(got, fmt.Sprint(withProvenance(r.Prefix, r)))
+	}
+	want := []string{
+		"2.0.0.0/23",
+		"2.0.2.0/24\tfile=/cache/ripencc/latest\tserial=20240101\tline=7",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
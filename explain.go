@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/monoidic/rir/rir"
+)
+
+// sourceFiles caches the registry files read for -explain, which usually
+// quotes several lines of the same file.
+var sourceFiles = map[string][][]byte{}
+
+// sourceLine returns line n, counted from 1, of a registry file.
+func sourceLine(path string, n int) (string, error) {
+	lines, ok := sourceFiles[path]
+	if !ok {
+		content, err := rir.ReadSource(path)
+		if err != nil {
+			return "", err
+		}
+		lines = bytes.Split(content, []byte("\n"))
+		sourceFiles[path] = lines
+	}
+	if n < 1 || n > len(lines) {
+		return "", fmt.Errorf("%s has no line %d", path, n)
+	}
+	return strings.TrimSuffix(string(lines[n-1]), "\r"), nil
+}
+
+// delegatedLine formats a record as a line of a delegated file, for records
+// whose file is not at hand.
+func delegatedLine(r rir.IpRecord) string {
+	fields := []string{r.Registry, r.Cc, r.Type, r.Start.String(), fmt.Sprint(r.Value), r.Date, r.Status}
+	if r.OpaqueId != "" {
+		fields = append(fields, r.OpaqueId)
+	}
+	return strings.Join(fields, "|")
+}
+
+// explained annotates a lookup result with the delegation it comes from and
+// its line in the registry file.
+func explained(result any, cp CountryPrefix) Annotated {
+	r := cp.record
+	a := annotate(result, "registry", r.Registry)
+	a = annotate(a, "date", r.Date)
+	a = annotate(a, "status", r.Status)
+	a = annotate(a, "opaque-id", r.OpaqueId)
+
+	line, err := sourceLine(cp.provenance.File, cp.provenance.Line)
+	if cp.provenance.File == "" || err != nil || !strings.HasPrefix(line, r.Registry+"|") {
+		line = delegatedLine(r)
+	}
+	return annotate(a, "record", line)
+}
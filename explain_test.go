@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/monoidic/rir/rir"
+)
+
+func TestExplained(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "latest")
+	// extra fields of the file line only show up when it is quoted
+	content := strings.Replace(holderData, "512|20050101|assigned|b8f0a8c3", "512|20050101|assigned|b8f0a8c3|e-stats", 1)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	records, err := rir.NewReader(strings.NewReader(holderData)).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	addr := netip.MustParseAddr("194.146.25.1")
+	want := "FR\t194.146.24.0/23\tregistry=ripencc\tdate=20050101\tstatus=assigned\topaque-id=b8f0a8c3\trecord=ripencc|FR|ipv4|194.146.24.0|512|20050101|assigned|b8f0a8c3"
+
+	// read from the file, then rebuilt from the record without one
+	for _, test := range []struct{ source, want string }{
+		{path, want + "|e-stats"},
+		{"", want},
+	} {
+		records.Source = test.source
+		q := Query{regions: []rir.Records{records}}
+		var got []string
+		for cp := range q.matchOnIp(ctx, addr) {
+			got = append(got, explained(cp, cp).String())
+		}
+		if len(got) != 1 || got[0] != test.want {
+			t.Errorf("explained (source %q): got %q, want %q", test.source, got, test.want)
+		}
+	}
+}
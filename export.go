@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+// An exporter renders a list of prefixes in a format consumed by firewalls or
+// routers. name identifies the generated set or list.
+type exporter func(w io.Writer, name string, prefixes []netip.Prefix) error
+
+var exporters = map[string]exporter{
+	"plain":       exportPlain,
+	"nftables":    exportNftables,
+	"ipset":       exportIpset,
+	"prefix-list": exportPrefixList,
+	"zmap":        exportZmap,
+	"masscan":     exportMasscan,
+}
+
+func exporterNames() string {
+	names := make([]string, 0, len(exporters))
+	for name := range exporters {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return strings.Join(names, ", ")
+}
+
+func splitFamilies(prefixes []netip.Prefix) (v4, v6 []netip.Prefix) {
+	for _, prefix := range prefixes {
+		if prefix.Addr().Is4() {
+			v4 = append(v4, prefix)
+		} else {
+			v6 = append(v6, prefix)
+		}
+	}
+	return v4, v6
+}
+
+func exportPlain(w io.Writer, name string, prefixes []netip.Prefix) error {
+	for _, prefix := range prefixes {
+		if _, err := fmt.Fprintln(w, prefix); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func exportNftables(w io.Writer, name string, prefixes []netip.Prefix) error {
+	v4, v6 := splitFamilies(prefixes)
+	for _, family := range []struct {
+		suffix, addrType string
+		prefixes         []netip.Prefix
+	}{{"v4", "ipv4_addr", v4}, {"v6", "ipv6_addr", v6}} {
+		if len(family.prefixes) == 0 {
+			continue
+		}
+		elements := make([]string, len(family.prefixes))
+		for i, prefix := range family.prefixes {
+			elements[i] = prefix.String()
+		}
+		_, err := fmt.Fprintf(w, "set %s_%s {\n\ttype %s\n\tflags interval\n\telements = {\n\t\t%s\n\t}\n}\n",
+			name, family.suffix, family.addrType, strings.Join(elements, ",\n\t\t"))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func exportIpset(w io.Writer, name string, prefixes []netip.Prefix) error {
+	v4, v6 := splitFamilies(prefixes)
+	for _, family := range []struct {
+		suffix, inet string
+		prefixes     []netip.Prefix
+	}{{"v4", "inet", v4}, {"v6", "inet6", v6}} {
+		if len(family.prefixes) == 0 {
+			continue
+		}
+		set := name + "_" + family.suffix
+		if _, err := fmt.Fprintf(w, "create %s hash:net family %s -exist\n", set, family.inet); err != nil {
+			return err
+		}
+		for _, prefix := range family.prefixes {
+			if _, err := fmt.Fprintf(w, "add %s %s -exist\n", set, prefix); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func exportPrefixList(w io.Writer, name string, prefixes []netip.Prefix) error {
+	v4, v6 := splitFamilies(prefixes)
+	for _, family := range []struct {
+		keyword  string
+		prefixes []netip.Prefix
+	}{{"ip", v4}, {"ipv6", v6}} {
+		for i, prefix := range family.prefixes {
+			if _, err := fmt.Fprintf(w, "%s prefix-list %s seq %d permit %s\n", family.keyword, name, (i+1)*5, prefix); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/monoidic/rir/rir"
+)
+
+// delegationChange is a delegation that appeared or disappeared between two
+// serials of a registry file.
+type delegationChange struct {
+	Country  string
+	Resource string
+}
+
+// registryChanges are the delegations added and removed by the latest serial
+// of a registry file.
+type registryChanges struct {
+	Registry       string
+	Serial         string
+	PreviousSerial string
+	Updated        time.Time
+	Added          []delegationChange
+	Removed        []delegationChange
+}
+
+// delegatedResources lists the delegated prefixes and AS numbers of records.
+func delegatedResources(records rir.Records) map[delegationChange]bool {
+	resources := make(map[delegationChange]bool)
+	for _, ip := range records.Ips {
+		if !isDelegated(ip.Record) {
+			continue
+		}
+		for prefix, err := range ip.Prefixes() {
+			if err == nil {
+				resources[delegationChange{ip.Cc, prefix.String()}] = true
+			}
+		}
+	}
+	for _, asn := range records.Asns {
+		if !isDelegated(asn.Record) {
+			continue
+		}
+		resource := fmt.Sprintf("AS%d", asn.Start)
+		if asn.Value > 1 {
+			resource += fmt.Sprintf("-AS%d", asn.Start+asn.Value-1)
+		}
+		resources[delegationChange{asn.Cc, resource}] = true
+	}
+	return resources
+}
+
+// compareSerials returns the delegations added and removed between two
+// serials of a registry file.
+func compareSerials(previous, current rir.Records) registryChanges {
+	changes := registryChanges{Registry: current.Registry, Serial: current.Serial, PreviousSerial: previous.Serial}
+	before, after := delegatedResources(previous), delegatedResources(current)
+	for resource := range after {
+		if !before[resource] {
+			changes.Added = append(changes.Added, resource)
+		}
+	}
+	for resource := range before {
+		if !after[resource] {
+			changes.Removed = append(changes.Removed, resource)
+		}
+	}
+	for _, list := range [][]delegationChange{changes.Added, changes.Removed} {
+		slices.SortFunc(list, func(a, b delegationChange) int { return strings.Compare(a.Resource, b.Resource) })
+	}
+	return changes
+}
+
+// latestChanges compares the records of a registry with the retained file of
+// its previous serial, if any.
+func latestChanges(current rir.Records) (registryChanges, bool) {
+	p, ok := rir.FindProvider(current.Registry)
+	if !ok {
+		return registryChanges{}, false
+	}
+	files := historyFiles(p)
+	for i := len(files) - 1; i >= 0; i-- {
+		if files[i].Version.Serial == current.Serial {
+			continue
+		}
+		changes := compareSerials(files[i].records(), current)
+		// the end date of the file, or when it was first seen
+		changes.Updated = time.Now().UTC()
+		if j := serialIndex(files, current.Serial); current.Serial != "" && j >= 0 && !files[j].Version.EndTime.IsZero() {
+			changes.Updated = files[j].Version.EndTime
+		}
+		return changes, true
+	}
+	log.Printf("No previous %s file, its changes are not in the feeds", current.Registry)
+	return registryChanges{}, false
+}
+
+// countryFeedEntry is the summary of the changes of a registry for a country.
+type countryFeedEntry struct {
+	id, title, content string
+	updated            time.Time
+}
+
+func countryFeedEntries(changes []registryChanges, cc string) []countryFeedEntry {
+	var entries []countryFeedEntry
+	for _, c := range changes {
+		var content strings.Builder
+		var added, removed int
+		for _, op := range []struct {
+			sign    string
+			count   *int
+			changes []delegationChange
+		}{{"+", &added, c.Added}, {"-", &removed, c.Removed}} {
+			for _, change := range op.changes {
+				if change.Country == cc {
+					*op.count++
+					fmt.Fprintf(&content, "%s%s\n", op.sign, change.Resource)
+				}
+			}
+		}
+		if added == 0 && removed == 0 {
+			continue
+		}
+		entries = append(entries, countryFeedEntry{
+			id:      fmt.Sprintf("urn:rir:%s:%s:%s", c.Registry, c.Serial, cc),
+			title:   fmt.Sprintf("%s %s: %d new and %d removed delegations since serial %s", c.Registry, c.Serial, added, removed, c.PreviousSerial),
+			content: content.String(),
+			updated: c.Updated,
+		})
+	}
+	return entries
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	ID      string `xml:"id"`
+	Title   string `xml:"title"`
+	Updated string `xml:"updated"`
+	Content string `xml:"content"`
+}
+
+type rssFeed struct {
+	XMLName     xml.Name  `xml:"rss"`
+	Version     string    `xml:"version,attr"`
+	Title       string    `xml:"channel>title"`
+	Link        string    `xml:"channel>link"`
+	Description string    `xml:"channel>description"`
+	Items       []rssItem `xml:"channel>item"`
+}
+
+type rssItem struct {
+	GUID        rssGUID `xml:"guid"`
+	Title       string  `xml:"title"`
+	PubDate     string  `xml:"pubDate,omitempty"`
+	Description string  `xml:"description"`
+}
+
+type rssGUID struct {
+	ID          string `xml:",chardata"`
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+}
+
+// writeCountryFeed answers with the Atom or RSS feed of the delegation
+// changes of a country.
+func (s *server) writeCountryFeed(w http.ResponseWriter, r *http.Request, format string) {
+	cc := strings.ToUpper(r.PathValue("cc"))
+	if len(cc) != 2 {
+		writeError(w, http.StatusBadRequest, "invalid country code")
+		return
+	}
+	entries := countryFeedEntries(s.changes, cc)
+	title := fmt.Sprintf("Delegation changes of %s", cc)
+
+	var feed any
+	contentType := "application/atom+xml"
+	switch format {
+	case "atom":
+		atom := atomFeed{ID: "urn:rir:" + cc, Title: title}
+		var updated time.Time
+		for _, e := range entries {
+			atom.Entries = append(atom.Entries, atomEntry{ID: e.id, Title: e.title, Updated: e.updated.Format(time.RFC3339), Content: e.content})
+			if e.updated.After(updated) {
+				updated = e.updated
+			}
+		}
+		if updated.IsZero() {
+			updated = time.Now().UTC()
+		}
+		atom.Updated = updated.Format(time.RFC3339)
+		feed = atom
+	case "rss":
+		rss := rssFeed{Version: "2.0", Title: title, Link: r.URL.String(), Description: title}
+		for _, e := range entries {
+			item := rssItem{GUID: rssGUID{ID: e.id}, Title: e.title, Description: e.content}
+			if !e.updated.IsZero() {
+				item.PubDate = e.updated.Format(time.RFC1123Z)
+			}
+			rss.Items = append(rss.Items, item)
+		}
+		feed = rss
+		contentType = "application/rss+xml"
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Write([]byte(xml.Header))
+	if err := xml.NewEncoder(w).Encode(feed); err != nil {
+		log.Printf("Writing response: %v", err)
+	}
+}
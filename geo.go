@@ -0,0 +1,330 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/monoidic/rir/rir"
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// A GeoSource maps an address to a country. The registry data is one source,
+// enrichment sources such as geofeeds, MMDB databases and RDAP are others.
+type GeoSource interface {
+	Name() string
+	// Kind tells whether the country is where the holder registered the
+	// resource or where the addresses are actually used.
+	Kind() CountryKind
+	// Country returns an empty string when the source knows nothing about
+	// the address.
+	Country(ctx context.Context, addr netip.Addr) (string, error)
+}
+
+// CountryKind separates the country a resource is registered in, which is
+// what delegated statistics and whois data hold, from the operational
+// country derived from geolocation data. The two often differ.
+type CountryKind string
+
+const (
+	Registration CountryKind = "registration"
+	Operational  CountryKind = "operational"
+)
+
+type registrySource struct {
+	once  sync.Once
+	table *prefixTable[delegation]
+}
+
+func (s *registrySource) Name() string {
+	return "registry"
+}
+
+func (s *registrySource) Kind() CountryKind {
+	return Registration
+}
+
+func (s *registrySource) Country(ctx context.Context, addr netip.Addr) (string, error) {
+	s.once.Do(func() {
+		s.table = loadPrefixTable(ctx)
+	})
+	d, ok := s.table.covering(netip.PrefixFrom(addr, addr.BitLen()))
+	if !ok {
+		return "", nil
+	}
+	return d.Record.Cc, nil
+}
+
+// geofeedSource reads an RFC 8805 self-published geofeed CSV file.
+type geofeedSource struct {
+	table *prefixTable[string]
+}
+
+func newGeofeedSource(path string) *geofeedSource {
+	f := check1(os.Open(path))
+	defer f.Close()
+
+	table := newPrefixTable[string]()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, ",")
+		if len(fields) < 2 {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(strings.TrimSpace(fields[0]))
+		if err != nil {
+			continue
+		}
+		table.add(prefix, strings.ToUpper(strings.TrimSpace(fields[1])))
+	}
+	check(s.Err())
+
+	return &geofeedSource{table: table}
+}
+
+func (s *geofeedSource) Name() string {
+	return "geofeed"
+}
+
+func (s *geofeedSource) Kind() CountryKind {
+	return Operational
+}
+
+func (s *geofeedSource) Country(ctx context.Context, addr netip.Addr) (string, error) {
+	cc, _ := s.table.covering(netip.PrefixFrom(addr, addr.BitLen()))
+	return cc, nil
+}
+
+// mmdbSource looks addresses up in a MaxMind format database.
+type mmdbSource struct {
+	db *maxminddb.Reader
+}
+
+func newMmdbSource(path string) *mmdbSource {
+	return &mmdbSource{db: check1(maxminddb.Open(path))}
+}
+
+func (s *mmdbSource) Name() string {
+	return "mmdb"
+}
+
+func (s *mmdbSource) Kind() CountryKind {
+	return Operational
+}
+
+func (s *mmdbSource) Country(ctx context.Context, addr netip.Addr) (string, error) {
+	var record struct {
+		Country struct {
+			IsoCode string `maxminddb:"iso_code"`
+		} `maxminddb:"country"`
+	}
+	if err := s.db.Lookup(net.IP(addr.AsSlice()), &record); err != nil {
+		return "", err
+	}
+	return record.Country.IsoCode, nil
+}
+
+// rdapSource asks an RDAP server, rdap.org by default which redirects to the
+// authoritative registry.
+type rdapSource struct {
+	baseURL string
+	client  *http.Client
+}
+
+func newRdapSource(baseURL string) *rdapSource {
+	return &rdapSource{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		client:  &http.Client{Timeout: 20 * time.Second},
+	}
+}
+
+func (s *rdapSource) Name() string {
+	return "rdap"
+}
+
+type rdapNetwork struct {
+	Country string `json:"country"`
+}
+
+// RDAP holds the country of the registered holder, not of the addresses.
+func (s *rdapSource) Kind() CountryKind {
+	return Registration
+}
+
+func (s *rdapSource) Country(ctx context.Context, addr netip.Addr) (string, error) {
+	var network rdapNetwork
+	if err := s.get(ctx, addr, &network); err != nil {
+		return "", err
+	}
+	return strings.ToUpper(network.Country), nil
+}
+
+func (s *rdapSource) get(ctx context.Context, addr netip.Addr, v any) error {
+	url := s.baseURL + "/ip/" + addr.String()
+	release, err := rir.Acquire(ctx, s.Name(), url)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/rdap+json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("RDAP call returned %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// GeoAnswer is the country given by a source, or the error it failed with.
+type GeoAnswer struct {
+	Source  string
+	Kind    CountryKind
+	Country string
+	Err     error
+}
+
+func askSources(ctx context.Context, sources []GeoSource, addr netip.Addr) []GeoAnswer {
+	answers := make([]GeoAnswer, len(sources))
+	var wg sync.WaitGroup
+	for i, source := range sources {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cc, err := source.Country(ctx, addr)
+			answers[i] = GeoAnswer{Source: source.Name(), Kind: source.Kind(), Country: cc, Err: err}
+		}()
+	}
+	wg.Wait()
+	return answers
+}
+
+// consensusVerdict summarises how much the sources agree.
+func consensusVerdict(answers []GeoAnswer) string {
+	votes := make(map[string]int)
+	answered := 0
+	for _, answer := range answers {
+		if answer.Err == nil && answer.Country != "" {
+			votes[answer.Country]++
+			answered++
+		}
+	}
+
+	switch {
+	case answered == 0:
+		return "unknown"
+	case answered == 1:
+		for cc := range votes {
+			return fmt.Sprintf("single source %s (low confidence)", cc)
+		}
+	case len(votes) == 1:
+		for cc := range votes {
+			return fmt.Sprintf("agree %s (high confidence)", cc)
+		}
+	}
+
+	countries := make([]string, 0, len(votes))
+	for cc := range votes {
+		countries = append(countries, cc)
+	}
+	slices.SortFunc(countries, func(a, b string) int {
+		if votes[a] != votes[b] {
+			return votes[b] - votes[a]
+		}
+		return strings.Compare(a, b)
+	})
+
+	if best := countries[0]; votes[best] > answered/2 {
+		return fmt.Sprintf("majority %s %d/%d (medium confidence)", best, votes[best], answered)
+	}
+	return fmt.Sprintf("disagree %s (no confidence)", strings.Join(countries, ","))
+}
+
+func (a GeoAnswer) MarshalJSON() ([]byte, error) {
+	v := struct {
+		Source  string      `json:"source"`
+		Kind    CountryKind `json:"kind"`
+		Country string      `json:"country,omitempty"`
+		Error   string      `json:"error,omitempty"`
+	}{Source: a.Source, Kind: a.Kind, Country: a.Country}
+	if a.Err != nil {
+		v.Error = a.Err.Error()
+	}
+	return json.Marshal(v)
+}
+
+func printConsensus(ctx context.Context, sources []GeoSource, addr netip.Addr) {
+	answers := askSources(ctx, sources, addr)
+	if outputFormat == "json" {
+		emit(struct {
+			Address netip.Addr  `json:"address"`
+			Answers []GeoAnswer `json:"answers"`
+			Verdict string      `json:"verdict"`
+		}{addr, answers, consensusVerdict(answers)})
+		return
+	}
+
+	for _, answer := range answers {
+		switch {
+		case answer.Err != nil:
+			emit(tsvLine(answer.Kind, answer.Source, fmt.Sprintf("error: %v", answer.Err)))
+		case answer.Country == "":
+			emit(tsvLine(answer.Kind, answer.Source, "-"))
+		default:
+			emit(tsvLine(answer.Kind, answer.Source, answer.Country))
+		}
+	}
+	emit("verdict\t" + consensusVerdict(answers))
+}
+
+// LabeledResult is a registry match along with the operational country of the
+// queried address, if known.
+type LabeledResult struct {
+	Registration      string       `json:"registration"`
+	Prefix            netip.Prefix `json:"prefix"`
+	Operational       string       `json:"operational"`
+	OperationalSource string       `json:"operational_source,omitempty"`
+}
+
+func (r LabeledResult) String() string {
+	if r.Operational == "" {
+		return tsvLine("registration="+r.Registration, r.Prefix, "operational=unknown")
+	}
+	return tsvLine("registration="+r.Registration, r.Prefix, "operational="+r.Operational, "operational_source="+r.OperationalSource)
+}
+
+// operationalCountry returns the answer of the first operational source that
+// knows the address.
+func operationalCountry(ctx context.Context, sources []GeoSource, addr netip.Addr) (GeoAnswer, bool) {
+	for _, source := range sources {
+		if source.Kind() != Operational {
+			continue
+		}
+		if cc, err := source.Country(ctx, addr); err == nil && cc != "" {
+			return GeoAnswer{Source: source.Name(), Kind: Operational, Country: cc}, true
+		}
+	}
+	return GeoAnswer{}, false
+}
@@ -1,3 +1,12 @@
 module github.com/monoidic/rir
 
-go 1.22.2
+go 1.23.0
+
+require (
+	github.com/klauspost/compress v1.18.0
+	github.com/oschwald/maxminddb-golang v1.13.1
+	go4.org/netipx v0.0.0-20231129151722-fdeea329fbba
+	golang.org/x/text v0.28.0
+)
+
+require golang.org/x/sys v0.21.0 // indirect
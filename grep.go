@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+
+	"go4.org/netipx"
+)
+
+// grepLine reports whether line holds an address of set.
+func grepLine(set *netipx.IPSet, line string) bool {
+	for addr := range lineAddrs(line) {
+		if set.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// grepCommand filters text, such as logs, keeping the lines holding an
+// address delegated to one of the given countries, or with -v the others.
+// It exits with status 1 when no line is kept.
+func grepCommand(ctx context.Context, args []string) {
+	fset := flag.NewFlagSet("grep", flag.ExitOnError)
+	countries := fset.String("c", "", "comma separated 2 letters strings of the countries (ISO 3166)")
+	invert := fset.Bool("v", false, "keep the lines without any address of the countries instead")
+	check(fset.Parse(args))
+
+	if *countries == "" || fset.NArg() > 1 {
+		usageError("usage: rir grep -c CC[,CC...] [-v] [file] (stdin by default)")
+	}
+	path := "-"
+	if fset.NArg() == 1 {
+		path = fset.Arg(0)
+	}
+
+	// the space of the countries is merged into sorted ranges, searched in
+	// logarithmic time
+	var b netipx.IPSetBuilder
+	b.AddSet(countrySet(ctx, countryList(*countries)...))
+	set := subtractExcluded(check1(b.IPSet()))
+
+	f := openInput(path)
+	defer f.Close()
+	var selected bool
+	check(copyLines(f, os.Stdout, func(line string) (string, bool) {
+		keep := grepLine(set, line) != *invert
+		selected = selected || keep
+		return line, keep
+	}))
+	// as grep, fail when no line is selected
+	if !selected {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"net/netip"
+	"testing"
+
+	"go4.org/netipx"
+)
+
+func TestGrepLine(t *testing.T) {
+	var b netipx.IPSetBuilder
+	b.AddPrefix(netip.MustParsePrefix("2.0.0.0/12"))
+	b.AddPrefix(netip.MustParsePrefix("2001:660::/32"))
+	set, err := b.IPSet()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		line string
+		want bool
+	}{
+		{"GET / from 8.8.8.8 via 2.3.4.5", true},
+		{"[2001:660::1]:443 closed", true},
+		{"::ffff:2.0.0.1 mapped", true},
+		{"8.8.8.8 only", false},
+		{"version 2.3.4.5.6", false},
+		{"no address at 12:30:45", false},
+	} {
+		if got := grepLine(set, test.line); got != test.want {
+			t.Errorf("grepLine(%q): got %v, want %v", test.line, got, test.want)
+		}
+	}
+}
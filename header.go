@@ -0,0 +1,96 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/monoidic/rir/rir"
+)
+
+// sourceInfo describes a registry file an artifact was generated from.
+type sourceInfo struct {
+	Registry string
+	Serial   string
+	URL      string
+	// Fetched is when the file was downloaded, zero if unknown
+	Fetched time.Time
+}
+
+func newSourceInfo(records rir.Records) sourceInfo {
+	source := sourceInfo{Registry: records.Registry, Serial: records.Serial, URL: bootstrapURL}
+	for _, p := range rir.AllProviders {
+		if p.Name() == records.Registry && source.URL == "" {
+			source.URL = p.URL()
+		}
+	}
+	if info, err := rir.StatSource(records.Source); err == nil {
+		source.Fetched = info.ModTime().UTC()
+	}
+	return source
+}
+
+var (
+	loadedMu sync.Mutex
+	// loadedSources are the registry files loaded by the command, by registry
+	loadedSources = make(map[string]sourceInfo)
+)
+
+func noteLoaded(records rir.Records) {
+	loadedMu.Lock()
+	defer loadedMu.Unlock()
+	loadedSources[records.Registry] = newSourceInfo(records)
+}
+
+func loaded() []sourceInfo {
+	loadedMu.Lock()
+	defer loadedMu.Unlock()
+	sources := make([]sourceInfo, 0, len(loadedSources))
+	for _, source := range loadedSources {
+		sources = append(sources, source)
+	}
+	return sources
+}
+
+// generatorVersion is the module version of the running binary.
+func generatorVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "(devel)"
+}
+
+// commentPrefixes start comment lines in the artifact formats not using #.
+var commentPrefixes = map[string]string{
+	"prefix-list": "!",
+	"zone":        ";",
+}
+
+// artifactHeader renders comment lines telling which registry files and
+// version of rir an artifact of format was generated from, so that it can be
+// traced back when audited. The header only depends on its sources so that
+// regenerating unchanged data gives identical artifacts.
+func artifactHeader(format string, sources []sourceInfo) []byte {
+	comment := cmp.Or(commentPrefixes[format], "#")
+	slices.SortFunc(sources, func(a, b sourceInfo) int {
+		return strings.Compare(a.Registry, b.Registry)
+	})
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s generated by rir %s\n", comment, generatorVersion())
+	for _, source := range sources {
+		fmt.Fprintf(&b, "%s source %s serial %s", comment, source.Registry, source.Serial)
+		if !source.Fetched.IsZero() {
+			fmt.Fprintf(&b, " fetched %s", source.Fetched.Format(time.RFC3339))
+		}
+		if source.URL != "" {
+			fmt.Fprintf(&b, " from %s", source.URL)
+		}
+		b.WriteString("\n")
+	}
+	return []byte(b.String())
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"flag"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/monoidic/rir/rir"
+)
+
+// snapshotFile is a retained registry file along with its header.
+type snapshotFile struct {
+	Provider rir.CachedProvider
+	Key      string
+	Path     string
+	Version  rir.Version
+}
+
+// historyFiles lists every retained file of the provider, oldest first, the
+// latest file included unless it is already retained as a snapshot.
+func historyFiles(p rir.CachedProvider) []snapshotFile {
+	var files []snapshotFile
+	seen := make(map[string]bool)
+
+	serials, _ := p.Snapshots()
+	slices.Reverse(serials)
+	keys := make([]string, 0, len(serials)+1)
+	for _, serial := range serials {
+		keys = append(keys, p.SnapshotKey(serial))
+	}
+	keys = append(keys, p.LatestKey())
+
+	for _, key := range keys {
+		content, err := rir.ReadStored(key)
+		if err != nil {
+			continue
+		}
+		version, err := rir.ReadVersion(bytes.NewReader(content))
+		if err != nil || version.Serial == "" || seen[version.Serial] {
+			continue
+		}
+		seen[version.Serial] = true
+		files = append(files, snapshotFile{Provider: p, Key: key, Path: rir.Store.Location(key), Version: version})
+	}
+
+	return files
+}
+
+func (f snapshotFile) records() rir.Records {
+	content := check1(rir.ReadStored(f.Key))
+	records := applyOverlay(check1(rir.NewLimitedReader(bytes.NewReader(content), rir.ReaderLimits).Read()))
+	records.Source = f.Path
+	return records
+}
+
+// historyEntry is what the registries said about a resource on one date.
+type historyEntry struct {
+	Date    string
+	Records []rir.Record
+}
+
+// resourceHistory collects, for every retained snapshot date, the records
+// matched by match across all registries. Registries publish on slightly
+// different dates, so each one contributes its most recent snapshot at or
+// before every date.
+func resourceHistory(match func(rir.Records) []rir.Record) []historyEntry {
+	type observation struct {
+		date     string
+		provider string
+		records  []rir.Record
+	}
+
+	type job struct {
+		provider string
+		file     snapshotFile
+	}
+
+	var todo []job
+	for _, provider := range rir.AllProviders {
+		for _, file := range historyFiles(provider) {
+			todo = append(todo, job{provider.Name(), file})
+		}
+	}
+
+	var observations []observation
+	for obs := range parallelMap(todo, func(j job) observation {
+		return observation{
+			date:     j.file.Version.EndDate,
+			provider: j.provider,
+			records:  match(j.file.records()),
+		}
+	}) {
+		observations = append(observations, obs)
+	}
+	slices.SortStableFunc(observations, func(a, b observation) int { return strings.Compare(a.date, b.date) })
+
+	var history []historyEntry
+	state := make(map[string][]rir.Record)
+	for i, obs := range observations {
+		state[obs.provider] = obs.records
+		if i+1 < len(observations) && observations[i+1].date == obs.date {
+			continue
+		}
+
+		entry := historyEntry{Date: obs.date}
+		for _, provider := range rir.AllProviders {
+			entry.Records = append(entry.Records, state[provider.Name()]...)
+		}
+		history = append(history, entry)
+	}
+	return history
+}
+
+// normalizeHistory replaces withdrawn country codes by current ones. The
+// successor of a split country is taken from the latest entry when possible.
+func normalizeHistory(history []historyEntry) {
+	if len(history) == 0 {
+		return
+	}
+	var current string
+	for _, r := range history[len(history)-1].Records {
+		current = cmp.Or(current, r.Cc)
+	}
+	for i := range history {
+		history[i].Records = slices.Clone(history[i].Records)
+		for j := range history[i].Records {
+			history[i].Records[j].Cc = rir.NormalizeCountry(history[i].Records[j].Cc, current)
+		}
+	}
+}
+
+func isDelegated(r rir.Record) bool {
+	return r.Status == "allocated" || r.Status == "assigned"
+}
+
+// describeChange explains the difference between two consecutive states.
+func describeChange(before, after []rir.Record, first bool) string {
+	var prev, cur *rir.Record
+	for i := range before {
+		if isDelegated(before[i]) {
+			prev = &before[i]
+		}
+	}
+	for i := range after {
+		if isDelegated(after[i]) {
+			cur = &after[i]
+		}
+	}
+
+	switch {
+	case prev == nil && cur == nil:
+		return ""
+	case prev == nil:
+		if first {
+			return ""
+		}
+		return "delegated"
+	case cur == nil:
+		return "returned"
+	case prev.Registry != cur.Registry:
+		return fmt.Sprintf("transferred from %s", prev.Registry)
+	case prev.Cc != cur.Cc:
+		return fmt.Sprintf("transferred from %s", prev.Cc)
+	case prev.OpaqueId != cur.OpaqueId:
+		return "transferred to another holder"
+	}
+	return ""
+}
+
+// HistoryRow is a record of a resource at a snapshot date, or its absence.
+type HistoryRow struct {
+	Date     string `json:"date"`
+	Registry string `json:"registry,omitempty"`
+	Country  string `json:"country,omitempty"`
+	Status   string `json:"status"`
+	OpaqueId string `json:"opaque_id,omitempty"`
+	Change   string `json:"change"`
+}
+
+func (r HistoryRow) String() string {
+	return tsvLine(r.Date, orDash(r.Registry), orDash(r.Country), r.Status, orDash(r.OpaqueId), r.Change)
+}
+
+func printHistory(history []historyEntry) {
+	var previous []rir.Record
+	for i, entry := range history {
+		change := describeChange(previous, entry.Records, i == 0)
+		if len(entry.Records) == 0 {
+			emit(HistoryRow{Date: entry.Date, Status: "absent", Change: change})
+		}
+		for _, r := range entry.Records {
+			emit(HistoryRow{entry.Date, r.Registry, r.Cc, r.Status, r.OpaqueId, change})
+		}
+		previous = entry.Records
+	}
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// historyCommand reports what the archived snapshots say about an AS number
+// or an address over time.
+func historyCommand(ctx context.Context, args []string) {
+	fset := flag.NewFlagSet("history", flag.ExitOnError)
+	asnFlag := fset.String("asn", "", "AS number, with or without the AS prefix")
+	ipFlag := fset.String("q", "", "ip address")
+	check(fset.Parse(args))
+
+	var history []historyEntry
+	switch {
+	case *asnFlag != "":
+		asn, err := parseAsn(*asnFlag)
+		if err != nil {
+			usageError("invalid AS number %q", *asnFlag)
+		}
+		history = resourceHistory(func(records rir.Records) []rir.Record {
+			if r, ok := records.Asn(asn); ok {
+				return []rir.Record{r.Record}
+			}
+			return nil
+		})
+
+	case *ipFlag != "":
+		addr := check1(netip.ParseAddr(*ipFlag))
+		history = resourceHistory(func(records rir.Records) []rir.Record {
+			var matches []rir.Record
+			for r := range records.Lookup(addr) {
+				matches = append(matches, r.Record)
+			}
+			return matches
+		})
+
+	default:
+		usageError("usage: rir history -asn AS64500 | -q address")
+	}
+
+	if normalizeCountries {
+		normalizeHistory(history)
+	}
+	printHistory(history)
+}
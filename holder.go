@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"iter"
+
+	"github.com/monoidic/rir/rir"
+)
+
+// HolderResource is a prefix or AS number range delegated to a holder.
+type HolderResource struct {
+	Registry string `json:"registry"`
+	Country  string `json:"country"`
+	Type     string `json:"type"`
+	Resource string `json:"resource"`
+	Date     string `json:"date"`
+	Status   string `json:"status"`
+}
+
+func (h HolderResource) String() string {
+	return tsvLine(h.Registry, h.Country, h.Resource, h.Date, h.Status)
+}
+
+func newHolderResource(r rir.Record, resource string) HolderResource {
+	return HolderResource{
+		Registry: r.Registry,
+		Country:  r.Cc,
+		Type:     r.Type,
+		Resource: resource,
+		Date:     r.Date,
+		Status:   r.Status,
+	}
+}
+
+// holderResources yields every prefix then every AS number range of the
+// records selected by the query, which selects the opaque ID of a holder.
+// Opaque IDs are only unique within a registry.
+func (q Query) holderResources(ctx context.Context) iter.Seq[HolderResource] {
+	return func(yield func(HolderResource) bool) {
+		for region := range q.loadedRegions(ctx) {
+			for entry := range region.Filter(q.filter) {
+				switch r := entry.(type) {
+				case rir.IpRecord:
+					for prefix, err := range r.Prefixes() {
+						if err != nil {
+							report("warning", codeInvalidRecord, &ProviderError{Provider: region.Registry, Err: err})
+							continue
+						}
+						if !yield(newHolderResource(r.Record, prefix.String())) {
+							return
+						}
+					}
+				case rir.AsnRecord:
+					asns := fmt.Sprintf("AS%d", r.Start)
+					if r.Value > 1 {
+						asns += fmt.Sprintf("-AS%d", r.Start+r.Value-1)
+					}
+					if !yield(newHolderResource(r.Record, asns)) {
+						return
+					}
+				}
+			}
+		}
+	}
+}
+
+// relatedResources yields the resources of the holder of cp other than cp
+// itself.
+func (q Query) relatedResources(ctx context.Context, cp CountryPrefix) iter.Seq[HolderResource] {
+	q.filter = rir.Filter{Registry: cp.record.Registry, OpaqueId: cp.record.OpaqueId}
+	return func(yield func(HolderResource) bool) {
+		for r := range q.holderResources(ctx) {
+			if r.Resource != cp.Prefix.String() && !yield(r) {
+				return
+			}
+		}
+	}
+}
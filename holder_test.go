@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"net/netip"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/monoidic/rir/rir"
+)
+
+const holderData = `2|ripencc|20240102|4|19830705|20240101|+0100
+ripencc|FR|ipv4|2.0.0.0|1048576|20100712|allocated|b8f0a8c3
+ripencc|FR|ipv4|194.146.24.0|512|20050101|assigned|b8f0a8c3
+ripencc|DE|ipv4|193.18.0.0|65536|19920922|assigned|c1c2c3c4
+ripencc|FR|asn|3215|2|19940101|allocated|b8f0a8c3
+`
+
+func TestRelatedResources(t *testing.T) {
+	records, err := rir.NewReader(strings.NewReader(holderData)).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := Query{regions: []rir.Records{records}}
+	ctx := context.Background()
+
+	var got []string
+	for cp := range q.matchOnIp(ctx, netip.MustParseAddr("2.1.1.1")) {
+		for r := range q.relatedResources(ctx, cp) {
+			got = append(got, r.String())
+		}
+	}
+	want := []string{
+		"ripencc\tFR\t194.146.24.0/23\t20050101\tassigned",
+		"ripencc\tFR\tAS3215-AS3216\t19940101\tallocated",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("relatedResources(2.1.1.1): got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/monoidic/rir/rir"
+)
+
+// exportHook is a shell command run after every export, set by -export-hook.
+var exportHook string
+
+// runExportHook writes a generated export to a file and runs the export hook
+// on it, so that rule reloads such as nft -f or ipset restore can be chained
+// to the generation. The hook gets the file as $1 and the export metadata as
+// RIR_EXPORT_* environment variables.
+func runExportHook(ctx context.Context, format, name string, diff bool, entries int, content []byte) {
+	if exportHook == "" {
+		return
+	}
+
+	path := filepath.Join(rir.GetCacheDir(), "exports", format, name+".out")
+	check(os.MkdirAll(filepath.Dir(path), 0o700))
+	tmp := path + ".tmp"
+	check(os.WriteFile(tmp, content, 0o600))
+	check(os.Rename(tmp, path))
+	check(signArtifact(path, content))
+
+	if err := execHook(ctx, exportHook, path, format, name, diff, entries); err != nil {
+		check(fmt.Errorf("export hook: %w", err))
+	}
+}
+
+// execHook runs a shell command on an export file, given as $1 and in the
+// RIR_EXPORT_* environment variables along with the export metadata.
+func execHook(ctx context.Context, hook, path, format, name string, diff bool, entries int) error {
+	diffValue := "0"
+	if diff {
+		diffValue = "1"
+	}
+	cmd := exec.CommandContext(ctx, "sh", "-c", hook, "sh", path)
+	cmd.Env = append(os.Environ(),
+		"RIR_EXPORT_FILE="+path,
+		"RIR_EXPORT_FORMAT="+format,
+		"RIR_EXPORT_NAME="+name,
+		fmt.Sprintf("RIR_EXPORT_ENTRIES=%d", entries),
+		"RIR_EXPORT_DIFF="+diffValue,
+	)
+	// keep stdout for the export itself
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/netip"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/monoidic/rir/rir"
+)
+
+// defaultIrrSources are public IRR dumps of route objects.
+var defaultIrrSources = []string{
+	"https://ftp.radb.net/radb/dbase/radb.db.gz",
+	"https://ftp.ripe.net/ripe/dbase/split/ripe.db.route.gz",
+	"https://ftp.ripe.net/ripe/dbase/split/ripe.db.route6.gz",
+}
+
+// RouteOrigin is a prefix an AS is registered or seen to originate.
+type RouteOrigin struct {
+	Prefix netip.Prefix
+	Origin int
+	Source string
+}
+
+// fetchIrrSource returns the path of a local copy of an IRR dump, downloading
+// it to the cache directory at most once a day.
+func fetchIrrSource(ctx context.Context, source string) string {
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		return source
+	}
+
+	dir := filepath.Join(rir.GetCacheDir(), "irr")
+	check(os.MkdirAll(dir, 0o700))
+	local := filepath.Join(dir, path.Base(source))
+
+	if info, err := os.Stat(local); err == nil && time.Since(info.ModTime()) < 24*time.Hour {
+		return local
+	}
+
+	p := rir.NewDefaultProvider("irr", source)
+	log.Printf("Fetching %s", source)
+	resp := check1(p.Get(ctx, source))
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		check(&rir.HTTPError{URL: source, StatusCode: resp.StatusCode})
+	}
+
+	tmp := check1(os.CreateTemp(dir, ".download-"))
+	defer os.Remove(tmp.Name())
+	check1(io.Copy(tmp, resp.Body))
+	check(tmp.Close())
+	check(os.Rename(tmp.Name(), local))
+
+	return local
+}
+
+func openMaybeGzip(path string) io.ReadCloser {
+	f := check1(os.Open(path))
+	if !strings.HasSuffix(path, ".gz") {
+		return f
+	}
+	zr := check1(gzip.NewReader(f))
+	return struct {
+		io.Reader
+		io.Closer
+	}{zr, f}
+}
+
+// parseAsn accepts "64500" as well as "AS64500".
+func parseAsn(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if len(s) > 2 && strings.EqualFold(s[:2], "AS") {
+		s = s[2:]
+	}
+	return strconv.Atoi(s)
+}
+
+// readRouteObjects streams the route and route6 objects of an RPSL dump.
+// CAIDA style prefix-to-AS files ("prefix<TAB>length<TAB>asn" per line) are
+// accepted as well, to use routing table data instead of registered objects.
+func readRouteObjects(r io.Reader, source string, yield func(RouteOrigin) bool) {
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1024*1024)
+
+	var route string
+	origin := -1
+	flush := func() bool {
+		defer func() { route, origin = "", -1 }()
+		if route == "" || origin < 0 {
+			return true
+		}
+		prefix, err := netip.ParsePrefix(route)
+		if err != nil {
+			return true
+		}
+		return yield(RouteOrigin{Prefix: prefix, Origin: origin, Source: source})
+	}
+
+	for s.Scan() {
+		line := s.Text()
+		if strings.TrimSpace(line) == "" {
+			if !flush() {
+				return
+			}
+			continue
+		}
+
+		if fields := strings.Split(line, "\t"); len(fields) == 3 {
+			// prefix-to-AS line, multi-origin entries are joined with _
+			bits, err := strconv.Atoi(fields[1])
+			addr, aerr := netip.ParseAddr(fields[0])
+			if err != nil || aerr != nil {
+				continue
+			}
+			for _, asn := range strings.FieldsFunc(fields[2], func(r rune) bool { return r == '_' || r == ',' }) {
+				if n, err := parseAsn(asn); err == nil {
+					if !yield(RouteOrigin{Prefix: netip.PrefixFrom(addr, bits), Origin: n, Source: source}) {
+						return
+					}
+				}
+			}
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		switch strings.ToLower(key) {
+		case "route", "route6":
+			route = strings.TrimSpace(value)
+		case "origin":
+			if n, err := parseAsn(value); err == nil {
+				origin = n
+			}
+		}
+	}
+	check(s.Err())
+	flush()
+}
+
+// originatedPrefixes returns every prefix registered for asn in the sources.
+func originatedPrefixes(ctx context.Context, asn int, sources []string) []RouteOrigin {
+	var routes []RouteOrigin
+	seen := make(map[netip.Prefix]bool)
+
+	for _, source := range sources {
+		f := openMaybeGzip(fetchIrrSource(ctx, source))
+		readRouteObjects(f, path.Base(source), func(route RouteOrigin) bool {
+			if route.Origin == asn && !seen[route.Prefix] {
+				seen[route.Prefix] = true
+				routes = append(routes, route)
+			}
+			return true
+		})
+		f.Close()
+	}
+
+	slices.SortFunc(routes, func(a, b RouteOrigin) int {
+		if c := a.Prefix.Addr().Compare(b.Prefix.Addr()); c != 0 {
+			return c
+		}
+		return a.Prefix.Bits() - b.Prefix.Bits()
+	})
+	return routes
+}
+
+// irrCommand answers "which prefixes does AS X originate" from IRR route
+// objects or a routing table dump, along with the registration country of
+// each prefix.
+func irrCommand(ctx context.Context, args []string) {
+	fset := flag.NewFlagSet("irr", flag.ExitOnError)
+	asnFlag := fset.String("asn", "", "AS number, with or without the AS prefix")
+	var sources []string
+	fset.Func("source", "IRR dump or prefix-to-AS file, local path or URL, may be repeated (default RADB and RIPE route objects)", func(s string) error {
+		sources = append(sources, s)
+		return nil
+	})
+	check(fset.Parse(args))
+
+	asn, err := parseAsn(*asnFlag)
+	if err != nil {
+		usageError("usage: rir irr -asn AS64500 [-source file-or-url ...]")
+	}
+	if len(sources) == 0 {
+		sources = defaultIrrSources
+	}
+
+	routes := originatedPrefixes(ctx, asn, sources)
+	table := loadPrefixTable(ctx)
+	for _, route := range routes {
+		cc := "-"
+		if d, ok := table.covering(route.Prefix); ok && d.Record.Cc != "" {
+			cc = d.Record.Cc
+		}
+		fmt.Println(tsvLine(route.Prefix, cc, route.Source))
+	}
+}
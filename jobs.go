@@ -0,0 +1,127 @@
+package main
+
+import (
+	"iter"
+
+	"github.com/monoidic/rir/rir"
+)
+
+// jobs is the maximum number of providers (or historical files) downloaded
+// and parsed at the same time.
+var jobs = len(rir.AllProviders)
+
+type jobResult[R any] struct {
+	value    R
+	panicked any
+}
+
+// parallelMap applies f to every item with at most jobs calls running at
+// once, yielding the results in the order of items. A panic in f is re-raised
+// when its result is reached. No call is started once the consumer stops.
+func parallelMap[T, R any](items []T, f func(T) R) iter.Seq[R] {
+	return func(yield func(R) bool) {
+		results := make([]chan jobResult[R], len(items))
+		for i := range results {
+			results[i] = make(chan jobResult[R], 1)
+		}
+
+		done := make(chan struct{})
+		defer close(done)
+
+		// start the jobs in order so that the first results, which are
+		// consumed first, are not delayed by later ones
+		sem := make(chan struct{}, max(jobs, 1))
+		go func() {
+			for i, item := range items {
+				select {
+				case sem <- struct{}{}:
+				case <-done:
+					return
+				}
+				// both may have been ready
+				select {
+				case <-done:
+					return
+				default:
+				}
+				go func() {
+					defer func() { <-sem }()
+					var result jobResult[R]
+					defer func() {
+						result.panicked = recover()
+						results[i] <- result
+					}()
+					result.value = f(item)
+				}()
+			}
+		}()
+
+		for _, ch := range results {
+			result := <-ch
+			if result.panicked != nil {
+				panic(result.panicked)
+			}
+			if !yield(result.value) {
+				return
+			}
+		}
+	}
+}
+
+// bufferedSeq runs seq ahead of its consumer, buffering up to bufsize
+// elements. A panic in seq is re-raised by the consumer once it reaches the
+// end of the elements, for recoverFatal to report it.
+func bufferedSeq[T any](seq iter.Seq[T], bufsize int) iter.Seq[T] {
+	ch := make(chan T, bufsize)
+	var done bool
+	var panicked any
+
+	go func() {
+		defer func() {
+			panicked = recover()
+			close(ch)
+		}()
+		for e := range seq {
+			if done {
+				break
+			}
+			ch <- e
+		}
+	}()
+
+	return func(yield func(T) bool) {
+		for e := range ch {
+			if !yield(e) {
+				done = true
+				return
+			}
+		}
+		done = true
+		if panicked != nil {
+			panic(panicked)
+		}
+	}
+}
+
+// bufferedSeq2 is bufferedSeq for iter.Seq2, such as the error-aware
+// iterators.
+func bufferedSeq2[K, V any](seq iter.Seq2[K, V], bufsize int) iter.Seq2[K, V] {
+	type pair struct {
+		k K
+		v V
+	}
+	pairs := bufferedSeq(func(yield func(pair) bool) {
+		for k, v := range seq {
+			if !yield(pair{k, v}) {
+				return
+			}
+		}
+	}, bufsize)
+	return func(yield func(K, V) bool) {
+		for p := range pairs {
+			if !yield(p.k, p.v) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParallelMapOrder(t *testing.T) {
+	defer func(n int) { jobs = n }(jobs)
+
+	items := []int{5, 1, 4, 2, 3}
+	for _, jobs = range []int{0, 1, 2, len(items)} {
+		var got []int
+		for v := range parallelMap(items, func(i int) int {
+			time.Sleep(time.Duration(i) * time.Millisecond)
+			return i * 10
+		}) {
+			got = append(got, v)
+		}
+		if want := []int{50, 10, 40, 20, 30}; !slices.Equal(got, want) {
+			t.Errorf("jobs=%d: got %v, want %v", jobs, got, want)
+		}
+	}
+}
+
+func TestParallelMapStop(t *testing.T) {
+	defer func(n int) { jobs = n }(jobs)
+	jobs = 1
+
+	var started atomic.Int32
+	for range parallelMap([]int{1, 2, 3, 4, 5}, func(i int) int {
+		started.Add(1)
+		time.Sleep(time.Millisecond)
+		return i
+	}) {
+		break
+	}
+	time.Sleep(20 * time.Millisecond)
+	// the second job may have been started while the first result was
+	// consumed
+	if n := started.Load(); n > 2 {
+		t.Errorf("jobs started after the consumer stopped: %d", n)
+	}
+}
+
+func TestBufferedSeqPanic(t *testing.T) {
+	seq := bufferedSeq(func(yield func(int) bool) {
+		yield(1)
+		check(errors.New("broken"))
+	}, 1)
+
+	var got []int
+	func() {
+		defer func() {
+			r := recover()
+			if c, ok := r.(checkError); !ok || c.err.Error() != "broken" {
+				t.Errorf("got panic %v, want the error of check", r)
+			}
+		}()
+		for v := range seq {
+			got = append(got, v)
+		}
+	}()
+	if !slices.Equal(got, []int{1}) {
+		t.Errorf("got %v, want [1]", got)
+	}
+}
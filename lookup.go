@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"io"
+	"iter"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/monoidic/rir/rir"
+	"go4.org/netipx"
+)
+
+// delegation is a single prefix of a delegated ip record.
+type delegation struct {
+	Prefix netip.Prefix
+	Record rir.IpRecord
+}
+
+// prefixTable answers "which entry covers this prefix" without scanning
+// every entry, by probing each prefix length present in the data.
+type prefixTable[T any] struct {
+	entries map[netip.Prefix]T
+	lengths [129]bool
+}
+
+func newPrefixTable[T any]() *prefixTable[T] {
+	return &prefixTable[T]{entries: make(map[netip.Prefix]T)}
+}
+
+func (t *prefixTable[T]) add(prefix netip.Prefix, value T) {
+	prefix = prefix.Masked()
+	t.entries[prefix] = value
+	t.lengths[prefix.Bits()] = true
+}
+
+// covering returns the most specific entry containing all of p.
+func (t *prefixTable[T]) covering(p netip.Prefix) (T, bool) {
+	p = p.Masked()
+	for bits := p.Bits(); bits >= 0; bits-- {
+		if !t.lengths[bits] {
+			continue
+		}
+		if v, ok := t.entries[netip.PrefixFrom(p.Addr(), bits).Masked()]; ok {
+			return v, true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+// loadPrefixTable builds a table from every ip record of every provider.
+func loadPrefixTable(ctx context.Context) *prefixTable[delegation] {
+	t := newPrefixTable[delegation]()
+	for region := range bufferedSeq(retrieveData(ctx), regionBuffer) {
+		for _, iprecord := range region.Ips {
+			for net, err := range iprecord.Prefixes() {
+				if err != nil {
+					report("warning", codeInvalidRecord, &ProviderError{Provider: region.Registry, Err: err})
+					continue
+				}
+				t.add(net, delegation{Prefix: net, Record: iprecord})
+			}
+		}
+	}
+	return t
+}
+
+// parsePrefixOrAddr accepts either a CIDR prefix or a bare address, which is
+// treated as a single host prefix.
+func parsePrefixOrAddr(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		return netip.ParsePrefix(s)
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// openInput opens path for reading, "-" meaning standard input.
+func openInput(path string) io.ReadCloser {
+	if path == "-" {
+		return io.NopCloser(os.Stdin)
+	}
+	return check1(os.Open(path))
+}
+
+// readPrefixList parses a file of prefixes, one per line, ignoring blank
+// lines and # comments.
+func readPrefixList(path string) []netip.Prefix {
+	f := openInput(path)
+	defer f.Close()
+
+	var prefixes []netip.Prefix
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line, _, _ := strings.Cut(s.Text(), "#")
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		prefixes = append(prefixes, check1(parsePrefixOrAddr(line)))
+	}
+	check(s.Err())
+
+	return prefixes
+}
+
+// PrefixOverlap is a delegation overlapping a queried prefix, along with the
+// portion of the queried prefix it covers.
+type PrefixOverlap struct {
+	Country    string       `json:"country"`
+	Delegation netip.Prefix `json:"delegation"`
+	Registry   string       `json:"registry"`
+	Status     string       `json:"status"`
+	Overlap    netip.Prefix `json:"overlap"`
+}
+
+func (o PrefixOverlap) String() string {
+	return tsvLine(o.Country, o.Delegation, o.Registry, o.Status, "overlap="+o.Overlap.String())
+}
+
+// matchOnPrefix yields every delegation overlapping prefix, including space
+// without a country, which is reported with the ZZ code.
+func (q Query) matchOnPrefix(ctx context.Context, prefix netip.Prefix) iter.Seq[PrefixOverlap] {
+	prefix = prefix.Masked()
+	return func(yield func(PrefixOverlap) bool) {
+		for region := range q.loadedRegions(ctx) {
+			for _, iprecord := range region.Ips {
+				for net, err := range iprecord.Prefixes() {
+					if err != nil || !net.Overlaps(prefix) {
+						continue
+					}
+					// of two overlapping prefixes, one contains the other
+					overlap := net
+					if prefix.Bits() > net.Bits() {
+						overlap = prefix
+					}
+					o := PrefixOverlap{
+						Country:    cmp.Or(iprecord.Cc, unknownCountry),
+						Delegation: net,
+						Registry:   iprecord.Registry,
+						Status:     iprecord.Status,
+						Overlap:    overlap,
+					}
+					if !yield(o) {
+						return
+					}
+				}
+			}
+		}
+	}
+}
+
+// RangeOverlap is a delegation overlapping a queried range of addresses,
+// along with the portion of the range it covers.
+type RangeOverlap struct {
+	Country    string         `json:"country"`
+	Delegation netip.Prefix   `json:"delegation"`
+	Registry   string         `json:"registry"`
+	Status     string         `json:"status"`
+	Overlap    netipx.IPRange `json:"overlap"`
+}
+
+func (o RangeOverlap) String() string {
+	return tsvLine(o.Country, o.Delegation, o.Registry, o.Status, "overlap="+o.Overlap.String())
+}
+
+// RangeGap is a portion of a queried range of addresses that no delegation
+// covers.
+type RangeGap struct {
+	Gap netipx.IPRange `json:"gap"`
+}
+
+func (g RangeGap) String() string {
+	return tsvLine("gap=" + g.Gap.String())
+}
+
+// matchOnRange returns the delegations overlapping r, ordered by address, and
+// the gaps of r they leave uncovered.
+func (q Query) matchOnRange(ctx context.Context, r netipx.IPRange) ([]RangeOverlap, []RangeGap) {
+	var overlaps []RangeOverlap
+	var covered netipx.IPSetBuilder
+	for region := range q.loadedRegions(ctx) {
+		for _, iprecord := range region.Ips {
+			for net, err := range iprecord.Prefixes() {
+				delegated := netipx.RangeOfPrefix(net)
+				if err != nil || !delegated.Overlaps(r) {
+					continue
+				}
+				from, to := r.From(), r.To()
+				if delegated.From().Compare(from) > 0 {
+					from = delegated.From()
+				}
+				if delegated.To().Compare(to) < 0 {
+					to = delegated.To()
+				}
+				overlap := netipx.IPRangeFrom(from, to)
+				covered.AddRange(overlap)
+				overlaps = append(overlaps, RangeOverlap{
+					Country:    cmp.Or(iprecord.Cc, unknownCountry),
+					Delegation: net,
+					Registry:   iprecord.Registry,
+					Status:     iprecord.Status,
+					Overlap:    overlap,
+				})
+			}
+		}
+	}
+	slices.SortFunc(overlaps, func(a, b RangeOverlap) int {
+		return cmp.Or(a.Overlap.From().Compare(b.Overlap.From()), cmp.Compare(a.Delegation.Bits(), b.Delegation.Bits()))
+	})
+
+	var uncovered netipx.IPSetBuilder
+	uncovered.AddRange(r)
+	uncovered.RemoveSet(check1(covered.IPSet()))
+	var gaps []RangeGap
+	for _, gap := range check1(uncovered.IPSet()).Ranges() {
+		gaps = append(gaps, RangeGap{gap})
+	}
+	return overlaps, gaps
+}
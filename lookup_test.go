@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"net/netip"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/monoidic/rir/rir"
+	"go4.org/netipx"
+)
+
+func TestMatchOnPrefix(t *testing.T) {
+	records, err := rir.NewReader(strings.NewReader(holderData)).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := Query{regions: []rir.Records{records}}
+
+	var got []string
+	for o := range q.matchOnPrefix(context.Background(), netip.MustParsePrefix("194.146.0.0/16")) {
+		got = append(got, o.String())
+	}
+	if want := []string{"FR\t194.146.24.0/23\tripencc\tassigned\toverlap=194.146.24.0/23"}; !slices.Equal(got, want) {
+		t.Errorf("matchOnPrefix(194.146.0.0/16): got %q, want %q", got, want)
+	}
+
+	got = nil
+	for o := range q.matchOnPrefix(context.Background(), netip.MustParsePrefix("2.1.2.0/24")) {
+		got = append(got, o.Overlap.String())
+	}
+	if want := []string{"2.1.2.0/24"}; !slices.Equal(got, want) {
+		t.Errorf("matchOnPrefix(2.1.2.0/24): got %q, want %q", got, want)
+	}
+}
+
+func TestMatchOnRange(t *testing.T) {
+	records, err := rir.NewReader(strings.NewReader(holderData)).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := Query{regions: []rir.Records{records}}
+
+	overlaps, gaps := q.matchOnRange(context.Background(), netipx.MustParseIPRange("2.15.255.0-2.16.0.10"))
+	var got []string
+	for _, o := range overlaps {
+		got = append(got, o.String())
+	}
+	for _, gap := range gaps {
+		got = append(got, gap.String())
+	}
+	want := []string{
+		"FR\t2.0.0.0/12\tripencc\tallocated\toverlap=2.15.255.0-2.15.255.255",
+		"gap=2.16.0.0-2.16.0.10",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("matchOnRange: got %q, want %q", got, want)
+	}
+}
+
+func TestBestMatch(t *testing.T) {
+	records, err := rir.NewReader(strings.NewReader(holderData)).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	nested, err := rir.NewReader(strings.NewReader("arin|DE|ipv4|2.1.0.0|65536|20200101|assigned\n")).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := Query{regions: []rir.Records{records, nested}}
+	addr := netip.MustParseAddr("2.1.1.1")
+
+	if n := len(slices.Collect(q.lookup(context.Background(), addr))); n != 2 {
+		t.Errorf("lookup(%s): got %d delegations, want 2", addr, n)
+	}
+	q.best = true
+	best := slices.Collect(q.lookup(context.Background(), addr))
+	if len(best) != 1 || best[0].Country != "DE" || best[0].Prefix.String() != "2.1.0.0/16" {
+		t.Errorf("lookup(%s) with best: got %v, want DE 2.1.0.0/16", addr, best)
+	}
+}
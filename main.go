@@ -1,191 +1,485 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"iter"
 	"log"
-	"math/big"
+	"math/rand"
 	"net/netip"
+	"os"
+	"os/signal"
+	"slices"
 	"strings"
+	"time"
+
+	"github.com/monoidic/rir/rir"
+	"go4.org/netipx"
 )
 
 func main() {
+	// deferred before parsing to also report errors of flags loading files
+	defer recoverFatal()
+
 	var (
 		all        bool
 		country    string
 		ipquery    string
+		asnquery   string
 		hostscount bool
+		asns       bool
+		consensus  bool
+		geofeed    string
+		mmdb       string
+		rdap       bool
+		rdapURL    string
+		rdns       bool
+		abuse      bool
+		provenance bool
+		explain    bool
+		aggregate  bool
+		unique     bool
+		registry   string
+		status     string
+		opaqueId   string
+		notCountry string
+		related    bool
+		best       bool
+		since      time.Time
+		until      time.Time
+		only4      bool
+		only6      bool
+		sample     int
+		seed       int64
 	)
 
 	flag.BoolVar(&all, "a", false, "print all subnets and countries in TSV")
-	flag.StringVar(&country, "c", "", "2 letters string of the country (ISO 3166)")
-	flag.StringVar(&ipquery, "q", "", "ip address to which to resolve country")
+	flag.StringVar(&country, "c", "", `2 letters string of the country (ISO 3166), or a comma separated list of them, "!CC" negating a country`)
+	flag.StringVar(&notCountry, "not-country", "", `comma separated countries whose space is left out of all the delegated space, same as -c '!CC,...'`)
+	flag.StringVar(&registry, "registry", "", "only include the records of this registry in country queries and -a")
+	flag.StringVar(&status, "status", "", "only include records of this status (allocated, assigned, reserved, available) in country queries and -a")
+	flag.StringVar(&opaqueId, "opaque-id", "", "list every prefix and AS number of the holder with this opaque ID, narrowed by the other filters")
+	flag.BoolVar(&best, "best", false, "given an ip address only print the most specific delegation containing it, warning if registries disagree")
+	flag.BoolVar(&related, "related", false, "given an ip address also print every other prefix and AS number of the holder of its prefix, by opaque ID")
+	flag.Func("since", "only include records delegated on or after this date (2006-01-02 or 20060102) in country queries and -a", dateFlag(&since))
+	flag.Func("until", "only include records delegated on or before this date (2006-01-02 or 20060102) in country queries and -a", dateFlag(&until))
+	flag.BoolVar(&only4, "4", false, "only include IPv4 prefixes in country queries, -a and allowlists")
+	flag.BoolVar(&only6, "6", false, "only include IPv6 prefixes in country queries, -a and allowlists")
+	flag.IntVar(&sample, "sample", 0, "given countries print this many addresses drawn uniformly at random from the space of each")
+	flag.Int64Var(&seed, "seed", 0, "seed of -sample, for reproducible samples (default random)")
+	flag.StringVar(&ipquery, "q", "", "ip address or hostname to which to resolve the registration country, or prefix or start-end range whose overlapping delegations to list")
+	flag.StringVar(&asnquery, "asn", "", "AS number, with or without the AS prefix, whose delegation to print")
 	flag.BoolVar(&hostscount, "n", false, "given country return possible hosts count (exclude network and broadcast addresses)")
+	flag.BoolVar(&consensus, "consensus", false, "given an ip address show the country of every configured source and whether they agree")
+	flag.StringVar(&geofeed, "geofeed", "", "RFC 8805 geofeed CSV file to use as an enrichment source")
+	flag.StringVar(&mmdb, "mmdb", "", "MaxMind format database to use as an enrichment source")
+	flag.BoolVar(&rdap, "rdap", false, "use RDAP as an enrichment source")
+	flag.StringVar(&rdapURL, "rdap-url", "https://rdap.org", "base URL of the RDAP service")
+	flag.BoolVar(&allowPartial, "allow-partial", false, fmt.Sprintf("keep going without the data of registries that cannot be fetched, exiting with status %d", exitPartial))
+	flag.BoolVar(&requireAll, "require-all", false, "fetch every registry before printing anything and fail if any is unavailable")
+	flag.DurationVar(&rir.FetchTimeout, "fetch-timeout", rir.FetchTimeout, "maximum duration of a request to a registry")
+	flag.Func("storage", `where registry files are cached: "dir" for ~/.rir, a directory, "memory", or s3://bucket/prefix (default $RIR_STORAGE, or "dir")`, setStorage)
+	flag.DurationVar(&rir.MaxStale, "max-stale", rir.MaxStale, "maximum age of cached data used when refreshing it fails, 0 to fail instead")
+	flag.Func("provider-timeout", "maximum duration of a request to one registry as provider=duration, may be repeated", parseProviderTimeout)
+	flag.StringVar(&bootstrapURL, "bootstrap", "", "load the registry data from the /snapshot.bin of a rir serve instance instead of the registry files")
+	flag.BoolVar(&normalizeCountries, "normalize-cc", false, "replace withdrawn country codes of archived files (e.g. YU, AN) by current ones")
+	flag.Func("overlay", "file of prefix and country code pairs overriding the registry country", loadOverlayFile)
+	flag.Func("exclude-file", "file of prefixes subtracted from every country and export output, may be repeated", loadExcludeFile)
+	flag.Func("exclude", "alias of -exclude-file", loadExcludeFile)
+	flag.Func("intersect", "file of prefixes every country and export output is restricted to, may be repeated", loadIntersectFile)
+	flag.BoolVar(&provenance, "provenance", false, "annotate results with the registry file, serial and line they come from")
+	flag.BoolVar(&aggregate, "aggregate", false, "merge the contiguous and overlapping prefixes of each country in country queries and -a")
+	flag.BoolVar(&unique, "unique", false, "print the country and prefix pairs listed by several registries once in country queries and -a")
+	flag.BoolVar(&explain, "explain", false, "annotate looked up addresses with the registry, date, status, opaque ID and line of their delegation")
+	flag.BoolVar(&abuse, "abuse", false, "include the abuse contact of queried addresses, looked up with RDAP and cached")
+	flag.DurationVar(&abuseTTL, "abuse-ttl", abuseTTL, "how long abuse contacts are cached")
+	flag.BoolVar(&rdns, "rdns", false, "include the PTR records of queried addresses")
+	flag.IntVar(&rdnsWorkers, "rdns-workers", rdnsWorkers, "maximum number of concurrent reverse DNS lookups")
+	flag.IntVar(&jobs, "jobs", jobs, "maximum number of registries downloaded and parsed in parallel")
+	flag.IntVar(&rir.FetchConcurrency, "fetch-concurrency", rir.FetchConcurrency, "maximum number of concurrent downloads")
+	flag.DurationVar(&rir.FetchInterval, "fetch-interval", rir.FetchInterval, "minimum delay between requests to the same server")
+	flag.IntVar(&rir.KeepSnapshots, "keep", rir.KeepSnapshots, "number of snapshots of each registry file to retain in the cache (0 to disable)")
+	flag.BoolVar(&rir.UsePrevious, "use-previous", false, "query the snapshot preceding the latest download")
+	flag.Func("manifest", "query the exact snapshots pinned by a manifest from rir snapshot create", func(path string) error {
+		pinSnapshot(path)
+		return nil
+	})
+	flag.Int64Var(&rir.AutoPrune.MaxSize, "cache-max-size", 0, "automatically prune snapshots and indexes until the registry files take this many bytes (0 to disable)")
+	flag.DurationVar(&rir.AutoPrune.MaxAge, "cache-max-age", 0, "automatically prune snapshots and indexes older than this (0 to disable)")
+	flag.IntVar(&rir.ReaderLimits.MaxLineLength, "max-line-length", rir.DefaultLimits.MaxLineLength, "maximum length of a line in a registry file (0 for no limit)")
+	flag.IntVar(&rir.ReaderLimits.MaxRecords, "max-records", rir.DefaultLimits.MaxRecords, "maximum number of records in a registry file (0 for no limit)")
+	flag.Int64Var(&rir.ReaderLimits.MaxFileSize, "max-file-size", rir.DefaultLimits.MaxFileSize, "maximum size in bytes of a registry file (0 for no limit)")
 
+	engine := flag.String("engine", "auto", "how registry files are loaded: index, stream, or auto to choose per command")
+	var maxMemory int64
+	flag.Func("max-memory", "memory budget, e.g. 256M or 64G, bounding the registry files loaded in parallel and streaming them under 1G", func(value string) (err error) {
+		maxMemory, err = parseByteSize(value)
+		return err
+	})
+	timeout := flag.Duration("timeout", 0, "abort after this duration (0 for no limit)")
+	flag.Func("format", "output format of the results: tsv, csv, json or whois", parseOutputFormat)
+	flag.Func("o", "alias of -format", parseOutputFormat)
+	flag.BoolFunc("whois-style", "print lookups as whois style key: value blocks, same as -format whois", func(string) error {
+		return parseOutputFormat("whois")
+	})
+	flag.Func("sign-key", "secret key of rir sign keygen signing the artifacts written to files (exports, snapshot manifests) as file.minisig", loadArtifactKey)
+	flag.StringVar(&diffAgainst, "diff-against", "", `only write the changes of exports (allowlist, targets, run, daemon jobs with "diff") relative to "previous", the last generated export, or to a file of prefixes`)
+	flag.StringVar(&exportHook, "export-hook", "", "shell command run after every export with the export file as $1 and RIR_EXPORT_* variables, e.g. 'nft -f \"$1\"'")
+	flag.Func("progress", "report progress events (downloads, parsing, exports) on stderr as json", parseProgress)
+	flag.Func("names", "print country names localized to this language (e.g. en, fr, pt-BR)", parseNamesLanguage)
+
+	flag.Usage = usage
+	if spec := os.Getenv("RIR_STORAGE"); spec != "" {
+		check(setStorage(spec))
+	}
 	flag.Parse()
 
+	setupOutput()
+
+	if allowPartial && requireAll {
+		usageError("-allow-partial and -require-all are mutually exclusive")
+	}
+	switch {
+	case only4 && only6:
+		usageError("-4 and -6 are mutually exclusive")
+	case only4:
+		addressFamily = rir.IPv4
+	case only6:
+		addressFamily = rir.IPv6
+	}
+
+	switch *engine {
+	case "auto":
+		rir.Engine = autoEngine(flag.Arg(0), all)
+	case rir.EngineIndex, rir.EngineStream:
+		rir.Engine = *engine
+	default:
+		usageError("unknown engine %q, expected auto, index or stream", *engine)
+	}
+	if maxMemory > 0 {
+		applyMemoryBudget(maxMemory, *engine == "auto")
+	}
+
+	// Ctrl-C and the timeout abort in-flight downloads
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	if command, ok := commands[flag.Arg(0)]; ok {
+		check(rir.CreateCacheDir())
+		command(ctx, flag.Args()[1:])
+		exitStatus()
+		return
+	}
+
+	// the lookup, country and all subcommands are the historical -q, -c
+	// and -a flags, which remain as aliases
+	var ips []string
+	if ipquery != "" {
+		ips = append(ips, ipquery)
+	}
+	switch args := flag.Args(); flag.Arg(0) {
+	case "lookup":
+		if len(args) < 2 {
+			usageError("usage: rir lookup address|prefix|range|host...")
+		}
+		ips = append(ips, args[1:]...)
+	case "country":
+		fset := flag.NewFlagSet("country", flag.ExitOnError)
+		fset.BoolVar(&hostscount, "n", hostscount, "return possible hosts count (exclude network and broadcast addresses)")
+		fset.BoolVar(&asns, "asn", false, "list the AS numbers delegated to the country instead of its prefixes")
+		check(fset.Parse(args[1:]))
+		if fset.NArg() != 1 {
+			usageError("usage: rir country [-n | -asn] CC")
+		}
+		country = fset.Arg(0)
+	case "asn":
+		if len(args) != 2 {
+			usageError("usage: rir asn number")
+		}
+		asnquery = args[1]
+	case "all":
+		all = true
+	case "":
+	default:
+		// more addresses after -q
+		if len(ips) == 0 {
+			usageError("unknown command %q", flag.Arg(0))
+		}
+		ips = append(ips, args...)
+	}
+
 	query := Query{
-		country:    strings.ToUpper(country),
-		ipstring:   ipquery,
+		filter:     rir.Filter{Registry: registry, Status: status, OpaqueId: opaqueId, Type: addressFamily, Since: since, Until: until},
 		hostscount: hostscount,
+		asns:       asns,
+		best:       best,
+	}
+	if country != "" || notCountry != "" {
+		query.filter.Countries, query.notCountries = parseCountries(country)
+		if notCountry != "" {
+			countries, _ := parseCountries(notCountry)
+			query.notCountries = append(query.notCountries, countries...)
+		}
+		if len(query.filter.Countries) > 0 && len(query.notCountries) > 0 {
+			usageError("-c cannot both list and negate countries")
+		}
+	}
+	var hosts []string
+	for _, ip := range ips {
+		if prefix, err := netip.ParsePrefix(ip); err == nil {
+			query.prefixes = append(query.prefixes, prefix)
+			continue
+		}
+		if r, err := netipx.ParseIPRange(ip); err == nil {
+			query.ranges = append(query.ranges, r)
+			continue
+		}
+		if addr, err := netip.ParseAddr(ip); err == nil {
+			query.addrs = append(query.addrs, addr)
+			continue
+		}
+		if strings.ContainsAny(ip, ":/") {
+			usageError("invalid address, prefix or range %q", ip)
+		}
+		hosts = append(hosts, ip)
+	}
+	if len(hosts) > 0 {
+		check(rir.CreateCacheDir())
+		resolved := resolveHosts(ctx, hosts)
+		query.hosts = make(map[netip.Addr]string)
+		for _, host := range hosts {
+			if len(resolved[host]) == 0 {
+				fatalf("cannot resolve %q", host)
+			}
+			for _, addr := range resolved[host] {
+				query.addrs = append(query.addrs, addr)
+				query.hosts[addr] = host
+			}
+		}
+	}
+	if asnquery != "" {
+		asn, err := parseAsn(asnquery)
+		if err != nil {
+			usageError("invalid AS number %q", asnquery)
+		}
+		query.asn = &asn
 	}
 
-	if !(all || query.IsCountryQuery() || query.IsIpQuery()) {
+	if !(all || query.IsHolderQuery() || query.IsCountryQuery() || query.IsIpQuery() || query.IsAsnQuery()) {
 		flag.Usage()
 		return
 	}
 
-	CreateCacheDir()
+	if aggregate && provenance {
+		usageError("-aggregate and -provenance are mutually exclusive, aggregated prefixes span several records")
+	}
+	// aggregating also subtracts the excluded prefixes
+	byCountry := excludeByCountry
+	if aggregate {
+		byCountry = aggregateByCountry
+	} else if unique {
+		byCountry = func(seq iter.Seq[CountryPrefix]) iter.Seq[CountryPrefix] {
+			return uniqueByCountry(excludeByCountry(seq))
+		}
+	}
+
+	check(rir.CreateCacheDir())
+
+	var sources []GeoSource
+	if geofeed != "" {
+		sources = append(sources, newGeofeedSource(geofeed))
+	}
+	if mmdb != "" {
+		sources = append(sources, newMmdbSource(mmdb))
+	}
+	if rdap {
+		sources = append(sources, newRdapSource(rdapURL))
+	}
 
 	switch {
 	case all:
-		for r := range getAll {
-			fmt.Println(r)
+		for r := range byCountry(filteredPrefixes(ctx, query.filter)) {
+			if provenance {
+				emit(withProvenance(r, r))
+			} else {
+				emit(r)
+			}
+		}
+
+	case query.IsHolderQuery():
+		for r := range query.holderResources(ctx) {
+			emit(r)
 		}
 
 	case query.IsCountryQuery():
-		if query.hostscount {
-			fmt.Println(query.countryStats())
+		if len(query.notCountries) > 0 {
+			if query.hostscount || query.asns || sample > 0 {
+				usageError("-n, -asn and -sample do not apply to negated countries")
+			}
+			for _, prefix := range complementSet(ctx, query.filter, query.notCountries).Prefixes() {
+				emit(prefix)
+			}
 			break
 		}
-		for r := range query.readRegionsCountry {
-			fmt.Println(r)
-		}
-
-	case query.IsIpQuery():
-		for r := range query.matchOnIp {
-			fmt.Println(r)
+		// results of several countries are tagged with their country
+		tagged := outputFormat == "json" || len(query.filter.Countries) > 1
+		if query.hostscount {
+			for _, stats := range query.countryStats(ctx) {
+				emit(stats)
+			}
+			break
 		}
-	}
-}
-
-func getAll(yield func(string) bool) {
-	for region := range bufferedSeq(retrieveData, 10) {
-		for _, iprecord := range region.Ips {
-			cc := iprecord.Cc
-			if cc == "" {
-				continue
+		if sample > 0 {
+			if seed == 0 {
+				seed = time.Now().UnixNano()
 			}
-			for net := range bufferedSeq(iprecord.Net(), 10) {
-				if !yield(fmt.Sprintf("%s\t%s", cc, net)) {
-					return
+			for _, r := range query.countrySample(ctx, sample, rand.New(rand.NewSource(seed))) {
+				var result any = r.Address
+				if tagged {
+					result = r
 				}
+				emit(result)
 			}
+			break
 		}
-	}
-}
-
-type Query struct {
-	country    string
-	ipstring   string
-	hostscount bool
-}
-
-func (q Query) IsCountryQuery() bool {
-	return q.country != ""
-}
-
-func (q Query) IsIpQuery() bool {
-	return q.ipstring != ""
-}
-
-func (q Query) readRegionsCountry(yield func(netip.Prefix) bool) {
-	for region := range bufferedSeq(retrieveData, 10) {
-		for _, iprecord := range region.Ips {
-			if iprecord.Cc == q.country && (iprecord.Type == IPv4 || iprecord.Type == IPv6) {
-				for net := range bufferedSeq(iprecord.Net(), 10) {
-					if !yield(net) {
-						return
-					}
+		if query.asns {
+			for r := range query.countryAsns(ctx) {
+				var result any = fmt.Sprintf("AS%d", r.Asn)
+				if tagged {
+					result = r
 				}
+				emit(result)
 			}
+			break
 		}
-	}
-}
-
-func (q Query) matchOnIp(yield func(string) bool) {
-	addr := netip.MustParseAddr(q.ipstring)
-	for region := range bufferedSeq(retrieveData, 10) {
-		for _, iprecord := range region.Ips {
-			for ipnet := range bufferedSeq(iprecord.Net(), 10) {
-				if ipnet.Contains(addr) {
-					if !yield(fmt.Sprintf("%s\t%s", iprecord.Cc, ipnet)) {
-						return
-					}
-				}
+		for r := range byCountry(query.readRegionsCountry(ctx)) {
+			var result any = r.Prefix
+			if tagged {
+				result = r
+			}
+			if provenance {
+				result = withProvenance(result, r)
 			}
+			emit(result)
 		}
-	}
-}
-
-func (q Query) countryStats() string {
-	countV4 := big.NewInt(0)
-	countV6 := big.NewInt(0)
-	netHosts := big.NewInt(0)
-	one := big.NewInt(1)
 
-	for r := range bufferedSeq(q.readRegionsCountry, 10) {
-		ones := r.Bits()
-		addr := r.Addr()
-		var count *big.Int
-		var size int
-
-		if addr.Is4() {
-			count = countV4
-			size = 32
-		} else {
-			count = countV6
-			size = 128
+	case query.IsAsnQuery():
+		for r := range query.matchOnAsn(ctx) {
+			emit(r)
 		}
 
-		if mask := uint(size - ones); mask > 0 {
-			count.Add(count, netHosts.Lsh(one, mask))
+	case consensus:
+		sources = append([]GeoSource{&registrySource{}}, sources...)
+		for _, addr := range query.addrs {
+			printConsensus(ctx, sources, addr)
 		}
-	}
-
-	return fmt.Sprintf("v4: %s\nv6: %s", countV4, countV6)
-}
 
-func retrieveData(yield func(Records) bool) {
-	for _, provider := range AllProviders {
-		if !yield(NewReader(provider.GetData()).Read()) {
-			return
+	default:
+		// several addresses, or resources of their holders, are looked up
+		// in records loaded once
+		if query.queried() > 1 || related {
+			query.regions = slices.Collect(retrieveData(ctx))
 		}
-	}
-}
-
-func check(err error) {
-	if err != nil {
-		log.Panic(err)
-	}
-}
-
-func check1[T any](arg1 T, err error) T {
-	check(err)
-	return arg1
-}
-
-func bufferedSeq[T any](seq iter.Seq[T], bufsize int) iter.Seq[T] {
-	ch := make(chan T, bufsize)
-	var done bool
-
-	go func() {
-		for e := range seq {
-			if done {
-				break
+		for _, prefix := range query.prefixes {
+			for o := range query.matchOnPrefix(ctx, prefix) {
+				var result any = o
+				if query.queried() > 1 {
+					result = annotate(result, "prefix", prefix.String())
+				}
+				emit(result)
 			}
-			ch <- e
 		}
-		close(ch)
-	}()
+		for _, r := range query.ranges {
+			overlaps, gaps := query.matchOnRange(ctx, r)
+			results := make([]any, 0, len(overlaps)+len(gaps))
+			for _, o := range overlaps {
+				results = append(results, o)
+			}
+			for _, gap := range gaps {
+				results = append(results, gap)
+			}
+			for _, result := range results {
+				if query.queried() > 1 {
+					result = annotate(result, "range", r.String())
+				}
+				emit(result)
+			}
+		}
+		var ptrs map[netip.Addr][]string
+		if rdns {
+			ptrs = reverseDNS(ctx, query.addrs)
+		}
+		for _, queried := range query.addrs {
+			// addresses of transition mechanisms are looked up by the IPv4
+			// address they carry
+			addr, tunnel := queried, ""
+			if embedded, mechanism, ok := embeddedIPv4(queried); ok {
+				log.Printf("Looking up %s embedded in %s (%s)", embedded, queried, mechanism)
+				addr, tunnel = embedded, mechanism
+			}
 
-	return func(yield func(T) bool) {
-		for e := range ch {
-			if !yield(e) {
-				break
+			var answer GeoAnswer
+			if len(sources) > 0 {
+				answer, _ = operationalCountry(ctx, sources, addr)
+			}
+			var contact string
+			if abuse {
+				var err error
+				contact, err = abuseContact(ctx, newRdapSource(rdapURL), addr)
+				if err != nil {
+					log.Printf("Looking up abuse contact: %v", err)
+				}
+			}
+			for r := range query.lookup(ctx, addr) {
+				var result any = r
+				if len(sources) > 0 {
+					// label both countries so the registration country is not
+					// mistaken for where the address is used
+					result = LabeledResult{Registration: r.Country, Prefix: r.Prefix, Operational: answer.Country, OperationalSource: answer.Source}
+				}
+				host := query.hosts[queried]
+				if host != "" {
+					result = annotate(result, "host", host)
+				}
+				switch {
+				case tunnel != "":
+					result = annotate(annotate(result, "tunnel", tunnel), "address", queried.String())
+				case host != "" || query.queried() > 1:
+					// tell which address each line answers
+					result = annotate(result, "address", queried.String())
+				}
+				if rdns {
+					result = annotate(result, "ptr", strings.Join(ptrs[queried], ","))
+				}
+				if abuse {
+					result = annotate(result, "abuse", contact)
+				}
+				if explain {
+					result = explained(result, r)
+				}
+				if provenance {
+					result = withProvenance(result, r)
+				}
+				emit(result)
+
+				if related {
+					if r.record.OpaqueId == "" {
+						log.Printf("No opaque ID for %s in the %s file, cannot list related resources", r.Prefix, r.record.Registry)
+						continue
+					}
+					for resource := range query.relatedResources(ctx, r) {
+						emit(annotate(resource, "related", queried.String()))
+					}
+				}
 			}
 		}
-		done = true
 	}
+
+	exitStatus()
 }
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"runtime/debug"
+	"strconv"
+	"strings"
+
+	"github.com/monoidic/rir/rir"
+)
+
+// regionBuffer is the number of loaded registry files buffered ahead of the
+// one being queried.
+var regionBuffer = 10
+
+const (
+	// registryMemory is about the peak memory used to load the largest
+	// registry file (ripencc) through its index: raw content, index and
+	// records.
+	registryMemory = 192 << 20
+	// smallMemory is the budget under which registry files are streamed
+	// rather than read whole.
+	smallMemory = 1 << 30
+)
+
+// parseByteSize parses a size in bytes, with an optional K, M, G or T binary
+// suffix.
+func parseByteSize(value string) (int64, error) {
+	s := strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(value)), "B")
+	shift := 0
+	if i := strings.IndexAny(s, "KMGT"); i >= 0 && i == len(s)-1 {
+		shift = 10 * (strings.IndexByte("KMGT", s[i]) + 1)
+		s = s[:i]
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 || n > (1<<63-1)>>shift {
+		return 0, fmt.Errorf("invalid size %q, expected bytes or e.g. 256M, 64G", value)
+	}
+	return n << shift, nil
+}
+
+// applyMemoryBudget fits the loading of registry files into budget bytes:
+// fewer files are loaded in parallel and buffered, small budgets stream the
+// files instead of reading them whole unless the engine was chosen with
+// -engine, and the budget becomes the soft memory limit of the runtime.
+func applyMemoryBudget(budget int64, autoEngine bool) {
+	parallel := int(max(budget/registryMemory, 1))
+	jobs = min(jobs, parallel)
+	rir.FetchConcurrency = min(rir.FetchConcurrency, parallel)
+	regionBuffer = min(regionBuffer, parallel)
+	if budget < smallMemory && autoEngine {
+		rir.Engine = rir.EngineStream
+	}
+	debug.SetMemoryLimit(budget)
+}
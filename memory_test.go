@@ -0,0 +1,49 @@
+package main
+
+import (
+	"math"
+	"runtime/debug"
+	"testing"
+
+	"github.com/monoidic/rir/rir"
+)
+
+func TestParseByteSize(t *testing.T) {
+	for _, test := range []struct {
+		value string
+		want  int64
+	}{
+		{"1024", 1024},
+		{"256M", 256 << 20},
+		{"64g", 64 << 30},
+		{"512KB", 512 << 10},
+		{"2T", 2 << 40},
+	} {
+		if got, err := parseByteSize(test.value); err != nil || got != test.want {
+			t.Errorf("parseByteSize(%q): got %d, %v, want %d", test.value, got, err, test.want)
+		}
+	}
+	for _, value := range []string{"", "M", "-1", "1.5G", "1X", "9999999999T"} {
+		if _, err := parseByteSize(value); err == nil {
+			t.Errorf("parseByteSize(%q): unexpected success", value)
+		}
+	}
+}
+
+func TestApplyMemoryBudget(t *testing.T) {
+	defer func(j, fetch, buffer int, engine string) {
+		jobs, rir.FetchConcurrency, regionBuffer, rir.Engine = j, fetch, buffer, engine
+		debug.SetMemoryLimit(math.MaxInt64)
+	}(jobs, rir.FetchConcurrency, regionBuffer, rir.Engine)
+
+	applyMemoryBudget(256<<20, true)
+	if jobs != 1 || regionBuffer != 1 || rir.Engine != rir.EngineStream {
+		t.Errorf("256M: got %d jobs, buffer %d, engine %s", jobs, regionBuffer, rir.Engine)
+	}
+
+	jobs, regionBuffer, rir.Engine = 5, 10, rir.EngineIndex
+	applyMemoryBudget(64<<30, true)
+	if jobs != 5 || regionBuffer != 10 || rir.Engine != rir.EngineIndex {
+		t.Errorf("64G: got %d jobs, buffer %d, engine %s", jobs, regionBuffer, rir.Engine)
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"golang.org/x/text/language"
+	"golang.org/x/text/language/display"
+)
+
+// countryNames translates country codes to names in the language selected
+// with -names, from the CLDR data embedded in x/text. It is nil when names
+// are not requested.
+var countryNames *display.Namer
+
+func parseNamesLanguage(value string) error {
+	tag, err := language.Parse(value)
+	if err != nil {
+		return err
+	}
+	namer := display.Regions(tag)
+	countryNames = &namer
+	return nil
+}
+
+// countryName returns the localized name of a country code, or an empty
+// string if names are not requested or the code is not a known region.
+func countryName(cc string) string {
+	if countryNames == nil || cc == "" {
+		return ""
+	}
+	region, err := language.ParseRegion(cc)
+	if err != nil {
+		return ""
+	}
+	return (*countryNames).Name(region)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/monoidic/rir/rir"
+)
+
+// OriginPair is a prefix and an AS number delegated to the same holder, a
+// likely origin of the prefix.
+type OriginPair struct {
+	Registry string       `json:"registry"`
+	OpaqueId string       `json:"opaque_id"`
+	Prefix   netip.Prefix `json:"prefix"`
+	Asn      int          `json:"asn"`
+}
+
+func (o OriginPair) String() string {
+	return tsvLine(o.Prefix, fmt.Sprintf("AS%d", o.Asn), o.Registry, o.OpaqueId)
+}
+
+// originPairs pairs the prefixes of the holders of a country with every AS
+// number delegated to the same holder, as told by the opaque IDs of the
+// extended files. It also returns the number of prefixes whose holder has no
+// AS number.
+func originPairs(all []rir.Records, country string) (pairs []OriginPair, orphans int) {
+	type holder struct{ registry, opaqueId string }
+	asns := make(map[holder][]int)
+	for _, records := range all {
+		for _, r := range records.Asns {
+			if r.Cc == country && r.OpaqueId != "" {
+				h := holder{r.Registry, r.OpaqueId}
+				for asn := r.Start; asn < r.Start+r.Value; asn++ {
+					asns[h] = append(asns[h], asn)
+				}
+			}
+		}
+	}
+
+	for _, records := range all {
+		for _, r := range records.Ips {
+			if r.Cc != country || r.OpaqueId == "" {
+				continue
+			}
+			h := holder{r.Registry, r.OpaqueId}
+			for prefix, err := range r.Prefixes() {
+				if err != nil {
+					report("warning", codeInvalidRecord, &ProviderError{Provider: r.Registry, Err: err})
+					continue
+				}
+				if len(asns[h]) == 0 {
+					orphans++
+				}
+				for _, asn := range asns[h] {
+					pairs = append(pairs, OriginPair{Registry: r.Registry, OpaqueId: r.OpaqueId, Prefix: prefix, Asn: asn})
+				}
+			}
+		}
+	}
+
+	slices.SortFunc(pairs, func(a, b OriginPair) int {
+		return cmp.Or(
+			a.Prefix.Addr().Compare(b.Prefix.Addr()),
+			cmp.Compare(a.Prefix.Bits(), b.Prefix.Bits()),
+			cmp.Compare(a.Asn, b.Asn),
+		)
+	})
+	return pairs, orphans
+}
+
+// slurmAssertions renders pairs as the locally added assertions of an RFC 8416
+// SLURM file, which relying party software such as Routinator or rpki-client
+// loads as if they were ROAs.
+func slurmAssertions(pairs []OriginPair) ([]byte, error) {
+	type assertion struct {
+		Asn             int    `json:"asn"`
+		Prefix          string `json:"prefix"`
+		MaxPrefixLength int    `json:"maxPrefixLength"`
+		Comment         string `json:"comment"`
+	}
+	type slurm struct {
+		SlurmVersion            int `json:"slurmVersion"`
+		ValidationOutputFilters struct {
+			PrefixFilters []any `json:"prefixFilters"`
+			BgpsecFilters []any `json:"bgpsecFilters"`
+		} `json:"validationOutputFilters"`
+		LocallyAddedAssertions struct {
+			PrefixAssertions []assertion `json:"prefixAssertions"`
+			BgpsecAssertions []any       `json:"bgpsecAssertions"`
+		} `json:"locallyAddedAssertions"`
+	}
+
+	s := slurm{SlurmVersion: 1}
+	s.ValidationOutputFilters.PrefixFilters = []any{}
+	s.ValidationOutputFilters.BgpsecFilters = []any{}
+	s.LocallyAddedAssertions.PrefixAssertions = []assertion{}
+	s.LocallyAddedAssertions.BgpsecAssertions = []any{}
+	for _, o := range pairs {
+		s.LocallyAddedAssertions.PrefixAssertions = append(s.LocallyAddedAssertions.PrefixAssertions, assertion{
+			Asn:             o.Asn,
+			Prefix:          o.Prefix.String(),
+			MaxPrefixLength: o.Prefix.Bits(),
+			Comment:         o.Registry + " " + o.OpaqueId,
+		})
+	}
+	return json.MarshalIndent(s, "", "  ")
+}
+
+// originsCommand pairs the prefixes of a country with the AS numbers of their
+// holders, to seed ROA creation or prefix filters.
+func originsCommand(ctx context.Context, args []string) {
+	fset := flag.NewFlagSet("origins", flag.ExitOnError)
+	country := fset.String("country", "", "2 letters string of the country (ISO 3166)")
+	format := fset.String("format", "tsv", "output format: tsv for results, roa-csv (AS number, prefix, max length) or slurm for RFC 8416 assertions")
+	check(fset.Parse(args))
+
+	if *country == "" || !slices.Contains([]string{"tsv", "roa-csv", "slurm"}, *format) {
+		usageError("usage: rir origins -country CC [-format tsv|roa-csv|slurm]")
+	}
+
+	cc := strings.ToUpper(*country)
+	pairs, orphans := originPairs(slices.Collect(retrieveData(ctx)), cc)
+	if orphans > 0 {
+		log.Printf("%d prefixes of %s have no AS number delegated to their holder", orphans, cc)
+	}
+
+	var b bytes.Buffer
+	switch *format {
+	case "tsv":
+		for _, o := range pairs {
+			emit(o)
+		}
+		return
+	case "roa-csv":
+		b.WriteString("ASN,IP Prefix,Max Length\n")
+		for _, o := range pairs {
+			fmt.Fprintf(&b, "AS%d,%s,%d\n", o.Asn, o.Prefix, o.Prefix.Bits())
+		}
+	case "slurm":
+		b.Write(check1(slurmAssertions(pairs)))
+		b.WriteString("\n")
+	}
+	check1(os.Stdout.Write(b.Bytes()))
+	exportWritten(*format, cc, "", len(pairs))
+	runExportHook(ctx, *format, cc, false, len(pairs), b.Bytes())
+}
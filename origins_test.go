@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/monoidic/rir/rir"
+)
+
+func TestOriginPairs(t *testing.T) {
+	records, err := rir.NewReader(strings.NewReader(holderData)).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pairs, orphans := originPairs([]rir.Records{records}, "FR")
+	var got []string
+	for _, o := range pairs {
+		got = append(got, fmt.Sprintf("%s AS%d", o.Prefix, o.Asn))
+	}
+	want := "2.0.0.0/12 AS3215, 2.0.0.0/12 AS3216, 194.146.24.0/23 AS3215, 194.146.24.0/23 AS3216"
+	if strings.Join(got, ", ") != want || orphans != 0 {
+		t.Errorf("originPairs(FR): got %v and %d orphans, want %s", got, orphans, want)
+	}
+
+	if pairs, orphans := originPairs([]rir.Records{records}, "DE"); len(pairs) != 0 || orphans != 1 {
+		t.Errorf("originPairs(DE): got %v and %d orphans, want 1 orphan", pairs, orphans)
+	}
+}
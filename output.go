@@ -0,0 +1,313 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/monoidic/rir/rir"
+)
+
+// outputFormat is either "text", the historical tab separated output with
+// free-text log lines, "csv", the same columns as comma separated values,
+// "json", one JSON object per result on stdout and structured error objects
+// on stderr, or "whois", key: value blocks for lookups.
+var outputFormat = "text"
+
+func parseOutputFormat(value string) error {
+	switch value {
+	case "text", "tsv":
+		outputFormat = "text"
+	case "csv", "json", "whois":
+		outputFormat = value
+	default:
+		return fmt.Errorf("unknown output format %q, expected tsv, csv, json or whois", value)
+	}
+	return nil
+}
+
+// CountryPrefix is a prefix delegated to a country.
+type CountryPrefix struct {
+	Country     string       `json:"country"`
+	CountryName string       `json:"country_name,omitempty"`
+	Prefix      netip.Prefix `json:"prefix"`
+
+	provenance Provenance
+	// record is the delegation of the prefix
+	record rir.IpRecord
+}
+
+// Provenance locates the line of the registry file a result came from.
+type Provenance struct {
+	File   string
+	Serial string
+	Line   int
+}
+
+func newCountryPrefix(cc string, prefix netip.Prefix) CountryPrefix {
+	return CountryPrefix{Country: cc, CountryName: countryName(cc), Prefix: prefix}
+}
+
+// unknownCountry stands for the missing country of reserved and available
+// space, as in the files of some registries.
+const unknownCountry = "ZZ"
+
+func recordPrefix(records rir.Records, ip rir.IpRecord, prefix netip.Prefix) CountryPrefix {
+	cp := newCountryPrefix(cmp.Or(ip.Cc, unknownCountry), prefix)
+	cp.provenance = Provenance{File: records.Source, Serial: records.Serial, Line: ip.Line}
+	cp.record = ip
+	return cp
+}
+
+// withProvenance annotates a result with where cp came from, if it comes
+// from a single record.
+func withProvenance(result any, cp CountryPrefix) any {
+	if cp.provenance == (Provenance{}) {
+		return result
+	}
+	a := annotate(result, "file", cp.provenance.File)
+	a = annotate(a, "serial", cp.provenance.Serial)
+	return annotate(a, "line", cp.provenance.Line)
+}
+
+func (cp CountryPrefix) String() string {
+	if cp.CountryName != "" {
+		return tsvLine(cp.Country, cp.Prefix, cp.CountryName)
+	}
+	return tsvLine(cp.Country, cp.Prefix)
+}
+
+// Fields of the text output are separated by tabs, so tabs, line breaks and
+// backslashes inside a field (say an opaque ID or a PTR annotation) are
+// escaped as \t, \n, \r and \\, as in the PostgreSQL text format. A line
+// therefore always holds exactly one result, and splitting it on tabs always
+// yields its fields.
+var (
+	tsvEscaper   = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+	tsvUnescaper = strings.NewReplacer(`\\`, `\`, `\t`, "\t", `\n`, "\n", `\r`, "\r")
+)
+
+// tsvLine joins fields into a line of text output, escaping each of them.
+func tsvLine(fields ...any) string {
+	escaped := make([]string, len(fields))
+	for i, field := range fields {
+		escaped[i] = tsvEscaper.Replace(fmt.Sprint(field))
+	}
+	return strings.Join(escaped, "\t")
+}
+
+// tsvFields splits a line of text output back into its unescaped fields.
+func tsvFields(line string) []string {
+	fields := strings.Split(line, "\t")
+	for i, field := range fields {
+		fields[i] = tsvUnescaper.Replace(field)
+	}
+	return fields
+}
+
+// Annotated is a result with extra key=value fields, appended as columns in
+// text output and merged into the object in JSON output.
+type Annotated struct {
+	Result      any
+	Annotations []Annotation
+}
+
+type Annotation struct {
+	Key   string
+	Value any
+}
+
+// annotate adds a key=value field to a result.
+func annotate(result any, key string, value any) Annotated {
+	a, ok := result.(Annotated)
+	if !ok {
+		a = Annotated{Result: result}
+	}
+	a.Annotations = append(slices.Clip(a.Annotations), Annotation{Key: key, Value: value})
+	return a
+}
+
+func (a Annotated) String() string {
+	var b strings.Builder
+	fmt.Fprint(&b, a.Result)
+	for _, annotation := range a.Annotations {
+		b.WriteString("\t" + tsvLine(fmt.Sprintf("%s=%v", annotation.Key, annotation.Value)))
+	}
+	return b.String()
+}
+
+func (a Annotated) MarshalJSON() ([]byte, error) {
+	content, err := json.Marshal(a.Result)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(content, &fields); err != nil {
+		return nil, err
+	}
+	for _, annotation := range a.Annotations {
+		fields[annotation.Key] = annotation.Value
+	}
+	return json.Marshal(fields)
+}
+
+// A csvRow is a result whose CSV columns differ from the fields of its text
+// output, such as a result spanning several lines.
+type csvRow interface {
+	csvRow() []string
+}
+
+// emit prints a single result in the selected output format. CSV rows are
+// the unescaped columns of the text output, unless the result is a csvRow,
+// quoted as in RFC 4180 where they contain commas, quotes or line breaks.
+func emit(v any) {
+	switch outputFormat {
+	case "json":
+		fmt.Println(string(check1(json.Marshal(v))))
+	case "csv":
+		w := csv.NewWriter(os.Stdout)
+		if row, ok := v.(csvRow); ok {
+			check(w.Write(row.csvRow()))
+		} else {
+			check(w.Write(tsvFields(fmt.Sprint(v))))
+		}
+		w.Flush()
+		check(w.Error())
+	case "whois":
+		fmt.Println(whoisText(v))
+	default:
+		fmt.Println(v)
+	}
+}
+
+// Error codes of the structured error objects.
+const (
+	codeLog             = "log"
+	codeFatal           = "fatal"
+	codeUsage           = "usage"
+	codeProviderFailed  = "provider_failed"
+	codeProviderSkipped = "provider_skipped"
+	codeInvalidRecord   = "invalid_record"
+)
+
+// ErrorReport is the structured form of a failure or log line.
+type ErrorReport struct {
+	Level    string `json:"level"`
+	Code     string `json:"code"`
+	Provider string `json:"provider,omitempty"`
+	Message  string `json:"message"`
+}
+
+// ProviderError attributes a failure to the provider it happened in.
+type ProviderError struct {
+	Provider string
+	Err      error
+}
+
+func (e *ProviderError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Provider, e.Err)
+}
+
+func (e *ProviderError) Unwrap() error {
+	return e.Err
+}
+
+func writeReport(report ErrorReport) {
+	content := check1(json.Marshal(report))
+	os.Stderr.Write(append(content, '\n'))
+}
+
+// report prints a failure on stderr, as a log line or as a JSON object.
+func report(level string, code string, err error) {
+	if outputFormat != "json" {
+		if level == "warning" {
+			log.Printf("Warning: %v", err)
+		} else {
+			log.Print(err)
+		}
+		return
+	}
+
+	r := ErrorReport{Level: level, Code: code, Message: err.Error()}
+	if perr := (*ProviderError)(nil); errors.As(err, &perr) {
+		r.Provider = perr.Provider
+		r.Message = perr.Err.Error()
+	}
+	writeReport(r)
+}
+
+// fatal reports an error and exits.
+func fatal(code string, err error) {
+	report("error", code, err)
+	os.Exit(1)
+}
+
+// fatalf reports a failure of the command and exits.
+func fatalf(format string, args ...any) {
+	fatal(codeFatal, fmt.Errorf(format, args...))
+}
+
+// usageError reports invalid arguments and exits.
+func usageError(format string, args ...any) {
+	fatal(codeUsage, fmt.Errorf(format, args...))
+}
+
+// jsonLogWriter turns the free-text lines of the standard logger into
+// structured objects.
+type jsonLogWriter struct{}
+
+func (jsonLogWriter) Write(p []byte) (int, error) {
+	writeReport(ErrorReport{Level: "info", Code: codeLog, Message: string(bytes.TrimSpace(p))})
+	return len(p), nil
+}
+
+// setupOutput configures logging for the selected output format. In JSON mode
+// panics are reported as structured errors instead of stack traces.
+func setupOutput() {
+	if outputFormat == "json" {
+		log.SetFlags(0)
+		log.SetOutput(jsonLogWriter{})
+	}
+}
+
+// recoverFatal is deferred in main to report the errors raised by check, and
+// in JSON mode any panic, as errors instead of stack traces.
+func recoverFatal() {
+	r := recover()
+	if r == nil {
+		return
+	}
+	if c, ok := r.(checkError); ok {
+		fatal(codeFatal, c.err)
+	}
+	if outputFormat == "json" {
+		fatal(codeFatal, fmt.Errorf("%v", r))
+	}
+	panic(r)
+}
+
+// checkError is raised by check and reported by recoverFatal.
+type checkError struct {
+	err error
+}
+
+// check aborts the command on errors there is nothing else to do about,
+// main reports them as a plain message rather than a stack trace.
+func check(err error) {
+	if err != nil {
+		panic(checkError{err})
+	}
+}
+
+func check1[T any](arg1 T, err error) T {
+	check(err)
+	return arg1
+}
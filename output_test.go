@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"math/big"
+	"net/netip"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestTsvEscaping(t *testing.T) {
+	for _, fields := range [][]string{
+		{"ripencc", "plain-id", "FR"},
+		{"ripencc", "tab\tinside", "FR"},
+		{"arin", "line\nbreak\r", "US"},
+		{"apnic", `back\slash\t`, "AU"},
+		{"lacnic", "comma,and\"quote", ""},
+	} {
+		values := make([]any, len(fields))
+		for i, field := range fields {
+			values[i] = field
+		}
+		line := tsvLine(values...)
+		if strings.ContainsAny(line, "\n\r") || strings.Count(line, "\t") != len(fields)-1 {
+			t.Errorf("tsvLine(%q): got %q, want one line of %d fields", fields, line, len(fields))
+		}
+		if got := tsvFields(line); !slices.Equal(got, fields) {
+			t.Errorf("tsvFields(tsvLine(%q)): got %q", fields, got)
+		}
+	}
+}
+
+func TestAnnotatedEscaping(t *testing.T) {
+	a := annotate(newCountryPrefix("FR", netip.MustParsePrefix("2.0.0.0/12")), "ptr", "evil\thost\nname")
+	line := a.String()
+	want := "FR\t2.0.0.0/12\tptr=evil\\thost\\nname"
+	if line != want {
+		t.Errorf("Annotated.String: got %q, want %q", line, want)
+	}
+
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+	if err := w.Write(tsvFields(line)); err != nil {
+		t.Fatal(err)
+	}
+	w.Flush()
+	got, err := csv.NewReader(&b).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"FR", "2.0.0.0/12", "ptr=evil\thost\nname"}; !slices.Equal(got, want) {
+		t.Errorf("CSV row: got %q, want %q", got, want)
+	}
+}
+
+func TestCountryStatsCSV(t *testing.T) {
+	stats := CountryStats{Country: "FR", CountryName: "France", V4: big.NewInt(1049088), V6: big.NewInt(0), tagged: true}
+	if got, want := stats.csvRow(), []string{"FR", "France", "1049088", "0"}; !slices.Equal(got, want) {
+		t.Errorf("CSV row: got %q, want %q", got, want)
+	}
+}
+
+// TestFatalJSON runs the test binary again to exit through usageError.
+func TestFatalJSON(t *testing.T) {
+	if os.Getenv("RIR_TEST_FATAL") != "" {
+		outputFormat = "json"
+		setupOutput()
+		usageError("usage: rir %s", "test")
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestFatalJSON$")
+	cmd.Env = append(os.Environ(), "RIR_TEST_FATAL=1")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err == nil {
+		t.Fatal("usageError did not exit")
+	}
+
+	var r ErrorReport
+	if err := json.Unmarshal(stderr.Bytes(), &r); err != nil {
+		t.Fatalf("%q: %v", stderr.String(), err)
+	}
+	if want := (ErrorReport{Level: "error", Code: codeUsage, Message: "usage: rir test"}); r != want {
+		t.Errorf("got %+v, want %+v", r, want)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"net/netip"
+	"slices"
+)
+
+// A countryOverlap is a prefix whose country differs from the one of the
+// delegation enclosing it, typical of anycast or mis-registered ranges.
+type countryOverlap struct {
+	source    string // "registry" for nested delegations, or "geofeed"
+	prefix    netip.Prefix
+	country   string
+	enclosing delegation
+}
+
+func (o countryOverlap) String() string {
+	return tsvLine(o.source, o.prefix, o.country, o.enclosing.Prefix, o.enclosing.Record.Cc, o.enclosing.Record.Registry)
+}
+
+func (o countryOverlap) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Source           string       `json:"source"`
+		Prefix           netip.Prefix `json:"prefix"`
+		Country          string       `json:"country"`
+		Enclosing        netip.Prefix `json:"enclosing"`
+		EnclosingCountry string       `json:"enclosing_country"`
+		Registry         string       `json:"registry"`
+	}{o.source, o.prefix, o.country, o.enclosing.Prefix, o.enclosing.Record.Cc, o.enclosing.Record.Registry})
+}
+
+// enclosingDelegation returns the most specific delegation strictly
+// containing prefix.
+func enclosingDelegation(table *prefixTable[delegation], prefix netip.Prefix) (delegation, bool) {
+	if prefix.Bits() == 0 {
+		return delegation{}, false
+	}
+	d, ok := table.covering(netip.PrefixFrom(prefix.Addr(), prefix.Bits()-1))
+	if !ok || d.Record.Status == "available" || d.Record.Cc == "" {
+		return delegation{}, false
+	}
+	return d, true
+}
+
+// countryOverlaps compares the delegations of the table, and the geofeed
+// entries if any, with the delegations enclosing them.
+func countryOverlaps(table *prefixTable[delegation], geofeed *prefixTable[string]) []countryOverlap {
+	var overlaps []countryOverlap
+	for prefix, d := range table.entries {
+		if d.Record.Status == "available" || d.Record.Cc == "" {
+			continue
+		}
+		if enclosing, ok := enclosingDelegation(table, prefix); ok && enclosing.Record.Cc != d.Record.Cc {
+			overlaps = append(overlaps, countryOverlap{"registry", prefix, d.Record.Cc, enclosing})
+		}
+	}
+	if geofeed != nil {
+		for prefix, cc := range geofeed.entries {
+			// the geofeed entry itself may match a delegation exactly
+			enclosing, ok := table.covering(prefix)
+			if ok && enclosing.Record.Status != "available" && enclosing.Record.Cc != "" && enclosing.Record.Cc != cc {
+				overlaps = append(overlaps, countryOverlap{"geofeed", prefix, cc, enclosing})
+			}
+		}
+	}
+
+	slices.SortFunc(overlaps, func(a, b countryOverlap) int {
+		if c := a.prefix.Addr().Compare(b.prefix.Addr()); c != 0 {
+			return c
+		}
+		return a.prefix.Bits() - b.prefix.Bits()
+	})
+	return overlaps
+}
+
+// overlapCommand lists the prefixes whose country differs from the one of the
+// delegation enclosing them.
+func overlapCommand(ctx context.Context, args []string) {
+	fset := flag.NewFlagSet("overlap", flag.ExitOnError)
+	geofeed := fset.String("geofeed", "", "RFC 8805 geofeed CSV file whose entries are also checked")
+	check(fset.Parse(args))
+
+	if fset.NArg() != 0 {
+		usageError("usage: rir overlap [-geofeed geofeed.csv]")
+	}
+
+	var feed *prefixTable[string]
+	if *geofeed != "" {
+		feed = newGeofeedSource(*geofeed).table
+	}
+	for _, o := range countryOverlaps(loadPrefixTable(ctx), feed) {
+		emit(o)
+	}
+}
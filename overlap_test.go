@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"net/netip"
+	"testing"
+)
+
+func TestCountryOverlaps(t *testing.T) {
+	table := newPrefixTable[delegation]()
+	for _, d := range []struct {
+		prefix, cc, status string
+	}{
+		{"10.0.0.0/8", "FR", "allocated"},
+		{"10.1.0.0/16", "DE", "assigned"},
+		{"10.2.0.0/16", "FR", "assigned"},
+		{"10.3.0.0/16", "", "available"},
+		{"192.0.2.0/24", "US", "allocated"},
+	} {
+		prefix := netip.MustParsePrefix(d.prefix)
+		var r delegation
+		r.Prefix = prefix
+		r.Record.Cc = d.cc
+		r.Record.Status = d.status
+		table.add(prefix, r)
+	}
+	geofeed := newPrefixTable[string]()
+	geofeed.add(netip.MustParsePrefix("10.1.2.0/24"), "DE")
+	geofeed.add(netip.MustParsePrefix("192.0.2.0/25"), "JP")
+	geofeed.add(netip.MustParsePrefix("198.51.100.0/24"), "JP")
+
+	want := []string{
+		"registry 10.1.0.0/16 DE 10.0.0.0/8 FR",
+		"geofeed 192.0.2.0/25 JP 192.0.2.0/24 US",
+	}
+	got := countryOverlaps(table, geofeed)
+	if len(got) != len(want) {
+		t.Fatalf("got %d overlaps %+v, want %d", len(got), got, len(want))
+	}
+	for i, o := range got {
+		if s := fmt.Sprintf("%s %s %s %s %s", o.source, o.prefix, o.country, o.enclosing.Prefix, o.enclosing.Record.Cc); s != want[i] {
+			t.Errorf("overlap %d: got %q, want %q", i, s, want[i])
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"log"
+	"math/bits"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/monoidic/rir/rir"
+	"go4.org/netipx"
+)
+
+// overlayEntry overrides the country of a prefix.
+type overlayEntry struct {
+	Prefix  netip.Prefix
+	Country string
+}
+
+// countryOverlay holds user maintained country corrections. It is applied to
+// the records of every provider, so lookups, stats and exports all see the
+// same corrected data.
+type countryOverlay struct {
+	entries []overlayEntry
+	set     *netipx.IPSet
+}
+
+var overlay *countryOverlay
+
+// loadOverlayFile parses a file of "prefix CC" lines, separated by spaces or
+// a comma, and reports conflicting entries.
+func loadOverlayFile(path string) error {
+	f := openInput(path)
+	defer f.Close()
+
+	var entries []overlayEntry
+	s := bufio.NewScanner(f)
+	for lineNumber := 1; s.Scan(); lineNumber++ {
+		line, _, _ := strings.Cut(s.Text(), "#")
+		fields := strings.FieldsFunc(line, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' })
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 2 || len(fields[1]) != 2 {
+			return fmt.Errorf("%s:%d: expected prefix and country code", path, lineNumber)
+		}
+		prefix, err := parsePrefixOrAddr(fields[0])
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", path, lineNumber, err)
+		}
+		entries = append(entries, overlayEntry{Prefix: prefix.Masked(), Country: strings.ToUpper(fields[1])})
+	}
+	if err := s.Err(); err != nil {
+		return err
+	}
+
+	// most specific first, so that they take precedence when applied
+	slices.SortStableFunc(entries, func(a, b overlayEntry) int {
+		return cmp.Compare(b.Prefix.Bits(), a.Prefix.Bits())
+	})
+
+	var b netipx.IPSetBuilder
+	for i, entry := range entries {
+		b.AddPrefix(entry.Prefix)
+		for _, other := range entries[i+1:] {
+			switch {
+			case entry.Country == other.Country || !entry.Prefix.Overlaps(other.Prefix):
+			case entry.Prefix == other.Prefix:
+				log.Printf("Overlay conflict: %s is both %s and %s, using %s", entry.Prefix, entry.Country, other.Country, entry.Country)
+			default:
+				log.Printf("Overlay conflict: %s %s overlaps %s %s, the more specific one wins", entry.Prefix, entry.Country, other.Prefix, other.Country)
+			}
+		}
+	}
+
+	overlay = &countryOverlay{entries: entries, set: check1(b.IPSet())}
+	return nil
+}
+
+// prefixRecord builds the record of a single prefix split off ip.
+func prefixRecord(ip rir.IpRecord, prefix netip.Prefix, country string) rir.IpRecord {
+	r := ip
+	r.Cc = country
+	r.Start = prefix.Addr()
+	if ip.Type == rir.IPv4 {
+		r.Value = 1 << (32 - prefix.Bits())
+	} else {
+		r.Value = prefix.Bits()
+	}
+	return r
+}
+
+// split applies the overlay to the record, returning it unchanged when no
+// entry overlaps it.
+func (o *countryOverlay) split(ip rir.IpRecord) []rir.IpRecord {
+	if bits.UintSize < 64 && ip.Type == rir.IPv4 {
+		// a /0 does not fit the Value of a 32 bits int
+		return []rir.IpRecord{ip}
+	}
+
+	var result []rir.IpRecord
+	changed := false
+	for net := range ip.Net() {
+		if !o.set.OverlapsPrefix(net) {
+			result = append(result, prefixRecord(ip, net, ip.Cc))
+			continue
+		}
+		changed = true
+
+		var remaining netipx.IPSetBuilder
+		remaining.AddPrefix(net)
+		for _, entry := range o.entries {
+			if !entry.Prefix.Overlaps(net) {
+				continue
+			}
+			var portion netipx.IPSetBuilder
+			portion.AddPrefix(entry.Prefix)
+			portion.Intersect(check1(remaining.IPSet()))
+			for _, prefix := range check1(portion.IPSet()).Prefixes() {
+				result = append(result, prefixRecord(ip, prefix, entry.Country))
+			}
+			remaining.RemovePrefix(entry.Prefix)
+		}
+		for _, prefix := range check1(remaining.IPSet()).Prefixes() {
+			result = append(result, prefixRecord(ip, prefix, ip.Cc))
+		}
+	}
+
+	if !changed {
+		return []rir.IpRecord{ip}
+	}
+	return result
+}
+
+// applyOverlay rewrites the ip records of records according to the overlay,
+// if any.
+func applyOverlay(records rir.Records) rir.Records {
+	if overlay == nil {
+		return records
+	}
+
+	ips := make([]rir.IpRecord, 0, len(records.Ips))
+	for _, ip := range records.Ips {
+		ips = append(ips, overlay.split(ip)...)
+	}
+	records.Ips = ips
+	return records
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/monoidic/rir/rir"
+)
+
+func TestOverlaySplit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "overlay")
+	if err := os.WriteFile(path, []byte("# corrections\n2.1.0.0/16 DE\n2.1.5.0/24,FI\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadOverlayFile(path); err != nil {
+		t.Fatalf("load overlay: %v", err)
+	}
+	defer func() { overlay = nil }()
+
+	ip := rir.IpRecord{
+		Record: rir.Record{Registry: "ripencc", Cc: "FR", Type: rir.IPv4, Value: 1 << 20},
+		Start:  netip.MustParseAddr("2.0.0.0"),
+	}
+	countries := make(map[string]int)
+	for _, r := range overlay.split(ip) {
+		countries[r.Cc] += r.Value
+	}
+
+	if countries["FI"] != 256 {
+		t.Errorf("FI addresses: expected 256 got %d", countries["FI"])
+	}
+	if countries["DE"] != 1<<16-256 {
+		t.Errorf("DE addresses: expected %d got %d", 1<<16-256, countries["DE"])
+	}
+	if countries["FR"] != 1<<20-1<<16 {
+		t.Errorf("FR addresses: expected %d got %d", 1<<20-1<<16, countries["FR"])
+	}
+
+	untouched := rir.IpRecord{
+		Record: rir.Record{Registry: "arin", Cc: "US", Type: rir.IPv4, Value: 256},
+		Start:  netip.MustParseAddr("8.8.8.0"),
+	}
+	if split := overlay.split(untouched); len(split) != 1 || split[0] != untouched {
+		t.Errorf("untouched record: expected it unchanged got %v", split)
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"log"
+	"math/rand"
+	"net/netip"
+	"os"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/monoidic/rir/rir"
+	"go4.org/netipx"
+)
+
+// CountryReachability aggregates the probes of the sampled addresses of a
+// country.
+type CountryReachability struct {
+	Country   string  `json:"country"`
+	Probed    int     `json:"probed"`
+	Reachable int     `json:"reachable"`
+	Rate      float64 `json:"rate"`
+	// MedianRTT is the median round trip time reported by the prober for
+	// the reachable addresses, in milliseconds, 0 when it reports none.
+	MedianRTT float64 `json:"median_rtt_ms,omitempty"`
+}
+
+func (r CountryReachability) String() string {
+	rtt := "-"
+	if r.MedianRTT > 0 {
+		rtt = strconv.FormatFloat(r.MedianRTT, 'f', 1, 64)
+	}
+	return tsvLine(r.Country, r.Probed, r.Reachable, strconv.FormatFloat(r.Rate, 'f', 3, 64), rtt)
+}
+
+// probeResult is the outcome of probing an address.
+type probeResult struct {
+	reachable bool
+	rtt       float64 // ms, 0 when unknown
+}
+
+// rttPattern finds the round trip time in the output of usual probers, such
+// as fping ("12.3 ms") and ping ("time=12.3 ms").
+var rttPattern = regexp.MustCompile(`([0-9]+(?:\.[0-9]+)?) ?ms\b`)
+
+// runProbe runs the prober command template on addr, {} standing for the
+// address. An address is reachable when the command succeeds.
+func runProbe(ctx context.Context, template string, addr netip.Addr, timeout time.Duration) probeResult {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	command := strings.ReplaceAll(template, "{}", addr.String())
+	var out bytes.Buffer
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	// children of the shell may outlive it, holding the output open
+	cmd.WaitDelay = time.Second
+	if err := cmd.Run(); err != nil {
+		return probeResult{}
+	}
+	result := probeResult{reachable: true}
+	if m := rttPattern.FindSubmatch(out.Bytes()); m != nil {
+		result.rtt, _ = strconv.ParseFloat(string(m[1]), 64)
+	}
+	return result
+}
+
+// aggregateProbes sums up the probes of the addresses of a country.
+func aggregateProbes(country string, results []probeResult) CountryReachability {
+	r := CountryReachability{Country: country, Probed: len(results)}
+	var rtts []float64
+	for _, result := range results {
+		if result.reachable {
+			r.Reachable++
+			if result.rtt > 0 {
+				rtts = append(rtts, result.rtt)
+			}
+		}
+	}
+	if r.Probed > 0 {
+		r.Rate = float64(r.Reachable) / float64(r.Probed)
+	}
+	if len(rtts) > 0 {
+		slices.Sort(rtts)
+		if n := len(rtts); n%2 == 1 {
+			r.MedianRTT = rtts[n/2]
+		} else {
+			r.MedianRTT = (rtts[n/2-1] + rtts[n/2]) / 2
+		}
+	}
+	return r
+}
+
+// probeCommand measures the reachability of countries by probing addresses
+// sampled from their space with an external prober, such as fping or
+// scamper.
+func probeCommand(ctx context.Context, args []string) {
+	fset := flag.NewFlagSet("probe", flag.ExitOnError)
+	countries := fset.String("country", "", "comma separated 2 letters strings of the countries (ISO 3166)")
+	prober := fset.String("prober", "fping -c1 -t1000 {}", "shell command probing an address, given as {}; it must succeed when the address answers")
+	sample := fset.Int("sample", 100, "number of addresses probed per country")
+	seed := fset.Int64("seed", 0, "seed of the sampling (default random)")
+	workers := fset.Int("workers", 16, "maximum number of concurrent probes")
+	timeout := fset.Duration("probe-timeout", 5*time.Second, "maximum duration of a probe")
+	check(fset.Parse(args))
+
+	if *countries == "" || *sample < 1 || *workers < 1 || !strings.Contains(*prober, "{}") {
+		usageError("usage: rir probe -country CC[,CC...] [-prober 'command {}'] [-sample n] [-seed n] [-workers n] [-probe-timeout duration]")
+	}
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	rng := rand.New(rand.NewSource(*seed))
+
+	type target struct {
+		country int
+		addr    netip.Addr
+	}
+	var names []string
+	var targets []target
+	listed := countryList(*countries)
+	sets := countrySets(ctx, listed)
+	for _, country := range listed {
+		var b netipx.IPSetBuilder
+		b.AddSet(sets[country])
+		// random IPv6 addresses hardly ever answer, probe IPv4 unless -6
+		if addressFamily == rir.IPv6 {
+			b.RemovePrefix(netip.MustParsePrefix("0.0.0.0/0"))
+		} else {
+			b.RemovePrefix(netip.MustParsePrefix("::/0"))
+		}
+		for _, prefix := range specialRanges {
+			b.RemovePrefix(prefix)
+		}
+		for _, addr := range sampleAddresses(subtractExcluded(check1(b.IPSet())), *sample, rng) {
+			targets = append(targets, target{len(names), addr})
+		}
+		names = append(names, country)
+	}
+
+	results := make([][]probeResult, len(names))
+	queue := make(chan target)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for range min(*workers, len(targets)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range queue {
+				result := runProbe(ctx, *prober, t.addr, *timeout)
+				mu.Lock()
+				results[t.country] = append(results[t.country], result)
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, t := range targets {
+		queue <- t
+	}
+	close(queue)
+	wg.Wait()
+	if ctx.Err() != nil {
+		log.Printf("Probing interrupted: %v", ctx.Err())
+		os.Exit(1)
+	}
+
+	for i, country := range names {
+		emit(aggregateProbes(country, results[i]))
+	}
+}
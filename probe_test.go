@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestRunProbe(t *testing.T) {
+	ctx := context.Background()
+	addr := netip.MustParseAddr("192.0.2.1")
+	for _, test := range []struct {
+		template string
+		want     probeResult
+	}{
+		{"echo '{} : [0], 64 bytes, 12.3 ms (12.3 avg, 0% loss)'", probeResult{true, 12.3}},
+		{"test {} = 192.0.2.1", probeResult{true, 0}},
+		{"test {} = 192.0.2.2", probeResult{}},
+		{"sleep 5", probeResult{}},
+	} {
+		if got := runProbe(ctx, test.template, addr, 500*time.Millisecond); got != test.want {
+			t.Errorf("runProbe(%q): got %+v, want %+v", test.template, got, test.want)
+		}
+	}
+}
+
+func TestAggregateProbes(t *testing.T) {
+	got := aggregateProbes("FR", []probeResult{{true, 10}, {false, 0}, {true, 30}, {true, 0}})
+	want := CountryReachability{Country: "FR", Probed: 4, Reachable: 3, Rate: 0.75, MedianRTT: 20}
+	if got != want {
+		t.Errorf("aggregateProbes: got %+v, want %+v", got, want)
+	}
+	if got := got.String(); got != "FR\t4\t3\t0.750\t20.0" {
+		t.Errorf("String: got %q", got)
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/monoidic/rir/rir"
+	"go4.org/netipx"
+)
+
+// A queryProfile is a named query of the profiles file, run by rir run.
+type queryProfile struct {
+	Name      string
+	Countries []string
+	Registry  string
+	Status    string
+	Family    string
+	// Format is an output format, tsv, csv or json, or an export format
+	Format    string
+	Aggregate bool
+	// SetName names the set or list of exports, the profile name by default
+	SetName string
+}
+
+// parseClause applies a "key value" clause of a profile definition.
+func (p *queryProfile) parseClause(clause string) error {
+	key, value, _ := strings.Cut(strings.TrimSpace(clause), " ")
+	value = strings.TrimSpace(value)
+	switch key {
+	case "countries", "country":
+		for _, cc := range strings.Split(strings.ToUpper(value), ",") {
+			p.Countries = append(p.Countries, strings.TrimSpace(cc))
+		}
+	case "registry":
+		p.Registry = value
+	case "status":
+		p.Status = value
+	case "family":
+		switch value {
+		case "4", rir.IPv4:
+			p.Family = rir.IPv4
+		case "6", rir.IPv6:
+			p.Family = rir.IPv6
+		default:
+			return fmt.Errorf("unknown family %q, expected 4 or 6", value)
+		}
+	case "format":
+		if _, ok := exporters[value]; !ok && !slices.Contains([]string{"tsv", "csv", "json"}, value) {
+			return fmt.Errorf("unknown format %q, expected tsv, csv, json or one of %s", value, exporterNames())
+		}
+		p.Format = value
+	case "aggregate":
+		p.Aggregate = true
+	case "name":
+		p.SetName = value
+	default:
+		return fmt.Errorf("unknown clause %q", key)
+	}
+	return nil
+}
+
+// readProfiles parses profile definitions, one per line:
+//
+//	profile "nordics" = countries FI,SE,NO,DK; format nftables; aggregate
+//
+// Blank lines and # comments are ignored.
+func readProfiles(r io.Reader) (map[string]queryProfile, error) {
+	profiles := make(map[string]queryProfile)
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rest, ok := strings.CutPrefix(line, "profile ")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected profile \"name\" = ...", n)
+		}
+		rest = strings.TrimSpace(rest)
+		quoted, err := strconv.QuotedPrefix(rest)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: expected a quoted profile name", n)
+		}
+		p := queryProfile{Name: check1(strconv.Unquote(quoted))}
+		rest, ok = strings.CutPrefix(strings.TrimSpace(rest[len(quoted):]), "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected = after the profile name", n)
+		}
+		for _, clause := range strings.Split(rest, ";") {
+			if err := p.parseClause(clause); err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+		}
+		if len(p.Countries) == 0 {
+			return nil, fmt.Errorf("line %d: profile %q has no countries", n, p.Name)
+		}
+		profiles[p.Name] = p
+	}
+	return profiles, s.Err()
+}
+
+// profilesPath is the default profiles file, in the configuration directory
+// ($XDG_CONFIG_HOME or ~/.config on Linux) rather than in the cache, or empty
+// if there is none.
+func profilesPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "rir", "profiles")
+}
+
+// runProfile prints the prefixes of a profile, aggregated by country with
+// aggregate, or exports them all as one set in an export format.
+func runProfile(ctx context.Context, p queryProfile) {
+	filter := rir.Filter{Countries: p.Countries, Registry: p.Registry, Status: p.Status, Type: p.Family}
+	prefixes := excludeByCountry(filteredPrefixes(ctx, filter))
+
+	if _, ok := exporters[p.Format]; ok {
+		var b netipx.IPSetBuilder
+		for r := range prefixes {
+			b.AddPrefix(r.Prefix)
+		}
+		name := cmp.Or(p.SetName, p.Name)
+		writeExport(ctx, os.Stdout, p.Format, name, check1(b.IPSet()).Prefixes())
+		return
+	}
+
+	if p.Format != "" {
+		check(parseOutputFormat(p.Format))
+	}
+	if p.Aggregate {
+		builders := make(map[string]*netipx.IPSetBuilder)
+		for r := range prefixes {
+			if builders[r.Country] == nil {
+				builders[r.Country] = &netipx.IPSetBuilder{}
+			}
+			builders[r.Country].AddPrefix(r.Prefix)
+		}
+		for _, cc := range p.Countries {
+			if b := builders[cc]; b != nil {
+				for _, prefix := range check1(b.IPSet()).Prefixes() {
+					emit(newCountryPrefix(cc, prefix))
+				}
+			}
+		}
+		return
+	}
+	for r := range prefixes {
+		emit(r)
+	}
+}
+
+// runCommand runs a query profile of the profiles file.
+func runCommand(ctx context.Context, args []string) {
+	fset := flag.NewFlagSet("run", flag.ExitOnError)
+	path := fset.String("profiles", profilesPath(), "file of the query profiles")
+	check(fset.Parse(args))
+	if *path == "" {
+		usageError("No configuration directory to read profiles from, see -profiles")
+	}
+
+	f, err := os.Open(*path)
+	if errors.Is(err, fs.ErrNotExist) {
+		fatalf("No profiles file %s", *path)
+	}
+	check(err)
+	profiles, err := readProfiles(f)
+	f.Close()
+	if err != nil {
+		fatalf("%s: %v", *path, err)
+	}
+
+	if fset.NArg() != 1 {
+		names := slices.Sorted(maps.Keys(profiles))
+		usageError("usage: rir run [-profiles file] profile\nprofiles: %s", strings.Join(names, ", "))
+	}
+	p, ok := profiles[fset.Arg(0)]
+	if !ok {
+		fatalf("No profile %q in %s", fset.Arg(0), *path)
+	}
+	runProfile(ctx, p)
+}
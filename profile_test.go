@@ -0,0 +1,39 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/monoidic/rir/rir"
+)
+
+func TestReadProfiles(t *testing.T) {
+	profiles, err := readProfiles(strings.NewReader(`# profiles
+profile "nordics" = countries FI,SE,NO,DK; format nftables; aggregate
+
+profile "fr6" = country fr; family 6; status allocated
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	nordics := profiles["nordics"]
+	if !slices.Equal(nordics.Countries, []string{"FI", "SE", "NO", "DK"}) || nordics.Format != "nftables" || !nordics.Aggregate {
+		t.Errorf("nordics: got %+v", nordics)
+	}
+	if fr6 := profiles["fr6"]; !slices.Equal(fr6.Countries, []string{"FR"}) || fr6.Family != rir.IPv6 || fr6.Status != "allocated" {
+		t.Errorf("fr6: got %+v", fr6)
+	}
+
+	for _, bad := range []string{
+		`profile nordics = countries FI`,
+		`profile "nordics" countries FI`,
+		`profile "nordics" = countries FI; format yaml`,
+		`profile "nordics" = format plain`,
+		`query "nordics" = countries FI`,
+	} {
+		if _, err := readProfiles(strings.NewReader(bad)); err == nil {
+			t.Errorf("%s: expected an error", bad)
+		}
+	}
+}
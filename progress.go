@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/monoidic/rir/rir"
+)
+
+// eventExportWritten reports a generated export or report.
+const eventExportWritten = "export_written"
+
+// ProgressEvent is a progress event of the rir package, or of an export, as
+// written on stderr with -progress json.
+type ProgressEvent struct {
+	Time time.Time `json:"time"`
+	rir.Event
+	Format  string `json:"format,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Path    string `json:"path,omitempty"`
+	Entries int    `json:"entries,omitempty"`
+}
+
+// progressJSON is set by -progress json.
+var progressJSON bool
+
+func parseProgress(value string) error {
+	if value != "json" {
+		return fmt.Errorf("unknown progress format %q, expected json", value)
+	}
+	progressJSON = true
+	rir.Progress = func(e rir.Event) {
+		writeProgress(ProgressEvent{Event: e})
+	}
+	return nil
+}
+
+// writeProgress prints a progress event on stderr, one JSON object per line,
+// when enabled.
+func writeProgress(e ProgressEvent) {
+	if !progressJSON {
+		return
+	}
+	e.Time = time.Now().UTC()
+	content := check1(json.Marshal(e))
+	os.Stderr.Write(append(content, '\n'))
+}
+
+// exportWritten reports the export of entries in format.
+func exportWritten(format, name, path string, entries int) {
+	writeProgress(ProgressEvent{
+		Event:   rir.Event{Kind: eventExportWritten},
+		Format:  format,
+		Name:    name,
+		Path:    path,
+		Entries: entries,
+	})
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"log"
+	"math/big"
+	"net/netip"
+	"slices"
+
+	"github.com/monoidic/rir/rir"
+	"go4.org/netipx"
+)
+
+// filteredPrefixes yields the prefixes of the ip records selected by filter
+// that are delegated to a country.
+func filteredPrefixes(ctx context.Context, filter rir.Filter) iter.Seq[CountryPrefix] {
+	return func(yield func(CountryPrefix) bool) {
+		for region := range bufferedSeq(retrieveData(ctx), regionBuffer) {
+			for entry := range region.Filter(filter) {
+				iprecord, ok := entry.(rir.IpRecord)
+				// space without a country, reserved or available, is only
+				// listed when asked for by status
+				if !ok || (iprecord.Cc == "" && filter.Status == "") {
+					continue
+				}
+				for net, err := range bufferedSeq2(iprecord.Prefixes(), 10) {
+					if err != nil {
+						report("warning", codeInvalidRecord, &ProviderError{Provider: region.Registry, Err: err})
+						continue
+					}
+					if !yield(recordPrefix(region, iprecord, net)) {
+						return
+					}
+				}
+			}
+		}
+	}
+}
+
+// Query is a query of the command line. Its filter selects the records of
+// country and full listings.
+type Query struct {
+	filter rir.Filter
+	// notCountries are the countries whose space is left out of all the
+	// delegated space, in a negated country query
+	notCountries []string
+	addrs        []netip.Addr
+	// hosts are the queried hostnames of the addresses they resolved to
+	hosts map[netip.Addr]string
+	// prefixes and ranges are queried for the delegations overlapping them
+	prefixes   []netip.Prefix
+	ranges     []netipx.IPRange
+	asn        *int
+	hostscount bool
+	asns       bool
+	// best keeps only the most specific delegation of an address
+	best bool
+	// regions are the loaded records of every provider, when loaded once
+	// for several lookups
+	regions []rir.Records
+}
+
+func (q Query) IsHolderQuery() bool {
+	return q.filter.OpaqueId != ""
+}
+
+func (q Query) IsCountryQuery() bool {
+	return len(q.filter.Countries) > 0 || len(q.notCountries) > 0
+}
+
+// queried is the number of queried addresses, prefixes and ranges.
+func (q Query) queried() int {
+	return len(q.addrs) + len(q.prefixes) + len(q.ranges)
+}
+
+func (q Query) IsIpQuery() bool {
+	return len(q.addrs) > 0 || len(q.prefixes) > 0 || len(q.ranges) > 0
+}
+
+func (q Query) IsAsnQuery() bool {
+	return q.asn != nil
+}
+
+func (q Query) readRegionsCountry(ctx context.Context) iter.Seq[CountryPrefix] {
+	return filteredPrefixes(ctx, q.filter)
+}
+
+// loadedRegions yields the records of every provider, loaded once for
+// several lookups or as they come.
+func (q Query) loadedRegions(ctx context.Context) iter.Seq[rir.Records] {
+	if q.regions != nil {
+		return slices.Values(q.regions)
+	}
+	return bufferedSeq(retrieveData(ctx), regionBuffer)
+}
+
+// lookup yields the delegations containing addr, or with -best only the
+// most specific one.
+func (q Query) lookup(ctx context.Context, addr netip.Addr) iter.Seq[CountryPrefix] {
+	if !q.best {
+		return q.matchOnIp(ctx, addr)
+	}
+	return func(yield func(CountryPrefix) bool) {
+		if best, ok := bestMatch(addr, slices.Collect(q.matchOnIp(ctx, addr))); ok {
+			yield(best)
+		}
+	}
+}
+
+// bestMatch returns the most specific of the delegations containing addr,
+// the first one listed among equally specific ones, warning when they do not
+// agree on the country.
+func bestMatch(addr netip.Addr, matches []CountryPrefix) (CountryPrefix, bool) {
+	if len(matches) == 0 {
+		return CountryPrefix{}, false
+	}
+	best := matches[0]
+	for _, match := range matches[1:] {
+		if match.Prefix.Bits() > best.Prefix.Bits() {
+			best = match
+		}
+	}
+	for _, match := range matches {
+		if match.Country != best.Country {
+			log.Printf("Registries disagree on %s: %s %s in %s, %s %s in %s, keeping the most specific", addr,
+				best.Country, best.Prefix, best.record.Registry, match.Country, match.Prefix, match.record.Registry)
+		}
+	}
+	return best, true
+}
+
+func (q Query) matchOnIp(ctx context.Context, addr netip.Addr) iter.Seq[CountryPrefix] {
+	return func(yield func(CountryPrefix) bool) {
+		for region := range q.loadedRegions(ctx) {
+			for iprecord, net := range region.Lookup(addr) {
+				if !yield(recordPrefix(region, iprecord, net)) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// matchOnAsn yields the delegation of the AS number in each registry holding
+// it, normally a single one.
+func (q Query) matchOnAsn(ctx context.Context) iter.Seq[AsnDelegation] {
+	return func(yield func(AsnDelegation) bool) {
+		for region := range bufferedSeq(retrieveData(ctx), regionBuffer) {
+			if r, ok := region.Asn(*q.asn); ok && !yield(newAsnDelegation(r)) {
+				return
+			}
+		}
+	}
+}
+
+// CountryAsn is an AS number delegated to a country.
+type CountryAsn struct {
+	Country  string `json:"country"`
+	Asn      int    `json:"asn"`
+	Registry string `json:"registry"`
+}
+
+func (a CountryAsn) String() string {
+	return tsvLine(a.Country, fmt.Sprintf("AS%d", a.Asn))
+}
+
+// countryAsns yields every AS number of the country, ranges expanded.
+func (q Query) countryAsns(ctx context.Context) iter.Seq[CountryAsn] {
+	filter := q.filter
+	filter.Type = rir.ASN
+	return func(yield func(CountryAsn) bool) {
+		for region := range bufferedSeq(retrieveData(ctx), regionBuffer) {
+			for entry := range region.Filter(filter) {
+				r := entry.(rir.AsnRecord)
+				for asn := r.Start; asn < r.Start+r.Value; asn++ {
+					if !yield(CountryAsn{Country: r.Cc, Asn: asn, Registry: r.Registry}) {
+						return
+					}
+				}
+			}
+		}
+	}
+}
+
+// CountryStats is the number of addresses delegated to a country.
+type CountryStats struct {
+	Country     string   `json:"country"`
+	CountryName string   `json:"country_name,omitempty"`
+	V4          *big.Int `json:"v4"`
+	V6          *big.Int `json:"v6"`
+
+	tagged bool
+}
+
+func (s CountryStats) String() string {
+	if s.CountryName != "" {
+		return fmt.Sprintf("%s\nv4: %s\nv6: %s", s.CountryName, s.V4, s.V6)
+	}
+	if s.tagged {
+		return fmt.Sprintf("%s\nv4: %s\nv6: %s", s.Country, s.V4, s.V6)
+	}
+	return fmt.Sprintf("v4: %s\nv6: %s", s.V4, s.V6)
+}
+
+func (s CountryStats) csvRow() []string {
+	return []string{s.Country, s.CountryName, s.V4.String(), s.V6.String()}
+}
+
+// countryStats counts the addresses of each queried country, in the order
+// of the query.
+func (q Query) countryStats(ctx context.Context) []CountryStats {
+	stats := make(map[string]CountryStats)
+	for _, cc := range q.filter.Countries {
+		stats[cc] = CountryStats{Country: cc, CountryName: countryName(cc), V4: big.NewInt(0), V6: big.NewInt(0)}
+	}
+	netHosts := big.NewInt(0)
+	one := big.NewInt(1)
+
+	for r := range excludeByCountry(bufferedSeq(q.readRegionsCountry(ctx), 10)) {
+		countV4, countV6 := stats[r.Country].V4, stats[r.Country].V6
+		ones := r.Prefix.Bits()
+		addr := r.Prefix.Addr()
+		var count *big.Int
+		var size int
+
+		if addr.Is4() {
+			count = countV4
+			size = 32
+		} else {
+			count = countV6
+			size = 128
+		}
+
+		if mask := uint(size - ones); mask > 0 {
+			count.Add(count, netHosts.Lsh(one, mask))
+		}
+	}
+
+	var result []CountryStats
+	for _, cc := range q.filter.Countries {
+		if s, ok := stats[cc]; ok {
+			// with several countries, tell them apart even without names
+			s.tagged = len(q.filter.Countries) > 1
+			result = append(result, s)
+			delete(stats, cc)
+		}
+	}
+	return result
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	rdnsWorkers = 8
+	rdnsTimeout = 5 * time.Second
+)
+
+// reverseDNS resolves the PTR records of addrs with a bounded pool of
+// workers. Addresses without a PTR record, or whose lookup failed, are
+// missing from the result.
+func reverseDNS(ctx context.Context, addrs []netip.Addr) map[netip.Addr][]string {
+	jobs := make(chan netip.Addr)
+	names := make(map[netip.Addr][]string, len(addrs))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	// at least one worker, or sending the first job blocks forever
+	for range max(min(rdnsWorkers, len(addrs)), 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for addr := range jobs {
+				ctx, cancel := context.WithTimeout(ctx, rdnsTimeout)
+				ptrs, err := net.DefaultResolver.LookupAddr(ctx, addr.String())
+				cancel()
+				if err != nil || len(ptrs) == 0 {
+					continue
+				}
+				for i, ptr := range ptrs {
+					ptrs[i] = strings.TrimSuffix(ptr, ".")
+				}
+				mu.Lock()
+				names[addr] = ptrs
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, addr := range addrs {
+		jobs <- addr
+	}
+	close(jobs)
+	wg.Wait()
+
+	return names
+}
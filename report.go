@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"flag"
+	htmltemplate "html/template"
+	"math/big"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/monoidic/rir/rir"
+)
+
+type registryStats struct {
+	Registry    string
+	Ipv4Records int
+	Ipv4Count   int
+	Ipv6Records int
+	Ipv6Count   *big.Int
+	AsnRecords  int
+	AsnCount    int
+}
+
+func (s *registryStats) add(records rir.Records, country string) {
+	for _, ip := range records.Ips {
+		if ip.Cc != country {
+			continue
+		}
+		switch ip.Type {
+		case rir.IPv4:
+			s.Ipv4Records++
+			s.Ipv4Count += ip.Value
+		case rir.IPv6:
+			s.Ipv6Records++
+			s.Ipv6Count.Add(s.Ipv6Count, new(big.Int).Lsh(big.NewInt(1), uint(128-ip.Value)))
+		}
+	}
+	for _, asn := range records.Asns {
+		if asn.Cc == country {
+			s.AsnRecords++
+			s.AsnCount += asn.Value
+		}
+	}
+}
+
+type keyCount struct {
+	Key   string
+	Count int
+	// Bar is the length of the key's bar in charts, out of 100
+	Bar int
+}
+
+type countryReport struct {
+	Country     string
+	Name        string
+	Generated   string
+	Registries  []registryStats
+	Total       registryStats
+	Statuses    []keyCount
+	Years       []keyCount
+	Largest     []rir.IpRecord
+	Recent      []rir.IpRecord
+	HasPrevious bool
+	Added       []string
+	Removed     []string
+}
+
+// recordKey identifies a delegation across snapshots.
+func recordKey(ip rir.IpRecord) string {
+	return ip.Start.String() + "|" + strconv.Itoa(ip.Value)
+}
+
+func withBars(counts map[string]int) []keyCount {
+	var result []keyCount
+	highest := 0
+	for key, count := range counts {
+		result = append(result, keyCount{Key: key, Count: count})
+		highest = max(highest, count)
+	}
+	for i := range result {
+		result[i].Bar = result[i].Count * 100 / max(highest, 1)
+	}
+	slices.SortFunc(result, func(a, b keyCount) int { return strings.Compare(a.Key, b.Key) })
+	return result
+}
+
+// previousRecords parses the snapshot preceding the latest file, if any.
+func previousRecords(p rir.CachedProvider) (rir.Records, bool) {
+	serial, err := p.PreviousSerial()
+	if err != nil {
+		return rir.Records{}, false
+	}
+	content := check1(rir.ReadStored(p.SnapshotKey(serial)))
+	return applyOverlay(check1(rir.NewLimitedReader(bytes.NewReader(content), rir.ReaderLimits).Read())), true
+}
+
+func buildCountryReport(ctx context.Context, country string) countryReport {
+	report := countryReport{
+		Country:   country,
+		Name:      countryName(country),
+		Generated: time.Now().UTC().Format(time.RFC1123),
+		Total:     registryStats{Registry: "total", Ipv6Count: big.NewInt(0)},
+	}
+	statuses := make(map[string]int)
+	years := make(map[string]int)
+	current := make(map[string]rir.IpRecord)
+	previous := make(map[string]rir.IpRecord)
+	var ips []rir.IpRecord
+
+	for _, provider := range rir.AllProviders {
+		records := applyOverlay(check1(provider.Records(ctx)))
+		stats := registryStats{Registry: provider.Name(), Ipv6Count: big.NewInt(0)}
+		stats.add(records, country)
+		report.Total.add(records, country)
+		report.Registries = append(report.Registries, stats)
+
+		// only registries with a previous snapshot take part in the changes
+		prev, hasPrevious := previousRecords(provider)
+		report.HasPrevious = report.HasPrevious || hasPrevious
+
+		for _, ip := range records.Ips {
+			if ip.Cc != country {
+				continue
+			}
+			ips = append(ips, ip)
+			statuses[ip.Status]++
+			if len(ip.Date) >= 4 {
+				years[ip.Date[:4]]++
+			}
+			if hasPrevious {
+				current[recordKey(ip)] = ip
+			}
+		}
+
+		for _, ip := range prev.Ips {
+			if ip.Cc == country {
+				previous[recordKey(ip)] = ip
+			}
+		}
+	}
+
+	report.Statuses = withBars(statuses)
+	report.Years = withBars(years)
+
+	var ipv4 []rir.IpRecord
+	for _, ip := range ips {
+		if ip.Type == rir.IPv4 {
+			ipv4 = append(ipv4, ip)
+		}
+	}
+	slices.SortFunc(ipv4, func(a, b rir.IpRecord) int { return cmp.Compare(b.Value, a.Value) })
+	report.Largest = ipv4[:min(10, len(ipv4))]
+
+	slices.SortFunc(ips, func(a, b rir.IpRecord) int { return strings.Compare(b.Date, a.Date) })
+	report.Recent = ips[:min(10, len(ips))]
+
+	if report.HasPrevious {
+		for key, ip := range current {
+			if _, ok := previous[key]; !ok {
+				report.Added = append(report.Added, ip.Start.String()+" ("+ip.Type+" "+strconv.Itoa(ip.Value)+")")
+			}
+		}
+		for key, ip := range previous {
+			if _, ok := current[key]; !ok {
+				report.Removed = append(report.Removed, ip.Start.String()+" ("+ip.Type+" "+strconv.Itoa(ip.Value)+")")
+			}
+		}
+		slices.Sort(report.Added)
+		slices.Sort(report.Removed)
+	}
+
+	return report
+}
+
+var reportFuncs = map[string]any{
+	"bar": func(width int) string { return strings.Repeat("█", max(width/4, 1)) },
+}
+
+const markdownReport = `# RIR report for {{.Country}}{{with .Name}} ({{.}}){{end}}
+
+Generated {{.Generated}} from the registries' delegated statistics.
+
+## Statistics
+
+| Registry | rir.IPv4 records | rir.IPv4 addresses | rir.IPv6 records | rir.IPv6 addresses | rir.ASN records | ASNs |
+|---|---:|---:|---:|---:|---:|---:|
+{{range .Registries}}| {{.Registry}} | {{.Ipv4Records}} | {{.Ipv4Count}} | {{.Ipv6Records}} | {{.Ipv6Count}} | {{.AsnRecords}} | {{.AsnCount}} |
+{{end}}{{with .Total}}| **{{.Registry}}** | **{{.Ipv4Records}}** | **{{.Ipv4Count}}** | **{{.Ipv6Records}}** | **{{.Ipv6Count}}** | **{{.AsnRecords}}** | **{{.AsnCount}}** |{{end}}
+
+## Status
+
+| Status | rir.Records |
+|---|---:|
+{{range .Statuses}}| {{.Key}} | {{.Count}} |
+{{end}}
+## Delegations per year
+
+` + "```" + `
+{{range .Years}}{{.Key}} {{bar .Bar}} {{.Count}}
+{{end}}` + "```" + `
+
+## Largest rir.IPv4 delegations
+
+| Start | Addresses | Registry | Date | Status |
+|---|---:|---|---|---|
+{{range .Largest}}| {{.Start}} | {{.Value}} | {{.Registry}} | {{.Date}} | {{.Status}} |
+{{end}}
+## Most recent delegations
+
+| Start | Type | Size | Registry | Date | Status |
+|---|---|---:|---|---|---|
+{{range .Recent}}| {{.Start}} | {{.Type}} | {{.Value}} | {{.Registry}} | {{.Date}} | {{.Status}} |
+{{end}}
+## Changes since the previous snapshot
+
+{{if not .HasPrevious}}No previous snapshot available.
+{{else}}{{range .Added}}- added {{.}}
+{{end}}{{range .Removed}}- removed {{.}}
+{{else}}{{if not .Added}}No changes.
+{{end}}{{end}}{{end}}`
+
+const htmlReport = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>RIR report for {{.Country}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; }
+td.n { text-align: right; }
+.bar { fill: #4878a8; }
+</style>
+</head>
+<body>
+<h1>RIR report for {{.Country}}{{with .Name}} ({{.}}){{end}}</h1>
+<p>Generated {{.Generated}} from the registries' delegated statistics.</p>
+
+<h2>Statistics</h2>
+<table>
+<tr><th>Registry</th><th>rir.IPv4 records</th><th>rir.IPv4 addresses</th><th>rir.IPv6 records</th><th>rir.IPv6 addresses</th><th>rir.ASN records</th><th>ASNs</th></tr>
+{{range .Registries}}<tr><td>{{.Registry}}</td><td class="n">{{.Ipv4Records}}</td><td class="n">{{.Ipv4Count}}</td><td class="n">{{.Ipv6Records}}</td><td class="n">{{.Ipv6Count}}</td><td class="n">{{.AsnRecords}}</td><td class="n">{{.AsnCount}}</td></tr>
+{{end}}{{with .Total}}<tr><th>{{.Registry}}</th><th>{{.Ipv4Records}}</th><th>{{.Ipv4Count}}</th><th>{{.Ipv6Records}}</th><th>{{.Ipv6Count}}</th><th>{{.AsnRecords}}</th><th>{{.AsnCount}}</th></tr>{{end}}
+</table>
+
+<h2>Status</h2>
+<table>
+<tr><th>Status</th><th>rir.Records</th></tr>
+{{range .Statuses}}<tr><td>{{.Key}}</td><td class="n">{{.Count}}</td></tr>
+{{end}}</table>
+
+<h2>Delegations per year</h2>
+<svg width="640" height="{{len .Years | mul 18}}" xmlns="http://www.w3.org/2000/svg">
+{{range $i, $year := .Years}}<text x="0" y="{{mul 18 $i | add 13}}" font-size="12">{{$year.Key}}</text><rect class="bar" x="40" y="{{mul 18 $i | add 2}}" width="{{mul 5 $year.Bar}}" height="14"/><text x="{{mul 5 $year.Bar | add 45}}" y="{{mul 18 $i | add 13}}" font-size="12">{{$year.Count}}</text>
+{{end}}</svg>
+
+<h2>Largest rir.IPv4 delegations</h2>
+<table>
+<tr><th>Start</th><th>Addresses</th><th>Registry</th><th>Date</th><th>Status</th></tr>
+{{range .Largest}}<tr><td>{{.Start}}</td><td class="n">{{.Value}}</td><td>{{.Registry}}</td><td>{{.Date}}</td><td>{{.Status}}</td></tr>
+{{end}}</table>
+
+<h2>Most recent delegations</h2>
+<table>
+<tr><th>Start</th><th>Type</th><th>Size</th><th>Registry</th><th>Date</th><th>Status</th></tr>
+{{range .Recent}}<tr><td>{{.Start}}</td><td>{{.Type}}</td><td class="n">{{.Value}}</td><td>{{.Registry}}</td><td>{{.Date}}</td><td>{{.Status}}</td></tr>
+{{end}}</table>
+
+<h2>Changes since the previous snapshot</h2>
+{{if not .HasPrevious}}<p>No previous snapshot available.</p>
+{{else if or .Added .Removed}}<ul>
+{{range .Added}}<li>added {{.}}</li>
+{{end}}{{range .Removed}}<li>removed {{.}}</li>
+{{end}}</ul>
+{{else}}<p>No changes.</p>
+{{end}}</body>
+</html>
+`
+
+func renderReport(report countryReport, format string) []byte {
+	var out bytes.Buffer
+	switch format {
+	case "md":
+		t := template.Must(template.New("report").Funcs(reportFuncs).Parse(markdownReport))
+		check(t.Execute(&out, report))
+	case "html":
+		funcs := htmltemplate.FuncMap{
+			"add": func(a, b int) int { return a + b },
+			"mul": func(a, b int) int { return a * b },
+		}
+		t := htmltemplate.Must(htmltemplate.New("report").Funcs(funcs).Parse(htmlReport))
+		check(t.Execute(&out, report))
+	default:
+		usageError("unknown report format %q, expected html or md", format)
+	}
+	return out.Bytes()
+}
+
+// reportCommand writes a self-contained report on the address space of a
+// country, suitable for attaching to tickets or compliance documents.
+func reportCommand(ctx context.Context, args []string) {
+	fset := flag.NewFlagSet("report", flag.ExitOnError)
+	country := fset.String("country", "", "2 letters string of the country (ISO 3166)")
+	format := fset.String("format", "md", "report format: html or md")
+	output := fset.String("output", "", "file to write the report to instead of stdout")
+	check(fset.Parse(args))
+
+	if *country == "" {
+		usageError("usage: rir report -country CC [-format html|md] [-output file]")
+	}
+
+	content := renderReport(buildCountryReport(ctx, strings.ToUpper(*country)), *format)
+	if *output == "" {
+		check1(os.Stdout.Write(content))
+		return
+	}
+	check(os.WriteFile(*output, content, 0o644))
+	check(signArtifact(*output, content))
+	exportWritten(*format, "report", *output, 0)
+}
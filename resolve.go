@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/monoidic/rir/rir"
+)
+
+var (
+	resolveWorkers = 8
+	// dnsTTL is how long resolved hostnames are cached.
+	dnsTTL = time.Hour
+)
+
+type dnsEntry struct {
+	Addrs   []netip.Addr `json:"addrs"`
+	Fetched time.Time    `json:"fetched"`
+}
+
+// dnsCachePath is the file of the hostnames resolved so far, so that they
+// are not resolved again on every run.
+func dnsCachePath() string {
+	return filepath.Join(rir.GetCacheDir(), "dns.json")
+}
+
+func loadDNSCache() map[string]dnsEntry {
+	cache := make(map[string]dnsEntry)
+	content, err := os.ReadFile(dnsCachePath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return cache
+	}
+	check(err)
+	check(json.Unmarshal(content, &cache))
+	return cache
+}
+
+// resolveHosts resolves the addresses of hosts, from the cache or with a
+// bounded pool of workers. Hosts that cannot be resolved are missing from
+// the result and not cached.
+func resolveHosts(ctx context.Context, hosts []string) map[string][]netip.Addr {
+	cache := loadDNSCache()
+	addrs := make(map[string][]netip.Addr, len(hosts))
+	var missing []string
+	for _, host := range hosts {
+		if entry, ok := cache[host]; ok && time.Since(entry.Fetched) < dnsTTL {
+			addrs[host] = entry.Addrs
+		} else if _, queued := addrs[host]; !queued {
+			addrs[host] = nil
+			missing = append(missing, host)
+		}
+	}
+
+	jobs := make(chan string)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	// at least one worker, or sending the first job blocks forever
+	for range max(min(resolveWorkers, len(missing)), 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for host := range jobs {
+				ctx, cancel := context.WithTimeout(ctx, rdnsTimeout)
+				resolved, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+				cancel()
+				mu.Lock()
+				if err == nil && len(resolved) > 0 {
+					for i, addr := range resolved {
+						resolved[i] = addr.Unmap()
+					}
+					addrs[host] = resolved
+					cache[host] = dnsEntry{Addrs: resolved, Fetched: time.Now().UTC()}
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, host := range missing {
+		jobs <- host
+	}
+	close(jobs)
+	wg.Wait()
+
+	if len(missing) > 0 {
+		for host, entry := range cache {
+			if time.Since(entry.Fetched) >= dnsTTL {
+				delete(cache, host)
+			}
+		}
+		tmp := dnsCachePath() + ".tmp"
+		check(os.WriteFile(tmp, check1(json.Marshal(cache)), 0o600))
+		check(os.Rename(tmp, dnsCachePath()))
+	}
+	return addrs
+}
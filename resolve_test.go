@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/netip"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/monoidic/rir/rir"
+)
+
+func TestResolveHostsCache(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	check(os.MkdirAll(rir.GetCacheDir(), 0o700))
+	cached := map[string]dnsEntry{
+		"cached.invalid":  {Addrs: []netip.Addr{netip.MustParseAddr("192.0.2.1")}, Fetched: time.Now()},
+		"expired.invalid": {Addrs: []netip.Addr{netip.MustParseAddr("192.0.2.2")}, Fetched: time.Now().Add(-2 * dnsTTL)},
+	}
+	check(os.WriteFile(dnsCachePath(), check1(json.Marshal(cached)), 0o600))
+
+	// a worker is started whatever -resolve-workers says
+	defer func(n int) { resolveWorkers = n }(resolveWorkers)
+	resolveWorkers = 0
+
+	addrs := resolveHosts(context.Background(), []string{"cached.invalid", "expired.invalid"})
+	if !slices.Equal(addrs["cached.invalid"], cached["cached.invalid"].Addrs) {
+		t.Errorf("cached.invalid: got %v", addrs["cached.invalid"])
+	}
+	// .invalid never resolves, so the expired entry is gone
+	if len(addrs["expired.invalid"]) != 0 {
+		t.Errorf("expired.invalid: got %v", addrs["expired.invalid"])
+	}
+	if _, ok := loadDNSCache()["expired.invalid"]; ok {
+		t.Errorf("expired entry kept in the cache")
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/monoidic/rir/rir"
+)
+
+var (
+	// addressFamily is rir.IPv4 or rir.IPv6 to restrict listings to one
+	// family, set by -4 and -6
+	addressFamily      string
+	allowPartial       bool
+	requireAll         bool
+	normalizeCountries bool
+	partialFailure     atomic.Bool
+)
+
+// exitPartial is the exit status when results were produced without the data
+// of every provider.
+const exitPartial = 3
+
+// retrieveRecords yields the records of every provider, in order, or the
+// error preventing a provider from being loaded, leaving the consumer to skip
+// or report it.
+func retrieveRecords(ctx context.Context) iter.Seq2[rir.Records, error] {
+	return func(yield func(rir.Records, error) bool) {
+		if bootstrapURL != "" {
+			all, err := fetchBootstrap(ctx, bootstrapURL)
+			if err != nil {
+				yield(rir.Records{}, &ProviderError{Provider: "bootstrap", Err: err})
+				return
+			}
+			for _, records := range all {
+				records = postprocessRecords(records)
+				noteLoaded(records)
+				if !yield(records, nil) {
+					return
+				}
+			}
+			return
+		}
+
+		type loaded struct {
+			records rir.Records
+			err     error
+		}
+		results := parallelMap(rir.AllProviders, func(p rir.CachedProvider) loaded {
+			records, err := tryRecords(ctx, p)
+			return loaded{records, err}
+		})
+		for result := range results {
+			if result.err == nil {
+				noteLoaded(result.records)
+			}
+			if !yield(result.records, result.err) {
+				return
+			}
+		}
+	}
+}
+
+// retrieveData yields the records of every provider, in order, failing or
+// skipping providers that cannot be loaded according to -allow-partial and
+// -require-all.
+func retrieveData(ctx context.Context) iter.Seq[rir.Records] {
+	return func(yield func(rir.Records) bool) {
+		if requireAll {
+			// load everything up front so that nothing is emitted if any
+			// provider fails
+			var all []rir.Records
+			for records, err := range retrieveRecords(ctx) {
+				if err != nil {
+					fatal(codeProviderFailed, err)
+				}
+				all = append(all, records)
+			}
+			for _, records := range all {
+				if !yield(records) {
+					return
+				}
+			}
+			return
+		}
+
+		for records, err := range retrieveRecords(ctx) {
+			if err != nil && !allowPartial {
+				fatal(codeProviderFailed, err)
+			}
+			if err != nil {
+				report("warning", codeProviderSkipped, err)
+				partialFailure.Store(true)
+				continue
+			}
+			if !yield(records) {
+				return
+			}
+		}
+	}
+}
+
+// parseProviderTimeout parses a name=duration flag value.
+func parseProviderTimeout(value string) error {
+	name, duration, ok := strings.Cut(value, "=")
+	if !ok {
+		return fmt.Errorf("expected provider=duration, got %q", value)
+	}
+	if _, ok := rir.FindProvider(name); !ok {
+		return fmt.Errorf("unknown provider %q", name)
+	}
+	timeout, err := time.ParseDuration(duration)
+	if err != nil {
+		return err
+	}
+	rir.ProviderTimeouts[name] = timeout
+	return nil
+}
+
+// tryRecords returns the data of a provider with the overlay applied, or the
+// reason it cannot be fetched or parsed.
+func tryRecords(ctx context.Context, p rir.CachedProvider) (rir.Records, error) {
+	records, err := p.Records(ctx)
+	if err != nil {
+		return rir.Records{}, &ProviderError{Provider: p.Name(), Err: err}
+	}
+	return postprocessRecords(records), nil
+}
+
+// postprocessRecords applies -normalize-cc and -overlay to loaded records.
+func postprocessRecords(records rir.Records) rir.Records {
+	if normalizeCountries {
+		records = records.NormalizeCountries()
+	}
+	return applyOverlay(records)
+}
+
+func exitStatus() {
+	if partialFailure.Load() {
+		os.Exit(exitPartial)
+	}
+}
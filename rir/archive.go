@@ -0,0 +1,92 @@
+package rir
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// archiveLayouts are the paths of the files of past serials relative to the
+// directory of the latest file of each registry, {year} and {date} being
+// replaced by those of the file. Registries archive them in yearly
+// directories, some of them compressed.
+var archiveLayouts = map[string]string{
+	"afrinic": "{year}/delegated-afrinic-extended-{date}",
+	"apnic":   "{year}/delegated-apnic-extended-{date}.gz",
+	"arin":    "archive/{year}/delegated-arin-extended-{date}",
+	"lacnic":  "delegated-lacnic-extended-{date}",
+	"ripencc": "{year}/delegated-ripencc-extended-{date}.bz2",
+}
+
+// archiveDate returns the date a registry file is published under: its
+// serial when it is a YYYYMMDD date, or the day of its serial in the time
+// zone of the file when it is a UNIX timestamp, as for ARIN and the RIPE NCC.
+func archiveDate(version Version) (time.Time, error) {
+	if t, err := time.Parse("20060102", version.Serial); err == nil {
+		return t, nil
+	}
+	if seconds, err := strconv.ParseInt(version.Serial, 10, 64); err == nil {
+		loc := time.UTC
+		if offset, err := time.Parse("-0700", version.UtcOffset); err == nil {
+			loc = offset.Location()
+		}
+		return time.Unix(seconds, 0).In(loc), nil
+	}
+	return time.Time{}, fmt.Errorf("rir: no archive date for serial %q", version.Serial)
+}
+
+// ArchiveURL is where a registry publishes the file of a given version once
+// it is no longer the latest one.
+func (p CachedProvider) ArchiveURL(version Version) (string, error) {
+	layout, ok := archiveLayouts[p.Name()]
+	if !ok {
+		return "", fmt.Errorf("rir: no archive layout for %s", p.Name())
+	}
+	date, err := archiveDate(version)
+	if err != nil {
+		return "", err
+	}
+	file := strings.NewReplacer("{year}", date.Format("2006"), "{date}", date.Format("20060102")).Replace(layout)
+	return p.url[:strings.LastIndex(p.url, "/")+1] + file, nil
+}
+
+// FetchArchive downloads an archived file, decompressing it according to
+// its extension.
+func (p CachedProvider) FetchArchive(ctx context.Context, url string) ([]byte, error) {
+	resp, err := p.Get(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, &HTTPError{URL: url, StatusCode: resp.StatusCode}
+	}
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return decompressArchive(url, content)
+}
+
+func decompressArchive(url string, content []byte) ([]byte, error) {
+	var r io.Reader
+	switch {
+	case strings.HasSuffix(url, ".bz2"):
+		r = bzip2.NewReader(bytes.NewReader(content))
+	case strings.HasSuffix(url, ".gz"):
+		gz, err := gzip.NewReader(bytes.NewReader(content))
+		if err != nil {
+			return nil, err
+		}
+		r = gz
+	default:
+		return content, nil
+	}
+	return io.ReadAll(r)
+}
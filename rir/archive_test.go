@@ -0,0 +1,65 @@
+package rir
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestArchiveURL(t *testing.T) {
+	for _, test := range []struct {
+		version Version
+		want    string
+	}{
+		{Version{Registry: "afrinic", Serial: "20240101"}, "https://ftp.ripe.net/pub/stats/afrinic/2024/delegated-afrinic-extended-20240101"},
+		{Version{Registry: "apnic", Serial: "20240101"}, "https://ftp.ripe.net/pub/stats/apnic/2024/delegated-apnic-extended-20240101.gz"},
+		// the serial of ARIN and the RIPE NCC is the timestamp of the
+		// file, in its time zone
+		{Version{Registry: "arin", Serial: "1714017601", UtcOffset: "-0400"}, "https://ftp.ripe.net/pub/stats/arin/archive/2024/delegated-arin-extended-20240425"},
+		{Version{Registry: "lacnic", Serial: "20231231"}, "https://ftp.ripe.net/pub/stats/lacnic/delegated-lacnic-extended-20231231"},
+		{Version{Registry: "ripencc", Serial: "1704063599", UtcOffset: "+0100"}, "https://ftp.ripe.net/pub/stats/ripencc/2023/delegated-ripencc-extended-20231231.bz2"},
+	} {
+		p, _ := FindProvider(test.version.Registry)
+		got, err := p.ArchiveURL(test.version)
+		if err != nil || got != test.want {
+			t.Errorf("%s: got %q, %v, want %q", test.version.Registry, got, err, test.want)
+		}
+	}
+
+	p, _ := FindProvider("ripencc")
+	if _, err := p.ArchiveURL(Version{}); err == nil {
+		t.Error("archive URL without serial")
+	}
+}
+
+func TestFetchArchive(t *testing.T) {
+	const content = "2|ripencc|20240101|0|19830705|20231231|+0100\n"
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write([]byte(content))
+	w.Close()
+	bz2, _ := base64.StdEncoding.DecodeString("QlpoOTFBWSZTWaqu26MAAAvZgAAQAAh+4AohUAQgACGptQekAaFNMjExMQw1KI2F3SBLEhqs+FKYzXu/R6NIXh8/F3JFOFCQqq7bow==")
+	// on different hosts to avoid waiting for the fetch interval
+	archives := map[string][]byte{
+		"https://apnic.example/2024/file.gz":    gz.Bytes(),
+		"https://ripencc.example/2024/file.bz2": bz2,
+		"https://lacnic.example/file":           []byte(content),
+	}
+
+	defer func(client *http.Client) { HTTPClient = client }(HTTPClient)
+	HTTPClient = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(archives[req.URL.String()])), Request: req}, nil
+	})}
+
+	p := NewCachedProvider("test", "https://registry.example/latest")
+	for url := range archives {
+		got, err := p.FetchArchive(context.Background(), url)
+		if err != nil || string(got) != content {
+			t.Errorf("%s: got %q, %v", url, got, err)
+		}
+	}
+}
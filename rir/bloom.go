@@ -0,0 +1,188 @@
+package rir
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"math/bits"
+	"net/netip"
+)
+
+// A PrefixFilter is a Bloom filter over a set of prefixes, such as the space
+// of a country, answering "probably in the set" for addresses in constant
+// time and a few bytes per prefix. Addresses of the set are always reported;
+// others are reported with about the false positive rate the filter was
+// built for. It is safe for concurrent lookups.
+//
+// Prefixes are stored as is rather than as their addresses, and a lookup
+// probes the prefix of the address at each prefix length present in the set,
+// so the set should be aggregated first to keep both the filter and the
+// number of probes small.
+type PrefixFilter struct {
+	hashes    uint32
+	v4Lengths uint64    // bit i set when IPv4 prefixes of length i are present
+	v6Lengths [3]uint64 // same for IPv6
+	bits      []uint64
+	size      uint64 // number of bits
+}
+
+// The serialized filter is laid out as
+//
+//	magic      [8]byte
+//	version    uint32
+//	hashes     uint32
+//	v4 lengths uint64
+//	v6 lengths [3]uint64
+//	size       uint64    number of bits
+//	bits       [size/64]uint64
+//
+// in big endian.
+const (
+	prefixFilterMagic   = "RIRBLOOM"
+	prefixFilterVersion = 1
+)
+
+var ErrPrefixFilterCorrupt = errors.New("rir: prefix filter is corrupt")
+
+type prefixFilterHeader struct {
+	Magic     [8]byte
+	Version   uint32
+	Hashes    uint32
+	V4Lengths uint64
+	V6Lengths [3]uint64
+	Size      uint64
+}
+
+// NewPrefixFilter builds a filter over prefixes whose lookups of addresses out
+// of them succeed with the given rate, between 0 and 1.
+func NewPrefixFilter(prefixes []netip.Prefix, falsePositiveRate float64) (*PrefixFilter, error) {
+	if falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+		return nil, fmt.Errorf("rir: false positive rate %g out of (0, 1)", falsePositiveRate)
+	}
+	f := &PrefixFilter{}
+	for _, prefix := range prefixes {
+		f.setLength(prefix)
+	}
+
+	// every probe of a lookup may be a false positive
+	probes := max(bits.OnesCount64(f.v4Lengths), bits.OnesCount64(f.v6Lengths[0])+bits.OnesCount64(f.v6Lengths[1])+bits.OnesCount64(f.v6Lengths[2]), 1)
+	rate := falsePositiveRate / float64(probes)
+	n := float64(max(len(prefixes), 1))
+	size := uint64(math.Ceil(-n * math.Log(rate) / (math.Ln2 * math.Ln2)))
+	size = (size + 63) / 64 * 64
+	f.size = size
+	f.bits = make([]uint64, size/64)
+	f.hashes = uint32(max(1, math.Round(float64(size)/n*math.Ln2)))
+
+	for _, prefix := range prefixes {
+		h1, h2 := prefixHashes(prefix.Masked())
+		for i := range uint64(f.hashes) {
+			bit := (h1 + i*h2) % f.size
+			f.bits[bit/64] |= 1 << (bit % 64)
+		}
+	}
+	return f, nil
+}
+
+func (f *PrefixFilter) setLength(prefix netip.Prefix) {
+	if prefix.Addr().Is4() {
+		f.v4Lengths |= 1 << prefix.Bits()
+	} else {
+		f.v6Lengths[prefix.Bits()/64] |= 1 << (prefix.Bits() % 64)
+	}
+}
+
+func (f *PrefixFilter) hasLength(addr netip.Addr, length int) bool {
+	if addr.Is4() {
+		return f.v4Lengths&(1<<length) != 0
+	}
+	return f.v6Lengths[length/64]&(1<<(length%64)) != 0
+}
+
+// prefixHashes derives the two hashes of the double hashing of prefix.
+func prefixHashes(prefix netip.Prefix) (uint64, uint64) {
+	key, _ := prefix.MarshalBinary()
+	h := fnv.New64a()
+	h.Write(key)
+	h1 := h.Sum64()
+	// splitmix64 finalizer for an independent second hash, odd so that
+	// every probe lands on a different bit
+	h2 := h1 + 0x9e3779b97f4a7c15
+	h2 = (h2 ^ h2>>30) * 0xbf58476d1ce4e5b9
+	h2 = (h2 ^ h2>>27) * 0x94d049bb133111eb
+	return h1, (h2 ^ h2>>31) | 1
+}
+
+// Contains reports whether addr is probably in one of the prefixes of the
+// filter.
+func (f *PrefixFilter) Contains(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for length := addr.BitLen(); length >= 0; length-- {
+		if !f.hasLength(addr, length) {
+			continue
+		}
+		h1, h2 := prefixHashes(netip.PrefixFrom(addr, length).Masked())
+		found := true
+		for i := range uint64(f.hashes) {
+			bit := (h1 + i*h2) % f.size
+			if f.bits[bit/64]&(1<<(bit%64)) == 0 {
+				found = false
+				break
+			}
+		}
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteTo serializes the filter.
+func (f *PrefixFilter) WriteTo(w io.Writer) (int64, error) {
+	header := prefixFilterHeader{
+		Version:   prefixFilterVersion,
+		Hashes:    f.hashes,
+		V4Lengths: f.v4Lengths,
+		V6Lengths: f.v6Lengths,
+		Size:      f.size,
+	}
+	copy(header.Magic[:], prefixFilterMagic)
+	if err := binary.Write(w, binary.BigEndian, header); err != nil {
+		return 0, err
+	}
+	if err := binary.Write(w, binary.BigEndian, f.bits); err != nil {
+		return 0, err
+	}
+	return int64(binary.Size(header) + 8*len(f.bits)), nil
+}
+
+// ReadPrefixFilter loads a filter serialized by WriteTo.
+func ReadPrefixFilter(r io.Reader) (*PrefixFilter, error) {
+	var header prefixFilterHeader
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrPrefixFilterCorrupt, err)
+	}
+	switch {
+	case string(header.Magic[:]) != prefixFilterMagic:
+		return nil, fmt.Errorf("%w: bad magic", ErrPrefixFilterCorrupt)
+	case header.Version != prefixFilterVersion:
+		return nil, fmt.Errorf("%w: got version %d expected %d", ErrPrefixFilterCorrupt, header.Version, prefixFilterVersion)
+	case header.Size == 0 || header.Size%64 != 0 || header.Hashes == 0 || header.Size > 1<<40:
+		return nil, fmt.Errorf("%w: bad size", ErrPrefixFilterCorrupt)
+	}
+
+	f := &PrefixFilter{
+		hashes:    header.Hashes,
+		v4Lengths: header.V4Lengths,
+		v6Lengths: header.V6Lengths,
+		size:      header.Size,
+		bits:      make([]uint64, header.Size/64),
+	}
+	if err := binary.Read(r, binary.BigEndian, f.bits); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrPrefixFilterCorrupt, err)
+	}
+	return f, nil
+}
@@ -0,0 +1,58 @@
+package rir
+
+import (
+	"bytes"
+	"errors"
+	"math/rand/v2"
+	"net/netip"
+	"testing"
+)
+
+func TestPrefixFilter(t *testing.T) {
+	prefixes := []netip.Prefix{
+		netip.MustParsePrefix("2.0.0.0/12"),
+		netip.MustParsePrefix("194.146.24.0/23"),
+		netip.MustParsePrefix("2001:660::/32"),
+	}
+	f, err := NewPrefixFilter(prefixes, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if _, err := f.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	if f, err = ReadPrefixFilter(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, addr := range []string{"2.0.0.0", "2.15.255.255", "194.146.25.7", "::ffff:2.3.4.5", "2001:660:1::1"} {
+		if !f.Contains(netip.MustParseAddr(addr)) {
+			t.Errorf("Contains(%s): got false", addr)
+		}
+	}
+
+	rng := rand.New(rand.NewPCG(1, 2))
+	var positives int
+	const lookups = 100000
+	for range lookups {
+		var a [4]byte
+		for i := range a {
+			a[i] = byte(rng.Uint32())
+		}
+		addr := netip.AddrFrom4(a)
+		if prefixes[0].Contains(addr) || prefixes[1].Contains(addr) {
+			continue
+		}
+		if f.Contains(addr) {
+			positives++
+		}
+	}
+	if rate := float64(positives) / lookups; rate > 0.02 {
+		t.Errorf("false positive rate %g, want about 0.01", rate)
+	}
+
+	if _, err := ReadPrefixFilter(bytes.NewReader([]byte("RIRBLOOX"))); !errors.Is(err, ErrPrefixFilterCorrupt) {
+		t.Errorf("ReadPrefixFilter(garbage): got %v", err)
+	}
+}
@@ -0,0 +1,387 @@
+package rir
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type CachedProvider struct {
+	DefaultProvider
+}
+
+func NewCachedProvider(name string, url string) CachedProvider {
+	return CachedProvider{
+		DefaultProvider: DefaultProvider{
+			name: name,
+			url:  url,
+		},
+	}
+}
+
+// WithOptions returns the provider configured by opts.
+func (p CachedProvider) WithOptions(opts *Options) CachedProvider {
+	p.DefaultProvider = p.DefaultProvider.WithOptions(opts)
+	return p
+}
+
+func (p CachedProvider) GetData(ctx context.Context) (io.ReadCloser, error) {
+	opts := p.options()
+	if serial, ok := opts.PinnedSerials[p.Name()]; ok {
+		return p.pinnedData(serial)
+	}
+	if opts.UsePrevious {
+		return p.previousData()
+	}
+
+	finfo, err := opts.store().Stat(p.LatestKey())
+	if errors.Is(err, fs.ErrNotExist) {
+		finfo, err = nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	refresh := finfo == nil || finfo.Size() == 0
+	if !refresh && time.Since(finfo.ModTime()) >= time.Hour*24 {
+		refresh, err = p.isStale(ctx)
+	}
+	if err == nil && refresh {
+		err = p.download(ctx)
+	}
+	if err != nil {
+		if err := p.staleFallback(ctx, finfo, err); err != nil {
+			return nil, err
+		}
+	}
+
+	return opts.store().Open(p.LatestKey())
+}
+
+// download fetches the provider data and stores it.
+func (p CachedProvider) download(ctx context.Context) error {
+	opts := p.options()
+	opts.logf("Refreshing %s data", p.Name())
+	data, err := p.DefaultProvider.GetData(ctx)
+	if err != nil {
+		return err
+	}
+	content, err := io.ReadAll(data)
+	data.Close()
+	if err != nil {
+		return err
+	}
+	if err := p.store(content); err != nil {
+		return err
+	}
+	return opts.autoPrune(p)
+}
+
+// MaxStale is how old the cached file of a provider without Options can be to
+// be used when refreshing it fails, 0 making every failure fatal.
+var MaxStale = 7 * 24 * time.Hour
+
+// staleFallback decides whether the cached file described by finfo, nil if
+// there is none, can be used despite the failure of its refresh, reporting
+// its age with an EventStaleData event if so. It returns the error to fail
+// with otherwise.
+func (p CachedProvider) staleFallback(ctx context.Context, finfo fs.FileInfo, err error) error {
+	opts := p.options()
+	if finfo == nil || finfo.Size() == 0 || opts.MaxStale <= 0 || ctx.Err() != nil {
+		return err
+	}
+	age := time.Since(finfo.ModTime()).Round(time.Second)
+	if age > opts.MaxStale {
+		return fmt.Errorf("%w (cached copy is %s old, more than %s)", err, age, opts.MaxStale)
+	}
+	opts.logf("Refreshing %s data failed, using cached copy %s old: %v", p.Name(), age, err)
+	opts.progress(Event{Kind: EventStaleData, Provider: p.Name(), AgeSeconds: int64(age.Seconds()), Error: err.Error()})
+	return nil
+}
+
+// SourcePath is the location in Store of the file GetData reads.
+func (p CachedProvider) SourcePath() string {
+	opts := p.options()
+	if serial, ok := opts.PinnedSerials[p.Name()]; ok {
+		return p.SnapshotPath(serial)
+	}
+	if opts.UsePrevious {
+		if serial, err := p.PreviousSerial(); err == nil {
+			return p.SnapshotPath(serial)
+		}
+	}
+	return p.FilePath()
+}
+
+// Defaults of the providers without Options.
+var (
+	// KeepSnapshots is the number of downloaded files of each provider
+	// retained as snapshots, 0 disables snapshots.
+	KeepSnapshots = 3
+	// UsePrevious makes providers return the snapshot preceding the latest
+	// download.
+	UsePrevious bool
+	// PinnedSerials maps provider names to the serial of the snapshot they
+	// must return, as set by a snapshot manifest.
+	PinnedSerials map[string]string
+)
+
+const snapshotPrefix = "snapshot-"
+
+// store saves freshly downloaded content as the latest file and as a
+// snapshot named after its serial, dropping snapshots beyond KeepSnapshots.
+func (p CachedProvider) store(content []byte) error {
+	opts := p.options()
+	if err := opts.store().Put(p.LatestKey(), content); err != nil {
+		return err
+	}
+
+	if opts.KeepSnapshots <= 0 {
+		return nil
+	}
+
+	version, err := ReadVersion(bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+	if version.Serial == "" {
+		opts.logf("No serial in %s data, not keeping a snapshot", p.Name())
+		return nil
+	}
+	if err := opts.store().Put(p.SnapshotKey(version.Serial), content); err != nil {
+		return err
+	}
+
+	snapshots, err := p.Snapshots()
+	if err != nil {
+		return err
+	}
+	for _, serial := range snapshots[min(opts.KeepSnapshots, len(snapshots)):] {
+		if err := opts.store().Delete(p.SnapshotKey(serial)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Snapshots returns the serials of the retained snapshots, newest first.
+func (p CachedProvider) Snapshots() ([]string, error) {
+	names, err := p.options().store().List(p.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	var serials []string
+	for _, name := range names {
+		if serial, ok := strings.CutPrefix(name, snapshotPrefix); ok {
+			serials = append(serials, serial)
+		}
+	}
+
+	slices.SortFunc(serials, compareSerials)
+	slices.Reverse(serials)
+	return serials, nil
+}
+
+// compareSerials orders serials numerically when they are numbers, which
+// they are for every registry in practice, and lexically otherwise.
+func compareSerials(a, b string) int {
+	an, aerr := strconv.ParseUint(a, 10, 64)
+	bn, berr := strconv.ParseUint(b, 10, 64)
+	if aerr == nil && berr == nil {
+		return cmp.Compare(an, bn)
+	}
+	return strings.Compare(a, b)
+}
+
+// key is the storage key of a file of the provider.
+func (p CachedProvider) key(name string) string {
+	return p.Name() + "/" + name
+}
+
+// LatestKey is the key of the latest download in Store.
+func (p CachedProvider) LatestKey() string {
+	return p.key("latest")
+}
+
+// SnapshotKey is the key of the snapshot of a serial in Store.
+func (p CachedProvider) SnapshotKey(serial string) string {
+	return p.key(snapshotPrefix + serial)
+}
+
+// SnapshotPath is the location in Store of the snapshot of a serial, a path
+// in the cache directory by default.
+func (p CachedProvider) SnapshotPath(serial string) string {
+	return p.options().store().Location(p.SnapshotKey(serial))
+}
+
+// ErrNotCached is returned when the snapshot a provider must return is not
+// in the cache.
+var ErrNotCached = errors.New("rir: snapshot not cached")
+
+func (p CachedProvider) pinnedData(serial string) (io.ReadCloser, error) {
+	f, err := p.options().store().Open(p.SnapshotKey(serial))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s serial %s, run rir snapshot fetch first", ErrNotCached, p.Name(), serial)
+	}
+	return f, err
+}
+
+// PreviousSerial returns the serial of the newest snapshot older than the
+// latest file. Every snapshot is older than a latest file without serial,
+// which is not kept as a snapshot.
+func (p CachedProvider) PreviousSerial() (string, error) {
+	snapshots, err := p.Snapshots()
+	if err != nil {
+		return "", err
+	}
+	var current string
+	if latest, err := p.options().store().Open(p.LatestKey()); err == nil {
+		version, _ := ReadVersion(latest)
+		latest.Close()
+		current = version.Serial
+	}
+	for _, serial := range snapshots {
+		if current == "" || compareSerials(serial, current) < 0 {
+			return serial, nil
+		}
+	}
+	return "", fmt.Errorf("%w: no snapshot of %s data older than serial %s", ErrNotCached, p.Name(), current)
+}
+
+// previousData returns the snapshot preceding the latest file, for when a
+// fresh download turns out to be broken.
+func (p CachedProvider) previousData() (io.ReadCloser, error) {
+	serial, err := p.PreviousSerial()
+	if err != nil {
+		return nil, err
+	}
+	opts := p.options()
+	opts.logf("Using previous %s snapshot %s", p.Name(), serial)
+	return opts.store().Open(p.SnapshotKey(serial))
+}
+
+func (p CachedProvider) isStale(ctx context.Context) (bool, error) {
+	local, err := p.localMd5()
+	if err != nil {
+		return false, err
+	}
+	remote, err := p.remoteMd5(ctx)
+	if err != nil {
+		return false, err
+	}
+	return local != remote, nil
+}
+
+// GetCacheDir returns the cache directory: the directory of Store if it is a
+// DirStorage, ~/.rir otherwise, which then only holds the files kept outside
+// of Store, such as exports.
+func GetCacheDir() string {
+	dir, _ := StoreDir()
+	return dir
+}
+
+func homeCacheDir() string {
+	return filepath.Join(os.Getenv("HOME"), ".rir")
+}
+
+func CreateCacheDir() error {
+	if !isDirStorage() {
+		return nil
+	}
+	for _, provider := range AllProviders {
+		path := filepath.Join(GetCacheDir(), provider.Name())
+		if err := os.MkdirAll(path, 0o700); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FilePath is the location in Store of the latest download, a path in the
+// cache directory by default.
+func (p CachedProvider) FilePath() string {
+	return p.options().store().Location(p.LatestKey())
+}
+
+// sourceKey returns the key of the file of Store at a location returned by
+// FilePath, SnapshotPath or SourcePath, such as the Source of records.
+func sourceKey(location string) (string, bool) {
+	for _, p := range AllProviders {
+		if location == p.FilePath() {
+			return p.LatestKey(), true
+		}
+		if serial, ok := strings.CutPrefix(location, p.SnapshotPath("")); ok {
+			return p.SnapshotKey(serial), true
+		}
+	}
+	return "", false
+}
+
+// ReadSource reads the registry file at the location of records, from Store
+// when it is one of the cache. Other locations are read as files.
+func ReadSource(location string) ([]byte, error) {
+	if key, ok := sourceKey(location); ok {
+		return ReadStored(key)
+	}
+	return os.ReadFile(location)
+}
+
+// StatSource is ReadSource for the description of the file.
+func StatSource(location string) (fs.FileInfo, error) {
+	if key, ok := sourceKey(location); ok {
+		return Store.Stat(key)
+	}
+	return os.Stat(location)
+}
+
+func (p CachedProvider) localMd5() (string, error) {
+	content, err := readKey(p.options().store(), p.LatestKey())
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", md5.Sum(content)), nil
+}
+
+var MD5SigRegex = regexp.MustCompile(`(?i)([a-f0-9]{32})`)
+
+// remoteMd5 returns the published checksum of the provider file, or an empty
+// string if there is none, which makes the cached file stale.
+func (p CachedProvider) remoteMd5(ctx context.Context) (string, error) {
+	resp, err := p.Get(ctx, p.url+".md5")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if status := resp.StatusCode; status != 200 {
+		p.options().logf("Cannot GET md5 for %s. Call returned %d", p.Name(), status)
+		return "", nil
+	}
+
+	md5Response, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	matches := MD5SigRegex.FindSubmatch(md5Response)
+
+	if matches == nil {
+		p.options().logf("Cannot regexp match an md5 for %s", p.Name())
+		return "", nil
+	}
+
+	return string(matches[1]), nil
+}
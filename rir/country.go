@@ -0,0 +1,50 @@
+package rir
+
+import "slices"
+
+// WithdrawnCountries maps the ISO 3166 codes found in older registry files
+// that are no longer in use to the codes that replaced them. When a country
+// split, the first successor is the one that took over most of its address
+// space.
+var WithdrawnCountries = map[string][]string{
+	"AN": {"CW", "SX", "BQ"}, // Netherlands Antilles
+	"BU": {"MM"},             // Burma
+	"CS": {"RS", "ME"},       // Serbia and Montenegro
+	"DD": {"DE"},             // German Democratic Republic
+	"FX": {"FR"},             // Metropolitan France
+	"TP": {"TL"},             // East Timor
+	"UK": {"GB"},             // used by some registries instead of GB
+	"YD": {"YE"},             // Democratic Yemen
+	"YU": {"RS", "ME"},       // Yugoslavia
+	"ZR": {"CD"},             // Zaire
+}
+
+// NormalizeCountry returns the current code of a withdrawn country code and
+// any other code unchanged. current is the code of the same resource in
+// recent data, if known, and is chosen among the successors of a split
+// country; the first successor is chosen otherwise.
+func NormalizeCountry(cc, current string) string {
+	successors, ok := WithdrawnCountries[cc]
+	if !ok {
+		return cc
+	}
+	if slices.Contains(successors, current) {
+		return current
+	}
+	return successors[0]
+}
+
+// NormalizeCountries returns the records with withdrawn country codes
+// replaced by current ones, so that archived files can be compared with
+// recent ones.
+func (r Records) NormalizeCountries() Records {
+	r.Ips = slices.Clone(r.Ips)
+	for i := range r.Ips {
+		r.Ips[i].Cc = NormalizeCountry(r.Ips[i].Cc, "")
+	}
+	r.Asns = slices.Clone(r.Asns)
+	for i := range r.Asns {
+		r.Asns[i].Cc = NormalizeCountry(r.Asns[i].Cc, "")
+	}
+	return r
+}
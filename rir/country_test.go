@@ -0,0 +1,20 @@
+package rir
+
+import "testing"
+
+func TestNormalizeCountry(t *testing.T) {
+	for _, test := range []struct {
+		cc, current, want string
+	}{
+		{"FR", "", "FR"},
+		{"ZR", "", "CD"},
+		{"YU", "", "RS"},
+		{"YU", "ME", "ME"},
+		{"AN", "SX", "SX"},
+		{"AN", "FR", "CW"},
+	} {
+		if got := NormalizeCountry(test.cc, test.current); got != test.want {
+			t.Errorf("NormalizeCountry(%q, %q) = %q, want %q", test.cc, test.current, got, test.want)
+		}
+	}
+}
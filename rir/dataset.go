@@ -0,0 +1,170 @@
+package rir
+
+import (
+	"context"
+	"crypto/sha256"
+	"io"
+	"iter"
+	"math/big"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+// A Dataset holds the records of several providers in memory, indexed for
+// lookups, so that long running programs can answer many queries without
+// loading the files again. It is safe for concurrent use.
+type Dataset struct {
+	records []Records
+
+	prefixes map[netip.Prefix]IpRecord
+	lengths  [129]bool
+}
+
+// LoadDataset loads the records of the providers, or of AllProviders when
+// none are given.
+func LoadDataset(ctx context.Context, providers ...CachedProvider) (*Dataset, error) {
+	if len(providers) == 0 {
+		providers = AllProviders
+	}
+	var all []Records
+	for _, p := range providers {
+		records, err := p.Records(ctx)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, records)
+	}
+	return NewDataset(all...), nil
+}
+
+// NewDataset indexes already loaded records. Invalid records are left out of
+// the index.
+func NewDataset(records ...Records) *Dataset {
+	d := &Dataset{records: records, prefixes: make(map[netip.Prefix]IpRecord)}
+	for _, r := range records {
+		for _, ip := range r.Ips {
+			for prefix, err := range ip.Prefixes() {
+				if err != nil {
+					break
+				}
+				d.prefixes[prefix] = ip
+				d.lengths[prefix.Bits()] = true
+			}
+		}
+	}
+	return d
+}
+
+// LookupIP returns the most specific delegation containing addr, along with
+// the prefix of the record it falls in.
+func (d *Dataset) LookupIP(addr netip.Addr) (IpRecord, netip.Prefix, bool) {
+	for bits := addr.BitLen(); bits >= 0; bits-- {
+		if !d.lengths[bits] {
+			continue
+		}
+		prefix := netip.PrefixFrom(addr, bits).Masked()
+		if record, ok := d.prefixes[prefix]; ok {
+			return record, prefix, true
+		}
+	}
+	return IpRecord{}, netip.Prefix{}, false
+}
+
+// CountryPrefixes returns the prefixes delegated to the country cc, IPv4
+// first, in address order.
+func (d *Dataset) CountryPrefixes(cc string) []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, r := range d.records {
+		for _, prefix := range r.Country(cc) {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	slices.SortFunc(prefixes, func(a, b netip.Prefix) int {
+		return a.Addr().Compare(b.Addr())
+	})
+	return prefixes
+}
+
+// CountryStats is the number of addresses delegated to a country.
+type CountryStats struct {
+	V4, V6 *big.Int
+}
+
+// CountryStats counts the addresses delegated to the country cc.
+func (d *Dataset) CountryStats(cc string) CountryStats {
+	stats := CountryStats{V4: new(big.Int), V6: new(big.Int)}
+	for _, prefix := range d.CountryPrefixes(cc) {
+		count := stats.V6
+		if prefix.Addr().Is4() {
+			count = stats.V4
+		}
+		count.Add(count, new(big.Int).Lsh(big.NewInt(1), uint(prefix.Addr().BitLen()-prefix.Bits())))
+	}
+	return stats
+}
+
+// Records yields the records of every provider selected by f, as IpRecord
+// and AsnRecord entries.
+func (d *Dataset) Records(f Filter) iter.Seq[Entry] {
+	return func(yield func(Entry) bool) {
+		for _, r := range d.records {
+			for entry := range r.Filter(f) {
+				if !yield(entry) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Asn returns the record of the AS number asn.
+func (d *Dataset) Asn(asn int) (AsnRecord, bool) {
+	for _, r := range d.records {
+		if record, ok := r.Asn(asn); ok {
+			return record, true
+		}
+	}
+	return AsnRecord{}, false
+}
+
+// CountryAsns returns the AS number records of the country cc.
+func (d *Dataset) CountryAsns(cc string) []AsnRecord {
+	var asns []AsnRecord
+	for entry := range d.Records(Filter{Country: cc, Type: ASN}) {
+		asns = append(asns, entry.(AsnRecord))
+	}
+	return asns
+}
+
+// Sources returns the records the dataset was built from, one per provider
+// file.
+func (d *Dataset) Sources() []Records {
+	return d.records
+}
+
+// Serials identifies the provider files of the dataset as registry-serial
+// pairs joined by dots, e.g. "arin-20250101.ripencc-20250101".
+func (d *Dataset) Serials() string {
+	var serials []string
+	for _, r := range d.records {
+		serials = append(serials, r.Registry+"-"+r.Serial)
+	}
+	slices.Sort(serials)
+	return strings.Join(serials, ".")
+}
+
+// WriteIndex writes the records of the dataset in the binary index format, for
+// other programs to load with ReadDatasetIndex instead of the registry files.
+func (d *Dataset) WriteIndex(w io.Writer) error {
+	return encodeIndex(w, datasetMagic, sha256.Sum256([]byte(d.Serials())), d.records)
+}
+
+// ReadDatasetIndex loads a dataset written by WriteIndex.
+func ReadDatasetIndex(r io.Reader) (*Dataset, error) {
+	var records []Records
+	if err := decodeIndex(r, datasetMagic, nil, &records); err != nil {
+		return nil, err
+	}
+	return NewDataset(records...), nil
+}
@@ -0,0 +1,69 @@
+package rir
+
+import (
+	"iter"
+	"slices"
+	"time"
+)
+
+// A Filter selects records. Every non-zero field must match; the zero Filter
+// matches every record.
+type Filter struct {
+	// Country is an ISO 3166 alpha-2 code
+	Country string
+	// Countries selects the records of any of these countries
+	Countries []string
+	// Registry is the name of a registry, such as ripencc
+	Registry string
+	// Type is IPv4, IPv6 or ASN
+	Type string
+	// Status is the delegation status, such as allocated or assigned
+	Status string
+	// OpaqueId identifies the holder of the resources within a registry
+	OpaqueId string
+	// Since and Until bound the delegation date, inclusively. Records
+	// without a valid date never match a date bound.
+	Since, Until time.Time
+}
+
+// Match reports whether the record is selected by the filter.
+func (f Filter) Match(r Record) bool {
+	switch {
+	case f.Country != "" && r.Cc != f.Country,
+		f.Registry != "" && r.Registry != f.Registry,
+		f.Type != "" && r.Type != f.Type,
+		f.Status != "" && r.Status != f.Status,
+		f.OpaqueId != "" && r.OpaqueId != f.OpaqueId,
+		len(f.Countries) > 0 && !slices.Contains(f.Countries, r.Cc):
+		return false
+	}
+	if !f.Since.IsZero() || !f.Until.IsZero() {
+		if r.Time.IsZero() ||
+			(!f.Since.IsZero() && r.Time.Before(f.Since)) ||
+			(!f.Until.IsZero() && r.Time.After(f.Until)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Filter yields the ip then the asn records selected by f, as IpRecord and
+// AsnRecord entries.
+func (r Records) Filter(f Filter) iter.Seq[Entry] {
+	return func(yield func(Entry) bool) {
+		if f.Type != ASN {
+			for _, ip := range r.Ips {
+				if f.Match(ip.Record) && !yield(ip) {
+					return
+				}
+			}
+		}
+		if f.Type == "" || f.Type == ASN {
+			for _, asn := range r.Asns {
+				if f.Match(asn.Record) && !yield(asn) {
+					return
+				}
+			}
+		}
+	}
+}
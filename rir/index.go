@@ -0,0 +1,264 @@
+package rir
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// The binary index caches the parsed records of a provider file so they do
+// not have to be parsed again on every run. Its header is laid out as
+//
+//	magic        [8]byte
+//	version      uint32
+//	source hash  [32]byte  sha256 of the raw registry file
+//	payload hash [32]byte  sha256 of the payload
+//	payload size uint64
+//
+// followed by the gob encoded Records. Dataset indexes share the layout with
+// their own magic, the hash of the provider serials as source hash and the
+// records of every provider as payload.
+const (
+	indexMagic   = "RIRINDEX"
+	datasetMagic = "RIRDATAS"
+	indexVersion = 3
+)
+
+type indexHeader struct {
+	Magic       [8]byte
+	Version     uint32
+	SourceHash  [sha256.Size]byte
+	PayloadHash [sha256.Size]byte
+	PayloadSize uint64
+}
+
+var (
+	ErrIndexCorrupt  = errors.New("rir: index is corrupt")
+	ErrIndexVersion  = errors.New("rir: index format version mismatch")
+	ErrIndexOutdated = errors.New("rir: index does not match source file")
+)
+
+func writeIndex(w io.Writer, sourceHash [sha256.Size]byte, records Records) error {
+	return encodeIndex(w, indexMagic, sourceHash, records)
+}
+
+func readIndex(r io.Reader, sourceHash [sha256.Size]byte) (Records, error) {
+	var records Records
+	if err := decodeIndex(r, indexMagic, &sourceHash, &records); err != nil {
+		return Records{}, err
+	}
+	return records, nil
+}
+
+// encodeIndex writes the header and gob encoded payload v.
+func encodeIndex(w io.Writer, magic string, sourceHash [sha256.Size]byte, v any) error {
+	var payload bytes.Buffer
+	if err := gob.NewEncoder(&payload).Encode(v); err != nil {
+		return err
+	}
+
+	header := indexHeader{
+		Version:     indexVersion,
+		SourceHash:  sourceHash,
+		PayloadHash: sha256.Sum256(payload.Bytes()),
+		PayloadSize: uint64(payload.Len()),
+	}
+	copy(header.Magic[:], magic)
+
+	if err := binary.Write(w, binary.BigEndian, header); err != nil {
+		return err
+	}
+	_, err := w.Write(payload.Bytes())
+	return err
+}
+
+// decodeIndex checks the header, and the source hash unless it is nil, then
+// decodes the payload into v.
+func decodeIndex(r io.Reader, magic string, sourceHash *[sha256.Size]byte, v any) error {
+	header, err := readIndexHeader(r, magic)
+	if err != nil {
+		return err
+	}
+	if sourceHash != nil && header.SourceHash != *sourceHash {
+		return ErrIndexOutdated
+	}
+	return decodePayload(r, header, v)
+}
+
+func readIndexHeader(r io.Reader, magic string) (indexHeader, error) {
+	var header indexHeader
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		return indexHeader{}, fmt.Errorf("%w: %w", ErrIndexCorrupt, err)
+	}
+
+	switch {
+	case string(header.Magic[:]) != magic:
+		return indexHeader{}, fmt.Errorf("%w: bad magic", ErrIndexCorrupt)
+	case header.Version != indexVersion:
+		return indexHeader{}, fmt.Errorf("%w: got %d expected %d", ErrIndexVersion, header.Version, indexVersion)
+	}
+	return header, nil
+}
+
+// decodePayload checks the payload following header against it and decodes
+// it into v.
+func decodePayload(r io.Reader, header indexHeader, v any) error {
+	payload, err := io.ReadAll(io.LimitReader(r, int64(header.PayloadSize)+1))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrIndexCorrupt, err)
+	}
+	if uint64(len(payload)) != header.PayloadSize || sha256.Sum256(payload) != header.PayloadHash {
+		return fmt.Errorf("%w: checksum mismatch", ErrIndexCorrupt)
+	}
+
+	if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(v); err != nil {
+		return fmt.Errorf("%w: %w", ErrIndexCorrupt, err)
+	}
+	return nil
+}
+
+// indexName is the name of the index of the latest file of a provider.
+const indexName = "latest.idx"
+
+func (p CachedProvider) indexPath() string {
+	return p.options().store().Location(p.key(indexName))
+}
+
+// Engines of CachedProvider.Records.
+const (
+	// EngineIndex reads the binary index, rebuilding it when needed. It is
+	// the fastest when the same file is queried several times.
+	EngineIndex = "index"
+	// EngineStream parses the raw file as it is read, without loading it
+	// whole nor touching the index. It suits single passes over a file
+	// that was just downloaded.
+	EngineStream = "stream"
+)
+
+// Engine selects how the providers without Options load their data.
+var Engine = EngineIndex
+
+// Records returns the parsed provider data, from the binary index when it is
+// valid and by parsing the raw file otherwise. A missing, corrupt or outdated
+// index is transparently rebuilt. With EngineStream the raw file is always
+// parsed.
+func (p CachedProvider) Records(ctx context.Context) (Records, error) {
+	opts := p.options()
+	data, err := p.GetData(ctx)
+	if err != nil {
+		return Records{}, err
+	}
+	if opts.Engine == EngineStream {
+		defer data.Close()
+		records, err := NewLimitedReader(data, opts.ReaderLimits).Read()
+		if err != nil {
+			return Records{}, err
+		}
+		records.Source = p.SourcePath()
+		opts.progress(Event{Kind: EventParsed, Provider: p.Name(), Ips: len(records.Ips), Asns: len(records.Asns)})
+		return records, nil
+	}
+
+	content, err := io.ReadAll(data)
+	data.Close()
+	if err != nil {
+		return Records{}, err
+	}
+	sourceHash := sha256.Sum256(content)
+
+	var reuse map[string]Entry
+	if f, err := opts.store().Open(p.key(indexName)); err == nil {
+		records, err := readIndex(f, sourceHash)
+		f.Close()
+		if err == nil {
+			records.Source = p.SourcePath()
+			opts.progress(Event{Kind: EventParsed, Provider: p.Name(), Ips: len(records.Ips), Asns: len(records.Asns), Indexed: true})
+			return records, nil
+		}
+		if errors.Is(err, ErrIndexOutdated) {
+			// most lines of a new serial are unchanged, only parse the
+			// others
+			reuse = p.previousEntries()
+		} else {
+			opts.logf("Rebuilding %s index: %v", p.Name(), err)
+		}
+	}
+
+	records, err := NewLimitedReader(bytes.NewReader(content), opts.ReaderLimits).reusing(reuse).Read()
+	if err != nil {
+		return Records{}, err
+	}
+	records.Source = p.SourcePath()
+	opts.progress(Event{Kind: EventParsed, Provider: p.Name(), Ips: len(records.Ips), Asns: len(records.Asns)})
+
+	if err := p.writeIndexFile(sourceHash, records); err != nil {
+		// the index only saves time on the next run
+		opts.logf("Writing %s index: %v", p.Name(), err)
+	}
+	return records, nil
+}
+
+// previousEntries maps the lines of the file an outdated index was built from
+// to their records in the index, so that an index can be updated from a new
+// serial by only parsing its new and modified lines. It returns nil when the
+// index cannot be read or its source file is no longer retained as a
+// snapshot.
+func (p CachedProvider) previousEntries() map[string]Entry {
+	f, err := p.options().store().Open(p.key(indexName))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	header, err := readIndexHeader(f, indexMagic)
+	if err != nil {
+		return nil
+	}
+	var previous Records
+	if err := decodePayload(f, header, &previous); err != nil || previous.Serial == "" {
+		return nil
+	}
+	content, err := readKey(p.options().store(), p.SnapshotKey(previous.Serial))
+	if err != nil || sha256.Sum256(content) != header.SourceHash {
+		return nil
+	}
+	return lineEntries(content, previous)
+}
+
+// lineEntries maps the text of the lines of content to the records parsed
+// from them.
+func lineEntries(content []byte, records Records) map[string]Entry {
+	var lines []string
+	s := bufio.NewScanner(bytes.NewReader(content))
+	s.Buffer(nil, math.MaxInt)
+	for s.Scan() {
+		lines = append(lines, s.Text())
+	}
+
+	entries := make(map[string]Entry, len(records.Ips)+len(records.Asns))
+	for _, r := range records.Ips {
+		if r.Line > 0 && r.Line <= len(lines) {
+			entries[lines[r.Line-1]] = r
+		}
+	}
+	for _, r := range records.Asns {
+		if r.Line > 0 && r.Line <= len(lines) {
+			entries[lines[r.Line-1]] = r
+		}
+	}
+	return entries
+}
+
+func (p CachedProvider) writeIndexFile(sourceHash [sha256.Size]byte, records Records) error {
+	var b bytes.Buffer
+	if err := writeIndex(&b, sourceHash, records); err != nil {
+		return err
+	}
+	return p.options().store().Put(p.key(indexName), b.Bytes())
+}
@@ -0,0 +1,129 @@
+package rir
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIndexRoundTrip(t *testing.T) {
+	records, err := NewReader(bytes.NewBufferString(regularData)).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sourceHash := sha256.Sum256([]byte(regularData))
+
+	var buf bytes.Buffer
+	if err := writeIndex(&buf, sourceHash, records); err != nil {
+		t.Fatalf("write index: %v", err)
+	}
+	content := buf.Bytes()
+
+	loaded, err := readIndex(bytes.NewReader(content), sourceHash)
+	if err != nil {
+		t.Fatalf("read index: %v", err)
+	}
+	if len(loaded.Ips) != len(records.Ips) || len(loaded.Asns) != len(records.Asns) {
+		t.Errorf("records count: expected %d/%d got %d/%d", len(records.Ips), len(records.Asns), len(loaded.Ips), len(loaded.Asns))
+	}
+	if loaded.Ips[0].Start != records.Ips[0].Start {
+		t.Errorf("first ip: expected %s got %s", records.Ips[0].Start, loaded.Ips[0].Start)
+	}
+
+	if _, err := readIndex(bytes.NewReader(content), sha256.Sum256(nil)); !errors.Is(err, ErrIndexOutdated) {
+		t.Errorf("other source: expected %v got %v", ErrIndexOutdated, err)
+	}
+
+	corrupt := bytes.Clone(content)
+	corrupt[len(corrupt)-1] ^= 0xff
+	if _, err := readIndex(bytes.NewReader(corrupt), sourceHash); !errors.Is(err, ErrIndexCorrupt) {
+		t.Errorf("corrupt payload: expected %v got %v", ErrIndexCorrupt, err)
+	}
+
+	if _, err := readIndex(bytes.NewReader(content[:len(content)/2]), sourceHash); !errors.Is(err, ErrIndexCorrupt) {
+		t.Errorf("truncated index: expected %v got %v", ErrIndexCorrupt, err)
+	}
+
+	versioned := bytes.Clone(content)
+	versioned[len(indexMagic)+3]++
+	if _, err := readIndex(bytes.NewReader(versioned), sourceHash); !errors.Is(err, ErrIndexVersion) {
+		t.Errorf("other version: expected %v got %v", ErrIndexVersion, err)
+	}
+}
+
+func TestEngines(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	p := NewCachedProvider("test", "https://registry.example/delegated")
+	if err := os.MkdirAll(filepath.Dir(p.FilePath()), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p.FilePath(), []byte(regularData), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	defer func(engine string) { Engine = engine }(Engine)
+
+	for _, test := range []struct {
+		engine string
+		index  bool
+	}{{EngineStream, false}, {EngineIndex, true}} {
+		Engine = test.engine
+		records, err := p.Records(context.Background())
+		if err != nil {
+			t.Fatalf("%s: %v", test.engine, err)
+		}
+		if len(records.Ips) != 11 || len(records.Asns) != 2 {
+			t.Errorf("%s: records count: expected 11/2 got %d/%d", test.engine, len(records.Ips), len(records.Asns))
+		}
+		if _, err := os.Stat(p.indexPath()); (err == nil) != test.index {
+			t.Errorf("%s: index written: expected %t got %v", test.engine, test.index, err)
+		}
+	}
+}
+
+func TestIncrementalIndex(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	p := NewCachedProvider("test", "https://registry.example/delegated")
+	if err := os.MkdirAll(filepath.Dir(p.FilePath()), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{p.FilePath(), p.SnapshotPath("20110113")} {
+		if err := os.WriteFile(path, []byte(regularData), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func(engine string) { Engine = engine }(Engine)
+	Engine = EngineIndex
+	if _, err := p.Records(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// a new serial changing a record and adding another
+	next := strings.Replace(regularData, "|20110113|", "|20110114|", 1)
+	next = strings.Replace(next, "apnic|MM|ipv4|203.81.64.0|8192", "apnic|MY|ipv4|203.81.64.0|8192", 1)
+	next += "\napnic|AU|ipv4|1.0.0.0|256|20110811|assigned"
+	if err := os.WriteFile(p.FilePath(), []byte(next), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if reuse := p.previousEntries(); len(reuse) != 13 {
+		t.Errorf("previous entries: expected 13 got %d", len(reuse))
+	}
+	got, err := p.Records(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := NewReader(strings.NewReader(next)).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want.Source = p.SourcePath()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("incremental update: got %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,85 @@
+package rir
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// A Loader shares a Dataset between the goroutines of a long running program.
+// However many goroutines ask for data at the same time, the providers are
+// fetched and parsed once, and a refreshed Dataset replaces the previous one
+// atomically once it is fully loaded. The zero Loader loads AllProviders.
+type Loader struct {
+	// Providers are the providers to load, AllProviders when empty.
+	Providers []CachedProvider
+	// Options configure the providers when set.
+	Options *Options
+
+	current atomic.Pointer[Dataset]
+
+	mu       sync.Mutex
+	inFlight *loadCall
+}
+
+type loadCall struct {
+	done    chan struct{}
+	dataset *Dataset
+	err     error
+}
+
+// Dataset returns the current Dataset, loading it on first use.
+func (l *Loader) Dataset(ctx context.Context) (*Dataset, error) {
+	if d := l.current.Load(); d != nil {
+		return d, nil
+	}
+	return l.join(ctx, false)
+}
+
+// Refresh loads the providers again, picking up updated provider files, and
+// makes the result the current Dataset. Meanwhile Dataset keeps returning the
+// previous one. Concurrent calls share a single load, which is not aborted
+// when the context of one of the callers is done.
+func (l *Loader) Refresh(ctx context.Context) (*Dataset, error) {
+	return l.join(ctx, true)
+}
+
+// join waits for the load in flight, starting one if there is none. Unless
+// refresh is set, a Dataset loaded in the meantime is returned instead.
+func (l *Loader) join(ctx context.Context, refresh bool) (*Dataset, error) {
+	l.mu.Lock()
+	if d := l.current.Load(); d != nil && !refresh {
+		l.mu.Unlock()
+		return d, nil
+	}
+	call := l.inFlight
+	if call == nil {
+		call = &loadCall{done: make(chan struct{})}
+		l.inFlight = call
+		go l.load(context.WithoutCancel(ctx), call)
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.dataset, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (l *Loader) load(ctx context.Context, call *loadCall) {
+	if l.Options != nil {
+		call.dataset, call.err = l.Options.LoadDataset(ctx, l.Providers...)
+	} else {
+		call.dataset, call.err = LoadDataset(ctx, l.Providers...)
+	}
+	if call.err == nil {
+		l.current.Store(call.dataset)
+	}
+
+	l.mu.Lock()
+	l.inFlight = nil
+	l.mu.Unlock()
+	close(call.done)
+}
@@ -0,0 +1,62 @@
+package rir
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestLoader(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	p := NewCachedProvider("test", "https://registry.example/delegated")
+	if err := os.MkdirAll(filepath.Dir(p.FilePath()), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p.FilePath(), []byte(regularData), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var parsed atomic.Int32
+	defer func(f func(Event)) { Progress = f }(Progress)
+	Progress = func(e Event) {
+		if e.Kind == EventParsed {
+			parsed.Add(1)
+		}
+	}
+
+	l := &Loader{Providers: []CachedProvider{p}}
+	datasets := make([]*Dataset, 10)
+	var wg sync.WaitGroup
+	for i := range datasets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d, err := l.Dataset(context.Background())
+			if err != nil {
+				t.Error(err)
+			}
+			datasets[i] = d
+		}()
+	}
+	wg.Wait()
+
+	if n := parsed.Load(); n != 1 {
+		t.Errorf("concurrent loads: parsed %d times, want once", n)
+	}
+	for _, d := range datasets {
+		if d == nil || d != datasets[0] {
+			t.Fatalf("concurrent loads: got distinct datasets %p and %p", d, datasets[0])
+		}
+	}
+
+	refreshed, err := l.Refresh(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current, _ := l.Dataset(context.Background()); refreshed == datasets[0] || current != refreshed {
+		t.Errorf("refresh: current dataset %p, refreshed %p, previous %p", current, refreshed, datasets[0])
+	}
+}
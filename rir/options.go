@@ -0,0 +1,156 @@
+package rir
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Options configure how providers fetch, cache and parse their files. A
+// provider without Options, such as those of AllProviders, uses the package
+// variables of the same names, which the command line tool sets from its
+// flags. Options must not be modified once in use.
+type Options struct {
+	// Store is where the files are cached, the cache directory when nil. An
+	// S3Storage without Client uses HTTPClient.
+	Store Storage
+	// HTTPClient performs every request, including checksum requests,
+	// http.DefaultClient when nil.
+	HTTPClient *http.Client
+	// FetchTimeout is the maximum duration of a request, overridden for
+	// some providers, by name, by ProviderTimeouts. 0 disables it.
+	FetchTimeout     time.Duration
+	ProviderTimeouts map[string]time.Duration
+	// FetchConcurrency and FetchInterval limit the requests of the
+	// providers sharing these Options. When FetchConcurrency is 0 they
+	// share the limits of the package instead.
+	FetchConcurrency int
+	FetchInterval    time.Duration
+
+	// KeepSnapshots is the number of downloaded files of each provider
+	// retained as snapshots, 0 disables snapshots.
+	KeepSnapshots int
+	// UsePrevious makes providers return the snapshot preceding the latest
+	// download.
+	UsePrevious bool
+	// PinnedSerials maps provider names to the serial of the snapshot they
+	// must return.
+	PinnedSerials map[string]string
+	// MaxStale is how old a cached file can be to be used when refreshing
+	// it fails, 0 making every failure fatal.
+	MaxStale time.Duration
+	// AutoPrune is applied to the cache every time a provider downloads a
+	// new file.
+	AutoPrune PrunePolicy
+
+	ReaderLimits Limits
+	// Engine selects how CachedProvider.Records loads the provider data.
+	Engine string
+
+	// Logger receives the messages of the providers, such as refreshes,
+	// log.Default() when nil.
+	Logger *log.Logger
+	// Progress, when set, is called with every progress event. Providers
+	// are loaded concurrently, so it must be safe for concurrent use.
+	Progress func(Event)
+
+	limiterOnce sync.Once
+	limiter     *fetchLimiter
+	storeOnce   sync.Once
+	storage     Storage
+}
+
+// DefaultOptions returns Options holding the current values of the package
+// variables, to be adjusted before use.
+func DefaultOptions() *Options {
+	return &Options{
+		Store:            Store,
+		HTTPClient:       HTTPClient,
+		FetchTimeout:     FetchTimeout,
+		ProviderTimeouts: ProviderTimeouts,
+		KeepSnapshots:    KeepSnapshots,
+		UsePrevious:      UsePrevious,
+		PinnedSerials:    PinnedSerials,
+		MaxStale:         MaxStale,
+		AutoPrune:        AutoPrune,
+		ReaderLimits:     ReaderLimits,
+		Engine:           Engine,
+		Progress:         Progress,
+	}
+}
+
+// LoadDataset is the package LoadDataset, with the providers using these
+// Options.
+func (o *Options) LoadDataset(ctx context.Context, providers ...CachedProvider) (*Dataset, error) {
+	if len(providers) == 0 {
+		providers = AllProviders
+	}
+	configured := make([]CachedProvider, len(providers))
+	for i, p := range providers {
+		configured[i] = p.WithOptions(o)
+	}
+	return LoadDataset(ctx, configured...)
+}
+
+func (o *Options) store() Storage {
+	o.storeOnce.Do(func() {
+		switch s := o.Store.(type) {
+		case nil:
+			o.storage = DirStorage{}
+		case *S3Storage:
+			// the requests of the storage go through HTTPClient too
+			if s.Client == nil {
+				configured := *s
+				configured.Client = o.client()
+				o.storage = &configured
+			} else {
+				o.storage = s
+			}
+		default:
+			o.storage = o.Store
+		}
+	})
+	return o.storage
+}
+
+func (o *Options) client() *http.Client {
+	if o.HTTPClient == nil {
+		return http.DefaultClient
+	}
+	return o.HTTPClient
+}
+
+// timeout is how long a request to a provider, including reading the response
+// body, may take.
+func (o *Options) timeout(provider string) time.Duration {
+	if timeout, ok := o.ProviderTimeouts[provider]; ok {
+		return timeout
+	}
+	return o.FetchTimeout
+}
+
+func (o *Options) logf(format string, args ...any) {
+	if o.Logger != nil {
+		o.Logger.Printf(format, args...)
+	} else {
+		log.Printf(format, args...)
+	}
+}
+
+func (o *Options) progress(e Event) {
+	if o.Progress != nil {
+		o.Progress(e)
+	}
+}
+
+func (o *Options) getLimiter() *fetchLimiter {
+	if o.FetchConcurrency == 0 {
+		return getLimiter()
+	}
+	o.limiterOnce.Do(func() {
+		o.limiter = newFetchLimiter(o.FetchConcurrency, o.FetchInterval)
+	})
+	return o.limiter
+}
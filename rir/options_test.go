@@ -0,0 +1,62 @@
+package rir
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestOptions loads a provider configured by Options only, leaving the
+// package variables and the cache directory alone.
+func TestOptions(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	defer func(f func(Event)) { Progress = f }(Progress)
+	Progress = func(e Event) { t.Errorf("package progress hook called with %v", e) }
+
+	var logs bytes.Buffer
+	var events []Event
+	store := NewMemoryStorage()
+	opts := &Options{
+		Store: store,
+		HTTPClient: &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(regularData)), Request: req}, nil
+		})},
+		FetchConcurrency: 1,
+		KeepSnapshots:    1,
+		ReaderLimits:     DefaultLimits,
+		Engine:           EngineStream,
+		Logger:           log.New(&logs, "", 0),
+		Progress:         func(e Event) { events = append(events, e) },
+	}
+
+	p := NewCachedProvider("test", "https://options.example/delegated")
+	d, err := opts.LoadDataset(context.Background(), p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prefixes := d.CountryPrefixes("JP"); len(prefixes) == 0 {
+		t.Errorf("no JP prefixes loaded")
+	}
+
+	if !strings.Contains(logs.String(), "Refreshing test data") {
+		t.Errorf("logs: got %q", logs.String())
+	}
+	if len(events) != 3 || events[0].Kind != EventFetchStarted || events[2].Kind != EventParsed {
+		t.Errorf("progress events: got %v", events)
+	}
+	if snapshots, err := p.WithOptions(opts).Snapshots(); err != nil || len(snapshots) != 1 {
+		t.Errorf("snapshots: got %v, %v", snapshots, err)
+	}
+	if _, err := store.Stat(p.LatestKey()); err != nil {
+		t.Errorf("latest not stored: %v", err)
+	}
+	if entries, err := os.ReadDir(home); err != nil || len(entries) != 0 {
+		t.Errorf("home: got %v, %v", entries, err)
+	}
+}
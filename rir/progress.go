@@ -0,0 +1,54 @@
+package rir
+
+import "io"
+
+// Kinds of progress events.
+const (
+	EventFetchStarted   = "fetch_started"
+	EventFetchCompleted = "fetch_completed"
+	EventParsed         = "parsed"
+	// EventStaleData reports cached data used as its refresh failed.
+	EventStaleData = "stale_data"
+)
+
+// An Event reports the progress of fetching and parsing provider data.
+type Event struct {
+	Kind     string `json:"event"`
+	Provider string `json:"provider,omitempty"`
+	URL      string `json:"url,omitempty"`
+	// Bytes is the size of a completed download.
+	Bytes int64 `json:"bytes,omitempty"`
+	// Ips and Asns are the number of records parsed, and Indexed whether
+	// they were read from the binary index rather than the raw file.
+	Ips     int  `json:"ips,omitempty"`
+	Asns    int  `json:"asns,omitempty"`
+	Indexed bool `json:"indexed,omitempty"`
+	// AgeSeconds is the age of stale data and Error why it was not
+	// refreshed.
+	AgeSeconds int64  `json:"age_seconds,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Progress, when set, is called with every progress event of the providers
+// without Options. Providers are loaded concurrently, so it must be safe for
+// concurrent use.
+var Progress func(Event)
+
+// countingBody reports the completion of a download once its body is
+// closed.
+type countingBody struct {
+	io.ReadCloser
+	event    Event
+	progress func(Event)
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.event.Bytes += int64(n)
+	return n, err
+}
+
+func (b *countingBody) Close() error {
+	b.progress(b.event)
+	return b.ReadCloser.Close()
+}
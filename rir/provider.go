@@ -0,0 +1,169 @@
+package rir
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+type Provider interface {
+	Name() string
+	// GetData returns the content of the provider file, to be closed by
+	// the caller. Fetching is aborted when ctx is done.
+	GetData(ctx context.Context) (io.ReadCloser, error)
+}
+
+type DefaultProvider struct {
+	name string
+	url  string
+	// opts are the package variables when nil
+	opts *Options
+}
+
+func NewDefaultProvider(name string, url string) DefaultProvider {
+	return DefaultProvider{name: name, url: url}
+}
+
+func (p DefaultProvider) Name() string {
+	return p.name
+}
+
+// WithOptions returns the provider configured by opts.
+func (p DefaultProvider) WithOptions(opts *Options) DefaultProvider {
+	p.opts = opts
+	return p
+}
+
+func (p DefaultProvider) options() *Options {
+	if p.opts != nil {
+		return p.opts
+	}
+	return DefaultOptions()
+}
+
+// URL is where the provider file is fetched from.
+func (p DefaultProvider) URL() string {
+	return p.url
+}
+
+// HTTPError is returned when a server answers with an unexpected status.
+type HTTPError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("rir: fetching %s: HTTP call returned %d", e.URL, e.StatusCode)
+}
+
+func (p DefaultProvider) GetData(ctx context.Context) (io.ReadCloser, error) {
+	opts := p.options()
+	opts.logf("Fetching %s data", p.Name())
+	opts.progress(Event{Kind: EventFetchStarted, Provider: p.Name(), URL: p.url})
+	response, err := p.Get(ctx, p.url)
+	if err != nil {
+		return nil, err
+	}
+
+	if status := response.StatusCode; status != 200 {
+		response.Body.Close()
+		return nil, &HTTPError{URL: p.url, StatusCode: status}
+	}
+
+	completed := Event{Kind: EventFetchCompleted, Provider: p.Name(), URL: p.url}
+	return &countingBody{ReadCloser: response.Body, event: completed, progress: opts.progress}, nil
+}
+
+// Get performs a GET request with HTTPClient, subject to the fetch rate limits
+// and to the timeout of the provider. The concurrency slot is held until the
+// response body is closed.
+func (p DefaultProvider) Get(ctx context.Context, url string) (*http.Response, error) {
+	opts := p.options()
+	acquired, err := opts.getLimiter().acquire(ctx, p.Name(), url)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	if timeout := opts.timeout(p.Name()); timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	release := func() {
+		cancel()
+		acquired()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	resp, err := opts.client().Do(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	resp.Body = releasingBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// Defaults of the providers without Options.
+var (
+	// HTTPClient performs every request to the providers, including the
+	// checksum requests of cached providers. Replace it to use a custom
+	// transport, proxy or TLS configuration.
+	HTTPClient = http.DefaultClient
+	// FetchTimeout is the maximum duration of a request to a provider.
+	FetchTimeout = 5 * time.Minute
+	// ProviderTimeouts overrides FetchTimeout for some providers, by name.
+	ProviderTimeouts = make(map[string]time.Duration)
+)
+
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b releasingBody) Close() error {
+	defer b.release()
+	return b.ReadCloser.Close()
+}
+
+// AllProviders are the five regional internet registries, fetched from the
+// RIPE NCC mirror.
+var AllProviders = []CachedProvider{
+	NewCachedProvider(
+		"afrinic",
+		"https://ftp.ripe.net/pub/stats/afrinic/delegated-afrinic-extended-latest",
+	),
+	NewCachedProvider(
+		"apnic",
+		"https://ftp.ripe.net/pub/stats/apnic/delegated-apnic-extended-latest",
+	),
+	NewCachedProvider(
+		"arin",
+		"https://ftp.ripe.net/pub/stats/arin/delegated-arin-extended-latest",
+	),
+	NewCachedProvider(
+		"lacnic",
+		"https://ftp.ripe.net/pub/stats/lacnic/delegated-lacnic-extended-latest",
+	),
+	NewCachedProvider(
+		"ripencc",
+		"https://ftp.ripe.net/pub/stats/ripencc/delegated-ripencc-extended-latest",
+	),
+	//NewCachedProvider(
+	//	"iana",
+	//	"http://ftp.apnic.net/stats/iana/delegated-iana-latest",
+	//),
+}
+
+// FindProvider returns the provider of AllProviders with the given name.
+func FindProvider(name string) (CachedProvider, bool) {
+	for _, provider := range AllProviders {
+		if provider.Name() == name {
+			return provider, true
+		}
+	}
+	return CachedProvider{}, false
+}
@@ -0,0 +1,170 @@
+package rir
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestHTTPClient(t *testing.T) {
+	files := map[string]string{
+		"https://registry.example/delegated":     "2|test|20240102|0|19830705|20240101|+0100\n",
+		"https://registry.example/delegated.md5": "MD5 (delegated) = 0123456789abcdef0123456789abcdef\n",
+	}
+	var requested []string
+	defer func(client *http.Client) { HTTPClient = client }(HTTPClient)
+	HTTPClient = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		requested = append(requested, req.URL.String())
+		content, ok := files[req.URL.String()]
+		if !ok {
+			return &http.Response{StatusCode: http.StatusNotFound, Body: http.NoBody}, nil
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(content))}, nil
+	})}
+
+	var events []Event
+	defer func(f func(Event)) { Progress = f }(Progress)
+	Progress = func(e Event) { events = append(events, e) }
+
+	p := NewCachedProvider("test", "https://registry.example/delegated")
+	data, err := p.DefaultProvider.GetData(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := io.ReadAll(data)
+	data.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != files[p.url] {
+		t.Errorf("GetData = %q, want %q", content, files[p.url])
+	}
+
+	want := []Event{
+		{Kind: EventFetchStarted, Provider: "test", URL: p.url},
+		{Kind: EventFetchCompleted, Provider: "test", URL: p.url, Bytes: int64(len(content))},
+	}
+	if !slices.Equal(events, want) {
+		t.Errorf("progress events = %v, want %v", events, want)
+	}
+
+	sum, err := p.remoteMd5(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum != "0123456789abcdef0123456789abcdef" {
+		t.Errorf("remoteMd5 = %q", sum)
+	}
+
+	if len(requested) != 2 {
+		t.Errorf("requests through HTTPClient: %v", requested)
+	}
+}
+
+func TestStaleFallback(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	defer func(client *http.Client) { HTTPClient = client }(HTTPClient)
+	unreachable := errors.New("unreachable")
+	HTTPClient = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, unreachable
+	})}
+	var events []Event
+	defer func(f func(Event)) { Progress = f }(Progress)
+	Progress = func(e Event) { events = append(events, e) }
+	defer func(maxStale time.Duration) { MaxStale = maxStale }(MaxStale)
+
+	p := NewCachedProvider("test", "https://registry.example/delegated")
+	if err := os.MkdirAll(filepath.Dir(p.FilePath()), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p.FilePath(), []byte(regularData), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	modified := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(p.FilePath(), modified, modified); err != nil {
+		t.Fatal(err)
+	}
+
+	MaxStale = 72 * time.Hour
+	data, err := p.GetData(context.Background())
+	if err != nil {
+		t.Fatalf("GetData within MaxStale: %v", err)
+	}
+	data.Close()
+	if len(events) != 1 || events[0].Kind != EventStaleData || events[0].AgeSeconds < 48*3600 || events[0].Error == "" {
+		t.Errorf("progress events = %v, want a stale_data event", events)
+	}
+
+	MaxStale = 24 * time.Hour
+	if _, err := p.GetData(context.Background()); !errors.Is(err, unreachable) {
+		t.Errorf("GetData beyond MaxStale: got %v, want %v", err, unreachable)
+	}
+}
+
+func TestUsePrevious(t *testing.T) {
+	defer func(previous bool) { UsePrevious = previous }(UsePrevious)
+	UsePrevious = true
+	file := func(serial string) string {
+		return "2|test|" + serial + "|0|19830705|20240101|+0000\n"
+	}
+
+	for _, test := range []struct {
+		name      string
+		latest    string
+		snapshots []string
+		want      string
+	}{
+		{"latest kept as snapshot", file("3"), []string{"1", "2", "3"}, "2"},
+		{"latest not kept, as with -keep 0", file("3"), []string{"1", "2"}, "2"},
+		{"latest without serial", file(""), []string{"1", "2"}, "2"},
+		{"latest older than the newest snapshot", file("2"), []string{"1", "2", "3"}, "1"},
+		{"no older snapshot", file("1"), []string{"1"}, ""},
+	} {
+		t.Setenv("HOME", t.TempDir())
+		p := NewCachedProvider("test", "https://registry.example/delegated")
+		if err := os.MkdirAll(filepath.Dir(p.FilePath()), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p.FilePath(), []byte(test.latest), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		for _, serial := range test.snapshots {
+			if err := os.WriteFile(p.SnapshotPath(serial), []byte(file(serial)), 0o600); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		data, err := p.GetData(context.Background())
+		if test.want == "" {
+			if !errors.Is(err, ErrNotCached) {
+				t.Errorf("%s: expected %v got %v", test.name, ErrNotCached, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		version, err := ReadVersion(data)
+		data.Close()
+		if err != nil || version.Serial != test.want {
+			t.Errorf("%s: serial: got %q, %v, want %s", test.name, version.Serial, err, test.want)
+		}
+		if got := p.SourcePath(); got != p.SnapshotPath(test.want) {
+			t.Errorf("%s: source: got %s, want %s", test.name, got, p.SnapshotPath(test.want))
+		}
+	}
+}
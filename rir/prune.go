@@ -0,0 +1,115 @@
+package rir
+
+import (
+	"errors"
+	"io/fs"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PrunePolicy describes which snapshots and indexes of the cache may be
+// removed. The `latest` file of each provider is never pruned, nor are the
+// other files of the cache directory. A zero field disables the corresponding
+// limit.
+type PrunePolicy struct {
+	MaxSize int64
+	MaxAge  time.Duration
+}
+
+var (
+	// AutoPrune is applied to the cache every time a provider without
+	// Options downloads a new file.
+	AutoPrune PrunePolicy
+	pruneMu   sync.Mutex
+)
+
+type cacheFile struct {
+	key     string
+	size    int64
+	modTime time.Time
+}
+
+// prunable tells whether a file of a provider may be pruned: snapshots and
+// indexes can be rebuilt, other files, such as the latest download, cannot.
+func prunable(name string) bool {
+	return strings.HasPrefix(name, snapshotPrefix) || strings.HasSuffix(name, ".idx")
+}
+
+// PruneCache removes the snapshots and indexes of the providers, AllProviders
+// when none are given, older than the maximum age from s, then removes the
+// oldest remaining ones until the files of the providers fit in the maximum
+// size. It returns the locations of the removed files.
+func PruneCache(s Storage, policy PrunePolicy, providers ...CachedProvider) ([]string, error) {
+	if len(providers) == 0 {
+		providers = AllProviders
+	}
+	var files []cacheFile
+	var total int64
+
+	for _, p := range providers {
+		names, err := s.List(p.Name())
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			key := p.key(name)
+			info, err := s.Stat(key)
+			if errors.Is(err, fs.ErrNotExist) {
+				// removed since the list was read
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			total += info.Size()
+			if prunable(name) {
+				files = append(files, cacheFile{key: key, size: info.Size(), modTime: info.ModTime()})
+			}
+		}
+	}
+
+	slices.SortFunc(files, func(a, b cacheFile) int {
+		return a.modTime.Compare(b.modTime)
+	})
+
+	var removed []string
+	for _, f := range files {
+		tooOld := policy.MaxAge > 0 && time.Since(f.modTime) > policy.MaxAge
+		tooBig := policy.MaxSize > 0 && total > policy.MaxSize
+		if !(tooOld || tooBig) {
+			continue
+		}
+		if err := s.Delete(f.key); err != nil {
+			return removed, err
+		}
+		total -= f.size
+		removed = append(removed, s.Location(f.key))
+	}
+
+	return removed, nil
+}
+
+// autoPrune applies AutoPrune, if set, after p modified the cache.
+func (o *Options) autoPrune(p CachedProvider) error {
+	if o.AutoPrune == (PrunePolicy{}) {
+		return nil
+	}
+	// providers are refreshed in parallel
+	pruneMu.Lock()
+	defer pruneMu.Unlock()
+
+	providers := AllProviders
+	if _, ok := FindProvider(p.Name()); !ok {
+		providers = append(slices.Clip(providers), p)
+	}
+	removed, err := PruneCache(o.store(), o.AutoPrune, providers...)
+	for _, path := range removed {
+		o.logf("Pruned %s from cache", path)
+	}
+	return err
+}
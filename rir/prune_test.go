@@ -0,0 +1,45 @@
+package rir
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestPruneCache(t *testing.T) {
+	dir := t.TempDir()
+	s := DirStorage{Dir: dir}
+	p := NewCachedProvider("test", "https://registry.example/delegated")
+
+	old := time.Now().Add(-48 * time.Hour)
+	for _, key := range []string{p.LatestKey(), p.SnapshotKey("1"), p.SnapshotKey("2"), p.key(indexName), "exports/plain/geo", "dns.json"} {
+		if err := s.Put(key, []byte("content")); err != nil {
+			t.Fatal(err)
+		}
+		if key != p.SnapshotKey("2") {
+			if err := os.Chtimes(s.path(key), old, old); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	removed, err := PruneCache(s, PrunePolicy{MaxAge: time.Hour}, p)
+	slices.Sort(removed)
+	if want := []string{s.path(p.key(indexName)), s.path(p.SnapshotKey("1"))}; err != nil || !slices.Equal(removed, want) {
+		t.Errorf("max age: got %v, %v, want %v", removed, err, want)
+	}
+
+	// the latest file counts but is kept
+	removed, err = PruneCache(s, PrunePolicy{MaxSize: 1}, p)
+	if want := []string{s.path(p.SnapshotKey("2"))}; err != nil || !slices.Equal(removed, want) {
+		t.Errorf("max size: got %v, %v, want %v", removed, err, want)
+	}
+
+	for _, path := range []string{p.LatestKey(), "exports/plain/geo", "dns.json"} {
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(path))); err != nil {
+			t.Errorf("%s pruned: %v", path, err)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package rir
+
+import (
+	"iter"
+	"net/netip"
+)
+
+// Lookup yields the IP records covering addr along with the prefix of the
+// record that contains it.
+func (r Records) Lookup(addr netip.Addr) iter.Seq2[IpRecord, netip.Prefix] {
+	return func(yield func(IpRecord, netip.Prefix) bool) {
+		for _, record := range r.Ips {
+			if (record.Type == IPv4) != addr.Is4() {
+				continue
+			}
+			for prefix, err := range record.Prefixes() {
+				if err == nil && prefix.Contains(addr) {
+					if !yield(record, prefix) {
+						return
+					}
+					break
+				}
+			}
+		}
+	}
+}
+
+// Country yields every prefix delegated to the country cc (ISO 3166 alpha-2)
+// along with its record.
+func (r Records) Country(cc string) iter.Seq2[IpRecord, netip.Prefix] {
+	return func(yield func(IpRecord, netip.Prefix) bool) {
+		for _, record := range r.Ips {
+			if record.Cc != cc || (record.Type != IPv4 && record.Type != IPv6) {
+				continue
+			}
+			for prefix, err := range record.Prefixes() {
+				if err != nil {
+					// malformed records, kept by lenient readers, cover nothing
+					break
+				}
+				if !yield(record, prefix) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Asn returns the record of the AS number asn.
+func (r Records) Asn(asn int) (AsnRecord, bool) {
+	for _, record := range r.Asns {
+		if record.Start <= asn && asn < record.Start+record.Value {
+			return record, true
+		}
+	}
+	return AsnRecord{}, false
+}
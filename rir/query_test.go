@@ -0,0 +1,147 @@
+package rir
+
+import (
+	"fmt"
+	"math/big"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+const queryData = `2|ripencc|20240102|4|19830705|20240101|+0100
+ripencc|*|ipv4|*|2|summary
+ripencc|*|ipv6|*|1|summary
+ripencc|*|asn|*|1|summary
+ripencc|FR|ipv4|2.0.0.0|1048576|20100712|allocated|b8f0a8c3
+ripencc|DE|ipv4|2.16.0.0|768|20100712|allocated|c1c2c3c4
+ripencc|FR|ipv6|2001:660::|32|19990908|allocated|b8f0a8c3
+ripencc|FR|asn|3215|1|19940101|allocated|b8f0a8c3
+`
+
+func TestQueries(t *testing.T) {
+	records, err := NewReader(strings.NewReader(queryData)).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for record, prefix := range records.Lookup(netip.MustParseAddr("2.16.2.1")) {
+		got = append(got, record.Cc+" "+prefix.String())
+	}
+	if want := "DE 2.16.2.0/24"; len(got) != 1 || got[0] != want {
+		t.Errorf("Lookup: got %v, want [%s]", got, want)
+	}
+
+	got = nil
+	for _, prefix := range records.Country("FR") {
+		got = append(got, prefix.String())
+	}
+	if want := "2.0.0.0/12 2001:660::/32"; strings.Join(got, " ") != want {
+		t.Errorf("Country: got %v, want %s", got, want)
+	}
+
+	if asn, ok := records.Asn(3215); !ok || asn.Cc != "FR" {
+		t.Errorf("Asn(3215): got %+v, %v", asn, ok)
+	}
+	if _, ok := records.Asn(3216); ok {
+		t.Error("Asn(3216): unexpected match")
+	}
+}
+
+// TestQueriesInvalidRecord queries records holding an ipv4 line whose start
+// is an IPv6 address, which must be skipped rather than crash.
+func TestQueriesInvalidRecord(t *testing.T) {
+	records := Records{Ips: []IpRecord{
+		{Record: Record{Registry: "ripencc", Cc: "FR", Type: IPv4, Value: 256, Status: "allocated", Line: 1}, Start: netip.MustParseAddr("2001:db8::")},
+		{Record: Record{Registry: "ripencc", Cc: "FR", Type: IPv4, Value: 256, Status: "allocated", Line: 2}, Start: netip.MustParseAddr("2.0.0.0")},
+	}}
+
+	var got []string
+	for record, prefix := range records.Lookup(netip.MustParseAddr("2.0.0.1")) {
+		got = append(got, fmt.Sprint(record.Line, " ", prefix))
+	}
+	if want := "2 2.0.0.0/24"; len(got) != 1 || got[0] != want {
+		t.Errorf("Lookup: got %v, want [%s]", got, want)
+	}
+
+	got = nil
+	for _, prefix := range records.Country("FR") {
+		got = append(got, prefix.String())
+	}
+	if want := "2.0.0.0/24"; strings.Join(got, " ") != want {
+		t.Errorf("Country: got %v, want %s", got, want)
+	}
+}
+
+func TestDataset(t *testing.T) {
+	records, err := NewReader(strings.NewReader(queryData)).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := NewDataset(records)
+
+	if record, prefix, ok := d.LookupIP(netip.MustParseAddr("2.16.2.1")); !ok || record.Cc != "DE" || prefix.String() != "2.16.2.0/24" {
+		t.Errorf("LookupIP(2.16.2.1): got %s %s %v", record.Cc, prefix, ok)
+	}
+	if _, _, ok := d.LookupIP(netip.MustParseAddr("192.0.2.1")); ok {
+		t.Error("LookupIP(192.0.2.1): unexpected match")
+	}
+
+	stats := d.CountryStats("FR")
+	if stats.V4.Int64() != 1<<20 || stats.V6.Cmp(new(big.Int).Lsh(big.NewInt(1), 96)) != 0 {
+		t.Errorf("CountryStats(FR): got %s %s", stats.V4, stats.V6)
+	}
+	if asn, ok := d.Asn(3215); !ok || asn.Cc != "FR" {
+		t.Errorf("Asn(3215): got %+v, %v", asn, ok)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	records, err := NewReader(strings.NewReader(queryData)).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		filter Filter
+		want   int
+	}{
+		{Filter{}, 4},
+		{Filter{Country: "FR"}, 3},
+		{Filter{Country: "FR", Type: IPv6}, 1},
+		{Filter{Countries: []string{"DE", "FR"}, Type: IPv4}, 2},
+		{Filter{Countries: []string{"US"}}, 0},
+		{Filter{Type: ASN}, 1},
+		{Filter{OpaqueId: "b8f0a8c3"}, 3},
+		{Filter{OpaqueId: "b8f0a8c3", Type: IPv6}, 1},
+		{Filter{Registry: "arin"}, 0},
+		{Filter{Since: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)}, 2},
+		{Filter{Until: time.Date(1999, 9, 8, 0, 0, 0, 0, time.UTC)}, 2},
+	} {
+		var got int
+		for range records.Filter(test.filter) {
+			got++
+		}
+		if got != test.want {
+			t.Errorf("Filter(%+v): got %d records, want %d", test.filter, got, test.want)
+		}
+	}
+}
+
+func TestCountrySet(t *testing.T) {
+	records, err := NewReader(strings.NewReader(queryData)).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	set, err := NewDataset(records).CountrySet("FR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !set.Contains(netip.MustParseAddr("2.15.255.255")) || set.Contains(netip.MustParseAddr("2.16.0.1")) {
+		t.Errorf("CountrySet(FR): wrong membership of %v", set.Prefixes())
+	}
+	if got := fmt.Sprint(set.Prefixes()); got != "[2.0.0.0/12 2001:660::/32]" {
+		t.Errorf("CountrySet(FR): got %s", got)
+	}
+}
@@ -0,0 +1,90 @@
+package rir
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// fetchLimiter keeps outbound requests polite: requests to the same host are
+// spaced out by at least an interval and only a bounded number run at once.
+type fetchLimiter struct {
+	mu       sync.Mutex
+	sem      chan struct{}
+	interval time.Duration
+	next     map[string]time.Time
+}
+
+func newFetchLimiter(concurrency int, interval time.Duration) *fetchLimiter {
+	return &fetchLimiter{
+		sem:      make(chan struct{}, max(concurrency, 1)),
+		interval: interval,
+		next:     make(map[string]time.Time),
+	}
+}
+
+// Some registries throttle more aggressively than others, LACNIC in
+// particular blocks clients that hit its servers in quick succession.
+var ProviderMinIntervals = map[string]time.Duration{
+	"lacnic": 10 * time.Second,
+}
+
+// acquire blocks until a request to rawURL on behalf of provider is allowed
+// and returns the function releasing the concurrency slot, or fails when ctx
+// is done first.
+func (l *fetchLimiter) acquire(ctx context.Context, provider string, rawURL string) (release func(), err error) {
+	select {
+	case l.sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	release = func() { <-l.sem }
+
+	host := rawURL
+	if u, err := url.Parse(rawURL); err == nil {
+		host = u.Host
+	}
+	interval := max(l.interval, ProviderMinIntervals[provider])
+
+	l.mu.Lock()
+	now := time.Now()
+	start := l.next[host]
+	if start.Before(now) {
+		start = now
+	}
+	l.next[host] = start.Add(interval)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(start.Sub(now))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return release, nil
+	case <-ctx.Done():
+		release()
+		return nil, ctx.Err()
+	}
+}
+
+// The limits shared by the providers without their own, see Options.
+var (
+	FetchConcurrency = 2
+	FetchInterval    = 2 * time.Second
+	limiter          *fetchLimiter
+	limiterOnce      sync.Once
+)
+
+func getLimiter() *fetchLimiter {
+	limiterOnce.Do(func() {
+		limiter = newFetchLimiter(FetchConcurrency, FetchInterval)
+	})
+	return limiter
+}
+
+// Acquire blocks until a request to rawURL on behalf of provider is allowed by
+// the global fetch rate limits and returns the function releasing it, for
+// requests made outside of providers.
+func Acquire(ctx context.Context, provider string, rawURL string) (release func(), err error) {
+	return getLimiter().acquire(ctx, provider, rawURL)
+}
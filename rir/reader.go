@@ -0,0 +1,589 @@
+// Package rir parses the delegation statistics files published by the
+// regional internet registries, fetches and caches them, and answers queries
+// about the delegated IP addresses and AS numbers.
+package rir
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"math"
+	"net/netip"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	IPv4 = "ipv4"
+	IPv6 = "ipv6"
+	ASN  = "asn"
+)
+
+type (
+	Summary struct {
+		Registry, Type string
+		Count          int
+	}
+
+	Version struct {
+		Version                       float64
+		Registry, Serial              string
+		Records                       int
+		StartDate, EndDate, UtcOffset string
+		// StartTime and EndTime are StartDate and EndDate parsed in the
+		// UtcOffset time zone, zero when missing or invalid
+		StartTime, EndTime time.Time
+	}
+
+	Record struct {
+		Registry, Cc, Type     string
+		Value                  int
+		Date, Status, OpaqueId string
+		// Time is Date parsed, zero when missing or invalid as for the
+		// 00000000 date of resources registered before the registries
+		// recorded dates
+		Time time.Time
+		// Line is the line number of the record in its file
+		Line int
+	}
+
+	IpRecord struct {
+		Record
+		Start netip.Addr
+	}
+
+	AsnRecord struct {
+		Record
+		Start int
+	}
+
+	Records struct {
+		Version                               float64
+		Registry, Serial                      string
+		Count, AsnCount, Ipv4Count, Ipv6Count int
+		Asns                                  []AsnRecord
+		Ips                                   []IpRecord
+		// Source is the file the records were read from, if any
+		Source string
+	}
+)
+
+func hostMaxLen(ip uint32) int {
+	if ip == 0 {
+		return 32
+	}
+	res := 0
+	for ip&1 == 0 {
+		ip >>= 1
+		res++
+	}
+
+	return res
+}
+
+func (ipr IpRecord) v4Net(yield func(netip.Prefix) bool) {
+	var currentIP [4]byte
+	currentIPS := currentIP[:]
+	hostsCount := uint32(ipr.Value)
+	startIP := ipr.Start.As4()
+	currentStart := binary.BigEndian.Uint32(startIP[:])
+
+	for hostsCount > 0 {
+		binary.BigEndian.PutUint32(currentIPS, currentStart)
+
+		logRes := int(math.Log2(float64(hostsCount)))
+		maxRes := hostMaxLen(currentStart)
+		minNet := maxRes
+		if logRes < maxRes {
+			minNet = logRes
+		}
+
+		ip := netip.AddrFrom4(currentIP)
+
+		if !yield(netip.PrefixFrom(ip, 32-minNet)) {
+			return
+		}
+
+		numHosts := uint32(1 << minNet)
+		hostsCount -= numHosts
+		currentStart += numHosts
+	}
+}
+
+func (ipr IpRecord) v6Net(yield func(netip.Prefix) bool) {
+	yield(netip.PrefixFrom(ipr.Start, ipr.Value))
+}
+
+func (ipr IpRecord) Net() iter.Seq[netip.Prefix] {
+	switch ipr.Type {
+	case IPv4:
+		return ipr.v4Net
+	case IPv6:
+		return ipr.v6Net
+	default:
+		// no prefixes for records of other types
+		return func(yield func(netip.Prefix) bool) {}
+	}
+}
+
+// ErrInvalidRecord is reported by Prefixes for a record whose type, start
+// address and value do not describe a range of addresses.
+var ErrInvalidRecord = errors.New("rir: invalid ip record")
+
+func (ipr IpRecord) validate() error {
+	var valid bool
+	switch ipr.Type {
+	case IPv4:
+		if ipr.Start.Is4() {
+			start := ipr.Start.As4()
+			remaining := 1<<32 - uint64(binary.BigEndian.Uint32(start[:]))
+			valid = ipr.Value > 0 && uint64(ipr.Value) <= remaining
+		}
+	case IPv6:
+		valid = ipr.Start.Is6() && ipr.Value >= 0 && ipr.Value <= 128
+	}
+	if !valid {
+		return fmt.Errorf("%w: line %d: %s %s %d", ErrInvalidRecord, ipr.Line, ipr.Type, ipr.Start, ipr.Value)
+	}
+	return nil
+}
+
+// Prefixes yields the prefixes covering the record like Net, or a single
+// ErrInvalidRecord error if the record is malformed, where Net would yield
+// nothing or panic.
+func (ipr IpRecord) Prefixes() iter.Seq2[netip.Prefix, error] {
+	return func(yield func(netip.Prefix, error) bool) {
+		if err := ipr.validate(); err != nil {
+			yield(netip.Prefix{}, err)
+			return
+		}
+		for prefix := range ipr.Net() {
+			if !yield(prefix, nil) {
+				return
+			}
+		}
+	}
+}
+
+// Limits bounds the resources a Reader may consume while parsing. A zero
+// field disables the corresponding limit.
+type Limits struct {
+	MaxLineLength int
+	MaxRecords    int
+	MaxFileSize   int64
+}
+
+// DefaultLimits are comfortably above the size of the real registry files
+// while still refusing input that would exhaust memory.
+var DefaultLimits = Limits{
+	MaxLineLength: 64 * 1024,
+	MaxRecords:    5_000_000,
+	MaxFileSize:   512 * 1024 * 1024,
+}
+
+// ReaderLimits are the limits applied when providers without Options parse
+// their files.
+var ReaderLimits = DefaultLimits
+
+var (
+	ErrLineTooLong    = errors.New("rir: line exceeds maximum length")
+	ErrTooManyRecords = errors.New("rir: file exceeds maximum number of records")
+	ErrFileTooLarge   = errors.New("rir: file exceeds maximum size")
+)
+
+type Reader struct {
+	s      *bufio.Scanner
+	size   *sizeLimitedReader
+	limits Limits
+	// reuse holds already parsed records by the text of their line
+	reuse map[string]Entry
+}
+
+func NewReader(r io.Reader) Reader {
+	return NewLimitedReader(r, DefaultLimits)
+}
+
+func NewLimitedReader(r io.Reader, limits Limits) Reader {
+	var size *sizeLimitedReader
+	if limits.MaxFileSize > 0 {
+		size = &sizeLimitedReader{r: r, n: limits.MaxFileSize}
+		r = size
+	}
+
+	s := bufio.NewScanner(r)
+	if limits.MaxLineLength > 0 {
+		// the scanner needs room for the line terminator on top of the line itself
+		s.Buffer(make([]byte, 0, min(limits.MaxLineLength+2, bufio.MaxScanTokenSize)), limits.MaxLineLength+2)
+	} else {
+		s.Buffer(nil, math.MaxInt)
+	}
+
+	return Reader{
+		s:      s,
+		size:   size,
+		limits: limits,
+	}
+}
+
+// reusing makes the reader return the records of reuse for the lines they
+// were parsed from instead of parsing these lines again.
+func (r Reader) reusing(reuse map[string]Entry) Reader {
+	r.reuse = reuse
+	return r
+}
+
+// sizeLimitedReader behaves like io.LimitedReader but fails loudly instead of
+// silently truncating the input.
+type sizeLimitedReader struct {
+	r        io.Reader
+	n        int64
+	exceeded bool
+}
+
+func (l *sizeLimitedReader) Read(p []byte) (int, error) {
+	if l.n <= 0 {
+		var probe [1]byte
+		if n, _ := l.r.Read(probe[:]); n > 0 {
+			l.exceeded = true
+			return 0, ErrFileTooLarge
+		}
+		return 0, io.EOF
+	}
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	return n, err
+}
+
+// ParseError reports a malformed line of a registry file.
+type ParseError struct {
+	Line int
+	Text string
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("rir: line %d: %v: %q", e.Line, e.Err, e.Text)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+var errFieldCount = errors.New("not enough fields")
+
+// An Entry is a parsed line of a registry file: a Version, a Summary, an
+// IpRecord or an AsnRecord.
+type Entry interface {
+	entry()
+}
+
+func (Version) entry()   {}
+func (Summary) entry()   {}
+func (IpRecord) entry()  {}
+func (AsnRecord) entry() {}
+
+// Records yields the entries of the file as they are read, so that files of
+// any size can be processed in constant memory. Iteration stops after the
+// first error, yielded with a nil Entry.
+func (r Reader) Records() iter.Seq2[Entry, error] {
+	return func(yield func(Entry, error) bool) {
+		var p parser
+		var recordsCount int
+
+		for r.s.Scan() {
+			p.currentLine = r.s.Text()
+			p.lineNumber++
+			if r.size != nil && r.size.exceeded {
+				// the scanner hands out the truncated last line before reporting the error
+				yield(nil, ErrFileTooLarge)
+				return
+			}
+			if limit := r.limits.MaxLineLength; limit > 0 && len(p.currentLine) > limit {
+				yield(nil, ErrLineTooLong)
+				return
+			}
+			if entry, ok := r.reuse[p.currentLine]; ok {
+				recordsCount++
+				if err := r.checkRecordsCount(recordsCount); err != nil {
+					yield(nil, err)
+					return
+				}
+				if !yield(atLine(entry, p.lineNumber), nil) {
+					return
+				}
+				continue
+			}
+			p.fields = strings.Split(p.currentLine, "|")
+
+			var entry Entry
+			var err error
+			switch {
+			case p.isIgnored():
+				continue
+			case p.isVersion():
+				entry, err = p.parseVersion()
+			case p.isSummary():
+				entry, err = p.parseSummary()
+			case p.isIp() || p.isAsn():
+				recordsCount++
+				if err := r.checkRecordsCount(recordsCount); err != nil {
+					yield(nil, err)
+					return
+				}
+				if p.isIp() {
+					entry, err = p.parseIp()
+				} else {
+					entry, err = p.parseAsn()
+				}
+			default:
+				continue
+			}
+			if err != nil {
+				yield(nil, p.error(err))
+				return
+			}
+			if !yield(entry, nil) {
+				return
+			}
+		}
+
+		if err := r.s.Err(); errors.Is(err, bufio.ErrTooLong) {
+			yield(nil, ErrLineTooLong)
+		} else if err != nil {
+			yield(nil, err)
+		}
+	}
+}
+
+// atLine moves a reused record to the line it was found at.
+func atLine(entry Entry, line int) Entry {
+	switch r := entry.(type) {
+	case IpRecord:
+		r.Line = line
+		return r
+	case AsnRecord:
+		r.Line = line
+		return r
+	}
+	return entry
+}
+
+func (r Reader) Read() (Records, error) {
+	var records Records
+	for entry, err := range r.Records() {
+		if err != nil {
+			return Records{}, err
+		}
+		switch entry := entry.(type) {
+		case Version:
+			records.Version = entry.Version
+			records.Registry = entry.Registry
+			records.Serial = entry.Serial
+			records.Count = entry.Records
+		case Summary:
+			switch entry.Type {
+			case ASN:
+				records.AsnCount = entry.Count
+			case IPv4:
+				records.Ipv4Count = entry.Count
+			case IPv6:
+				records.Ipv6Count = entry.Count
+			}
+		case IpRecord:
+			records.Ips = append(records.Ips, entry)
+		case AsnRecord:
+			records.Asns = append(records.Asns, entry)
+		}
+	}
+	return records, nil
+}
+
+// ReadVersion parses only the header of a registry file, returning a zero
+// Version if the file has none.
+func ReadVersion(r io.Reader) (Version, error) {
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, DefaultLimits.MaxLineLength)
+
+	var p parser
+	for s.Scan() {
+		p.currentLine = s.Text()
+		p.lineNumber++
+		p.fields = strings.Split(p.currentLine, "|")
+
+		switch {
+		case p.isIgnored():
+			// ignored
+		case p.isVersion():
+			version, err := p.parseVersion()
+			if err != nil {
+				return Version{}, p.error(err)
+			}
+			return version, nil
+		default:
+			return Version{}, nil
+		}
+	}
+
+	return Version{}, s.Err()
+}
+
+func (r Reader) checkRecordsCount(count int) error {
+	if limit := r.limits.MaxRecords; limit > 0 && count > limit {
+		return ErrTooManyRecords
+	}
+	return nil
+}
+
+var (
+	versionRegex = regexp.MustCompile(`^\d+\.*\d*`)
+	ignoredRegex = regexp.MustCompile(`^\s*(#.*)?$`)
+)
+
+type parser struct {
+	currentLine string
+	lineNumber  int
+	fields      []string
+}
+
+func (p parser) error(err error) error {
+	return &ParseError{Line: p.lineNumber, Text: p.currentLine, Err: err}
+}
+
+func (p parser) isVersion() bool {
+	return versionRegex.MatchString(p.currentLine)
+}
+
+func (p parser) isIgnored() bool {
+	return ignoredRegex.MatchString(p.currentLine)
+}
+
+func (p parser) isSummary() bool {
+	return strings.HasSuffix(p.currentLine, "summary")
+}
+
+func (p parser) isIp() bool {
+	return len(p.fields) > 2 && strings.HasPrefix(p.fields[2], "ipv")
+}
+
+func (p parser) isAsn() bool {
+	return len(p.fields) > 2 && strings.HasPrefix(p.fields[2], ASN)
+}
+
+func (p parser) parseVersion() (Version, error) {
+	if len(p.fields) < 7 {
+		return Version{}, errFieldCount
+	}
+	version, err := strconv.ParseFloat(p.fields[0], 64)
+	if err != nil {
+		return Version{}, err
+	}
+	records, err := strconv.Atoi(p.fields[3])
+	if err != nil {
+		return Version{}, err
+	}
+	loc := time.UTC
+	if offset, err := time.Parse("-0700", p.fields[6]); err == nil {
+		loc = offset.Location()
+	}
+	return Version{
+		Version:   version,
+		Registry:  p.fields[1],
+		Serial:    p.fields[2],
+		Records:   records,
+		StartDate: p.fields[4],
+		EndDate:   p.fields[5],
+		UtcOffset: p.fields[6],
+		StartTime: parseDate(p.fields[4], loc),
+		EndTime:   parseDate(p.fields[5], loc),
+	}, nil
+}
+
+// parseDate parses a YYYYMMDD date, leniently returning the zero time for
+// empty or invalid dates.
+func parseDate(date string, loc *time.Location) time.Time {
+	t, err := time.ParseInLocation("20060102", date, loc)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+func (p parser) parseSummary() (Summary, error) {
+	if len(p.fields) < 5 {
+		return Summary{}, errFieldCount
+	}
+	count, err := strconv.Atoi(p.fields[4])
+	if err != nil {
+		return Summary{}, err
+	}
+	return Summary{
+		Registry: p.fields[0],
+		Type:     p.fields[2],
+		Count:    count,
+	}, nil
+}
+
+func (p parser) parseIp() (IpRecord, error) {
+	record, err := p.buildRecord()
+	if err != nil {
+		return IpRecord{}, err
+	}
+	start, err := netip.ParseAddr(p.fields[3])
+	if err != nil {
+		return IpRecord{}, err
+	}
+	return IpRecord{
+		Record: record,
+		Start:  start,
+	}, nil
+}
+
+func (p parser) parseAsn() (AsnRecord, error) {
+	record, err := p.buildRecord()
+	if err != nil {
+		return AsnRecord{}, err
+	}
+	start, err := strconv.Atoi(p.fields[3])
+	if err != nil {
+		return AsnRecord{}, err
+	}
+	return AsnRecord{
+		Record: record,
+		Start:  start,
+	}, nil
+}
+
+func (p parser) buildRecord() (Record, error) {
+	if len(p.fields) < 7 {
+		return Record{}, errFieldCount
+	}
+	value, err := strconv.Atoi(p.fields[4])
+	if err != nil {
+		return Record{}, err
+	}
+
+	record := Record{
+		Registry: p.fields[0],
+		Cc:       p.fields[1],
+		Type:     p.fields[2],
+		Value:    value,
+		Date:     p.fields[5],
+		Time:     parseDate(p.fields[5], time.UTC),
+		Status:   p.fields[6],
+		Line:     p.lineNumber,
+	}
+
+	if len(p.fields) > 7 { // extended record
+		record.OpaqueId = p.fields[7]
+	}
+
+	return record, nil
+}
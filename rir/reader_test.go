@@ -0,0 +1,277 @@
+package rir
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Fri Feb 27 22:11:38 CET 2015 File of 4.2M
+// BenchmarkReader	       1	1181852831 ns/op (~1.18s)
+
+// Mon Mar 16 14:33:52 CET 2015
+// BenchmarkReader	       1	1235675316 ns/op
+func BenchmarkReader(b *testing.B) {
+	path := filepath.Join(os.Getenv("HOME"), ".rir", "ripencc", "latest")
+	content, err := os.ReadFile(path)
+	if err != nil || len(content) == 0 {
+		log.Fatal(" File for bench is empty!")
+	}
+	b.ResetTimer()
+	data := bytes.NewBuffer(content)
+	_, _ = NewReader(data).Read()
+}
+
+func findIpWith(records Records, address string) IpRecord {
+	for _, ip := range records.Ips {
+		if address == ip.Start.String() {
+			return ip
+		}
+	}
+	log.Fatalf("Cannot find ip with address %s", address)
+	return IpRecord{}
+}
+
+func findAsnWith(records Records, number int) AsnRecord {
+	for _, asn := range records.Asns {
+		if asn.Start <= number && number < asn.Start+asn.Value {
+			return asn
+		}
+	}
+	log.Fatalf("Cannot find asn with number %d", number)
+	return AsnRecord{}
+
+}
+
+func TestParsingRegularFile(t *testing.T) {
+	data := bytes.NewBufferString(regularData)
+
+	records, err := NewReader(data).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recordsCount, asnCount, ipv4Count, ipv6Count := 23486, 3986, 17947, 1553
+
+	if records.Version != 2.3 {
+		t.Errorf("records version: expected 2.3 got %f", records.Version)
+	}
+	if records.Count != recordsCount {
+		t.Errorf("total records count: expected %d got %d", recordsCount, records.Count)
+	}
+	if records.AsnCount != asnCount {
+		t.Errorf("asn count: expected %d got %d", asnCount, records.AsnCount)
+	}
+	if records.Ipv4Count != ipv4Count {
+		t.Errorf("ipv4 count: expected %d got %d", ipv4Count, records.Ipv4Count)
+	}
+	if records.Ipv6Count != ipv6Count {
+		t.Errorf("ipv6 count: expected %d got %d", ipv6Count, records.Ipv6Count)
+	}
+
+	if len(records.Asns) != 2 {
+		t.Errorf("asn real count: expected %d got %d", 2, len(records.Asns))
+	}
+
+	if len(records.Ips) != 11 {
+		t.Errorf("ips real count: expected %d got %d. Content: %v", 11, len(records.Ips), records.Ips)
+	}
+
+	asnRecord := findAsnWith(records, 173)
+	if asnRecord.Status != "allocated" {
+		t.Errorf("asn record status: expected 'allocated' got %q", asnRecord.Status)
+	}
+	if asnRecord.OpaqueId != "A91BD5FB" {
+		t.Errorf("asn record opaque id: expected 'A91BD5FB' got %q", asnRecord.OpaqueId)
+	}
+
+	ipRecord := findIpWith(records, "203.81.160.0")
+	if ipRecord.Status != "assigned" {
+		t.Errorf("ip record status: expected 'assigned' got %q", ipRecord.Status)
+	}
+	if ipRecord.OpaqueId != "" {
+		t.Errorf("ip record opaque id: expected empty got %q", ipRecord.OpaqueId)
+	}
+	if want := time.Date(2010, 1, 22, 0, 0, 0, 0, time.UTC); !ipRecord.Time.Equal(want) {
+		t.Errorf("ip record time: expected %v got %v", want, ipRecord.Time)
+	}
+
+	otherIpRecord := findIpWith(records, "193.9.26.0")
+	if otherIpRecord.Status != "assigned" {
+		t.Errorf("ip record status: expected 'assigned' got %q", otherIpRecord.Status)
+	}
+	if otherIpRecord.OpaqueId != "A91872ED" {
+		t.Errorf("ip record opaque id: expected 'A91872ED' got %q", otherIpRecord.OpaqueId)
+	}
+
+	splitRecord := findIpWith(records, "193.18.0.0")
+	var splitNets []netip.Prefix
+	for net := range splitRecord.Net() {
+		splitNets = append(splitNets, net)
+	}
+	if !(len(splitNets) == 2 && splitNets[0].String() == "193.18.0.0/16" && splitNets[1].String() == "193.19.0.0/19") {
+		t.Errorf("invalid parsing of split subnet, got: %v", splitNets)
+	}
+
+	splitRecord2 := findIpWith(records, "0.0.0.0")
+	fmt.Println(splitRecord2.Net())
+}
+
+func TestVersionTimes(t *testing.T) {
+	version, err := ReadVersion(strings.NewReader(regularData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2011, 1, 12, 0, 0, 0, 0, time.FixedZone("", 10*60*60)); !version.EndTime.Equal(want) {
+		t.Errorf("version end time: expected %v got %v", want, version.EndTime)
+	}
+
+	version, err = ReadVersion(strings.NewReader("2|arin|1700000000000|0|00000000|20240101|-0500\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !version.StartTime.IsZero() {
+		t.Errorf("version start time: expected zero time for 00000000 got %v", version.StartTime)
+	}
+}
+
+func readWithLimits(data string, limits Limits) error {
+	_, err := NewLimitedReader(bytes.NewBufferString(data), limits).Read()
+	return err
+}
+
+func TestReaderLimits(t *testing.T) {
+	if err := readWithLimits(regularData, DefaultLimits); err != nil {
+		t.Errorf("default limits: expected no error got %v", err)
+	}
+
+	if err := readWithLimits(regularData, Limits{MaxLineLength: 40}); !errors.Is(err, ErrLineTooLong) {
+		t.Errorf("max line length: expected %v got %v", ErrLineTooLong, err)
+	}
+
+	if err := readWithLimits(regularData, Limits{MaxRecords: 12}); !errors.Is(err, ErrTooManyRecords) {
+		t.Errorf("max records: expected %v got %v", ErrTooManyRecords, err)
+	}
+	if err := readWithLimits(regularData, Limits{MaxRecords: 13}); err != nil {
+		t.Errorf("max records: expected no error got %v", err)
+	}
+
+	if err := readWithLimits(regularData, Limits{MaxFileSize: 100}); !errors.Is(err, ErrFileTooLarge) {
+		t.Errorf("max file size: expected %v got %v", ErrFileTooLarge, err)
+	}
+	if err := readWithLimits(regularData, Limits{MaxFileSize: int64(len(regularData))}); err != nil {
+		t.Errorf("max file size: expected no error got %v", err)
+	}
+
+	longLine := regularData + "\n# " + strings.Repeat("x", bufio.MaxScanTokenSize)
+	if err := readWithLimits(longLine, Limits{}); err != nil {
+		t.Errorf("no limits: expected no error got %v", err)
+	}
+}
+
+func TestMalformedLine(t *testing.T) {
+	for _, line := range []string{
+		"apnic|JP|ipv4|203.81.64.0",
+		"apnic|JP|ipv4|203.81.64|8192|20100504|assigned",
+		"apnic|JP|asn|AS173|1|20020801|allocated",
+	} {
+		_, err := NewReader(strings.NewReader(regularData + "\n" + line)).Read()
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) {
+			t.Errorf("%q: expected a ParseError got %v", line, err)
+			continue
+		}
+		if parseErr.Line != 20 || parseErr.Text != line {
+			t.Errorf("%q: got error on line %d %q", line, parseErr.Line, parseErr.Text)
+		}
+	}
+}
+
+func TestStreamingRecords(t *testing.T) {
+	var ips, asns, summaries int
+	var version Version
+	for entry, err := range NewReader(strings.NewReader(regularData)).Records() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch entry := entry.(type) {
+		case Version:
+			version = entry
+		case Summary:
+			summaries++
+		case IpRecord:
+			ips++
+		case AsnRecord:
+			asns++
+		}
+	}
+	if version.Registry != "apnic" || summaries != 3 || ips != 11 || asns != 2 {
+		t.Errorf("entries: got version %+v, %d summaries, %d ips and %d asns", version, summaries, ips, asns)
+	}
+
+	var errs []error
+	for entry, err := range NewReader(strings.NewReader(regularData + "\napnic|JP|asn|AS173|1|20020801|allocated\napnic|NZ|asn|681|1|20020801|allocated")).Records() {
+		if err != nil {
+			errs = append(errs, err)
+			if entry != nil {
+				t.Errorf("expected no entry along with the error, got %v", entry)
+			}
+		}
+	}
+	var parseErr *ParseError
+	if len(errs) != 1 || !errors.As(errs[0], &parseErr) {
+		t.Errorf("expected iteration to stop at a single ParseError, got %v", errs)
+	}
+}
+
+func TestInvalidRecordPrefixes(t *testing.T) {
+	for _, line := range []string{
+		"apnic|JP|ipv4|2001:200::|256|20100504|assigned",
+		"apnic|JP|ipv4|255.255.255.0|512|20100504|assigned",
+		"apnic|JP|ipv6|2001:200::|129|19990813|allocated",
+		"apnic|JP|ipvx|203.81.64.0|256|20100504|assigned",
+	} {
+		records, err := NewReader(strings.NewReader(regularData + "\n" + line)).Read()
+		if err != nil {
+			t.Fatalf("%q: %v", line, err)
+		}
+		var errs []error
+		for prefix, err := range records.Ips[len(records.Ips)-1].Prefixes() {
+			if err == nil {
+				t.Errorf("%q: unexpected prefix %s", line, prefix)
+			}
+			errs = append(errs, err)
+		}
+		if len(errs) != 1 || !errors.Is(errs[0], ErrInvalidRecord) {
+			t.Errorf("%q: expected a single ErrInvalidRecord, got %v", line, errs)
+		}
+	}
+}
+
+var regularData = `2.3|apnic|20110113|23486|19850701|20110112|+1000
+# line to be ignored
+apnic|*|asn|*|3986|summary
+apnic|*|ipv4|*|17947|summary
+
+apnic|*|ipv6|*|1553|summary
+apnic|JP|asn|173|1|20020801|allocated|A91BD5FB
+apnic|NZ|asn|681|1|20020801|allocated
+apnic|MM|ipv4|203.81.64.0|8192|20100504|assigned
+apnic|MM|ipv4|203.81.160.0|4096|20100122|assigned
+apnic|KP|ipv4|175.45.176.0|1024|20100122|assigned
+apnic|JP|ipv6|2001:200::|35|19990813|allocated
+apnic|JP|ipv6|2001:200:2000::|35|20030423|allocated
+apnic|JP|ipv6|2001:200:4000::|34|20030423|allocated
+apnic|JP|ipv6|2001:200:8000::|33|20030423|allocated
+ripencc|PL|ipv4|193.9.25.0|256|20090225|assigned
+ripencc|DE|ipv4|193.18.0.0|73728|19920922|assigned
+ripencc|XX|ipv4|0.0.0.0|4294967295|19920923|assigned
+ripencc|HU|ipv4|193.9.26.0|512|20081222|assigned|A91872ED`
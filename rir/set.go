@@ -0,0 +1,27 @@
+package rir
+
+import "go4.org/netipx"
+
+// CountrySet returns the addresses delegated to the country cc as an
+// aggregated set, for membership checks and minimal prefix lists.
+func (r Records) CountrySet(cc string) (*netipx.IPSet, error) {
+	var b netipx.IPSetBuilder
+	for _, prefix := range r.Country(cc) {
+		b.AddPrefix(prefix)
+	}
+	return b.IPSet()
+}
+
+// CountrySet returns the addresses delegated to the country cc by every
+// provider as an aggregated set.
+func (d *Dataset) CountrySet(cc string) (*netipx.IPSet, error) {
+	var b netipx.IPSetBuilder
+	for _, r := range d.records {
+		set, err := r.CountrySet(cc)
+		if err != nil {
+			return nil, err
+		}
+		b.AddSet(set)
+	}
+	return b.IPSet()
+}
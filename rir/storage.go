@@ -0,0 +1,227 @@
+package rir
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Storage holds the files of the cache: the latest download, snapshots and
+// index of each provider. Keys are slash separated paths such as
+// "ripencc/latest". Missing keys are reported with errors matching
+// fs.ErrNotExist.
+type Storage interface {
+	Open(key string) (io.ReadCloser, error)
+	Stat(key string) (fs.FileInfo, error)
+	// Put replaces the content of a key atomically.
+	Put(key string, content []byte) error
+	Delete(key string) error
+	// List returns the names of the keys directly under dir.
+	List(dir string) ([]string, error)
+	// Location is where key is stored, a path or URL, for messages and
+	// provenance.
+	Location(key string) string
+}
+
+// Store is where providers without Options cache their files, the cache
+// directory by default.
+var Store Storage = DirStorage{}
+
+// ParseStorage returns the storage described by spec: "dir" or a path for a
+// directory, "memory" for a memory-only cache, or s3://bucket/prefix for an
+// S3 bucket.
+func ParseStorage(spec string) (Storage, error) {
+	switch {
+	case spec == "" || spec == "dir":
+		return DirStorage{}, nil
+	case spec == "memory":
+		return NewMemoryStorage(), nil
+	case strings.HasPrefix(spec, "s3://"):
+		return NewS3Storage(spec)
+	case strings.Contains(spec, "://"):
+		return nil, fmt.Errorf("unknown storage %q", spec)
+	}
+	return DirStorage{Dir: spec}, nil
+}
+
+// ReadStored reads the content of a key of Store.
+func ReadStored(key string) ([]byte, error) {
+	return readKey(Store, key)
+}
+
+func readKey(s Storage, key string) ([]byte, error) {
+	r, err := s.Open(key)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// DirStorage stores files in a directory, the cache directory if Dir is
+// empty.
+type DirStorage struct {
+	Dir string
+}
+
+func (s DirStorage) path(key string) string {
+	return filepath.Join(cmp.Or(s.Dir, homeCacheDir()), filepath.FromSlash(key))
+}
+
+func (s DirStorage) Location(key string) string {
+	return s.path(key)
+}
+
+func (s DirStorage) Open(key string) (io.ReadCloser, error) {
+	return os.Open(s.path(key))
+}
+
+func (s DirStorage) Stat(key string) (fs.FileInfo, error) {
+	return os.Stat(s.path(key))
+}
+
+func (s DirStorage) Put(key string, content []byte) error {
+	p := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), "."+filepath.Base(p)+"-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+func (s DirStorage) Delete(key string) error {
+	return os.Remove(s.path(key))
+}
+
+func (s DirStorage) List(dir string) ([]string, error) {
+	entries, err := os.ReadDir(s.path(dir))
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
+
+// MemoryStorage keeps files in memory, for read-only filesystems and
+// short-lived processes: files are downloaded again by every process.
+type MemoryStorage struct {
+	mu    sync.RWMutex
+	files map[string]storedFile
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{files: make(map[string]storedFile)}
+}
+
+// storedFile is a file of a storage without a filesystem, described by its
+// fs.FileInfo methods.
+type storedFile struct {
+	name    string
+	content []byte
+	size    int64
+	modTime time.Time
+}
+
+func (f storedFile) Name() string       { return f.name }
+func (f storedFile) Size() int64        { return f.size }
+func (f storedFile) Mode() fs.FileMode  { return 0o600 }
+func (f storedFile) ModTime() time.Time { return f.modTime }
+func (f storedFile) IsDir() bool        { return false }
+func (f storedFile) Sys() any           { return nil }
+
+func (s *MemoryStorage) get(op, key string) (storedFile, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	f, ok := s.files[key]
+	if !ok {
+		return storedFile{}, &fs.PathError{Op: op, Path: key, Err: fs.ErrNotExist}
+	}
+	return f, nil
+}
+
+func (s *MemoryStorage) Open(key string) (io.ReadCloser, error) {
+	f, err := s.get("open", key)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(f.content)), nil
+}
+
+func (s *MemoryStorage) Stat(key string) (fs.FileInfo, error) {
+	f, err := s.get("stat", key)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (s *MemoryStorage) Put(key string, content []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[key] = storedFile{path.Base(key), bytes.Clone(content), int64(len(content)), time.Now()}
+	return nil
+}
+
+func (s *MemoryStorage) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.files[key]; !ok {
+		return &fs.PathError{Op: "remove", Path: key, Err: fs.ErrNotExist}
+	}
+	delete(s.files, key)
+	return nil
+}
+
+func (s *MemoryStorage) Location(key string) string {
+	return "memory:" + key
+}
+
+func (s *MemoryStorage) List(dir string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var names []string
+	for key := range s.files {
+		if name, ok := strings.CutPrefix(key, dir+"/"); ok && !strings.Contains(name, "/") {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// StoreDir returns the directory of Store if it is a DirStorage. Features
+// walking the cache, such as pruning, need one.
+func StoreDir() (string, bool) {
+	s, ok := Store.(DirStorage)
+	return cmp.Or(s.Dir, homeCacheDir()), ok
+}
+
+func isDirStorage() bool {
+	_, ok := StoreDir()
+	return ok
+}
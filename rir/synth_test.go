@@ -0,0 +1,57 @@
+package rir
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/bits"
+	"testing"
+)
+
+func TestSyntheticFile(t *testing.T) {
+	opts := SynthOptions{Ipv4: 500, Ipv6: 100, Asns: 200, Seed: 7}
+	var b bytes.Buffer
+	if err := WriteSyntheticFile(&b, opts); err != nil {
+		t.Fatal(err)
+	}
+	records, err := NewReader(bytes.NewReader(b.Bytes())).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if records.Registry != "test" || records.Count != 800 || len(records.Ips) != 600 || len(records.Asns) != 200 || records.Ipv4Count != 500 {
+		t.Errorf("got registry %q, %d records, %d ips, %d asns", records.Registry, records.Count, len(records.Ips), len(records.Asns))
+	}
+
+	var oddSizes, asnBlocks int
+	for _, record := range records.Ips {
+		for _, err := range record.Prefixes() {
+			if err != nil {
+				t.Errorf("line %d: %v", record.Line, err)
+			}
+		}
+		if record.Type == IPv4 && bits.OnesCount(uint(record.Value)) > 1 {
+			oddSizes++
+		}
+	}
+	for _, record := range records.Asns {
+		if record.Value > 1 {
+			asnBlocks++
+		}
+	}
+	if oddSizes == 0 || asnBlocks == 0 {
+		t.Errorf("got %d IPv4 records of sizes not powers of two and %d AS blocks, want some", oddSizes, asnBlocks)
+	}
+
+	data, err := SyntheticProvider{Options: opts}.GetData(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := io.ReadAll(data)
+	if !bytes.Equal(again, b.Bytes()) {
+		t.Error("SyntheticProvider: the same options yielded another file")
+	}
+
+	if err := WriteSyntheticFile(io.Discard, SynthOptions{Ipv4: 1_000_000}); err != ErrSynthTooLarge {
+		t.Errorf("WriteSyntheticFile(1M IPv4 records): got %v, want %v", err, ErrSynthTooLarge)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"math/big"
+	"math/rand"
+	"net/netip"
+	"slices"
+	"sort"
+
+	"go4.org/netipx"
+)
+
+// CountryAddress is an address of the space of a country.
+type CountryAddress struct {
+	Country string     `json:"country"`
+	Address netip.Addr `json:"address"`
+}
+
+func (a CountryAddress) String() string {
+	return tsvLine(a.Country, a.Address)
+}
+
+// sampleAddresses draws n distinct addresses of set, uniformly at random,
+// in ascending order. Every address of set is returned when it holds no more
+// than n of them.
+func sampleAddresses(set *netipx.IPSet, n int, rng *rand.Rand) []netip.Addr {
+	ranges := set.Ranges()
+	// starts[i] is the number of addresses of ranges[:i]
+	starts := make([]*big.Int, len(ranges))
+	total := big.NewInt(0)
+	for i, r := range ranges {
+		starts[i] = new(big.Int).Set(total)
+		total.Add(total, rangeSize(r))
+	}
+
+	var addrs []netip.Addr
+	if total.Cmp(big.NewInt(int64(n))) <= 0 {
+		for _, r := range ranges {
+			for addr := r.From(); ; addr = addr.Next() {
+				addrs = append(addrs, addr)
+				if addr == r.To() {
+					break
+				}
+			}
+		}
+		return addrs
+	}
+
+	seen := make(map[netip.Addr]bool, n)
+	for len(addrs) < n {
+		offset := new(big.Int).Rand(rng, total)
+		i := sort.Search(len(starts), func(i int) bool { return starts[i].Cmp(offset) > 0 }) - 1
+		addr := addrAdd(ranges[i].From(), offset.Sub(offset, starts[i]))
+		if !seen[addr] {
+			seen[addr] = true
+			addrs = append(addrs, addr)
+		}
+	}
+	slices.SortFunc(addrs, netip.Addr.Compare)
+	return addrs
+}
+
+func rangeSize(r netipx.IPRange) *big.Int {
+	from, to := addrInt(r.From()), addrInt(r.To())
+	return to.Sub(to, from).Add(to, big.NewInt(1))
+}
+
+func addrInt(addr netip.Addr) *big.Int {
+	return new(big.Int).SetBytes(addr.AsSlice())
+}
+
+// addrAdd returns addr plus offset, which must not overflow.
+func addrAdd(addr netip.Addr, offset *big.Int) netip.Addr {
+	value := addrInt(addr)
+	value.Add(value, offset)
+	b := make([]byte, addr.BitLen()/8)
+	value.FillBytes(b)
+	result, _ := netip.AddrFromSlice(b)
+	return result
+}
+
+// countrySample draws n addresses drawn from the space of each queried
+// country, as selected by the query filters.
+func (q Query) countrySample(ctx context.Context, n int, rng *rand.Rand) []CountryAddress {
+	builders := make(map[string]*netipx.IPSetBuilder)
+	for r := range excludeByCountry(q.readRegionsCountry(ctx)) {
+		b, ok := builders[r.Country]
+		if !ok {
+			b = &netipx.IPSetBuilder{}
+			builders[r.Country] = b
+		}
+		b.AddPrefix(r.Prefix)
+	}
+
+	var result []CountryAddress
+	for _, cc := range q.filter.Countries {
+		b, ok := builders[cc]
+		if !ok {
+			continue
+		}
+		for _, addr := range sampleAddresses(check1(b.IPSet()), n, rng) {
+			result = append(result, CountryAddress{Country: cc, Address: addr})
+		}
+		delete(builders, cc)
+	}
+	return result
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"net/netip"
+	"testing"
+
+	"go4.org/netipx"
+)
+
+func TestSampleAddresses(t *testing.T) {
+	var b netipx.IPSetBuilder
+	b.AddPrefix(netip.MustParsePrefix("192.0.2.0/30"))
+	b.AddPrefix(netip.MustParsePrefix("2001:db8::/126"))
+	set, err := b.IPSet()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	all := sampleAddresses(set, 10, rand.New(rand.NewSource(1)))
+	if got := fmt.Sprint(all); got != "[192.0.2.0 192.0.2.1 192.0.2.2 192.0.2.3 2001:db8:: 2001:db8::1 2001:db8::2 2001:db8::3]" {
+		t.Errorf("sampleAddresses(n > size): got %s", got)
+	}
+
+	for seed := range int64(20) {
+		addrs := sampleAddresses(set, 5, rand.New(rand.NewSource(seed)))
+		if len(addrs) != 5 {
+			t.Fatalf("sampleAddresses(5): got %d addresses", len(addrs))
+		}
+		for i, addr := range addrs {
+			if !set.Contains(addr) || (i > 0 && addrs[i-1].Compare(addr) >= 0) {
+				t.Errorf("sampleAddresses(5): got %v, want distinct sorted addresses of the set", addrs)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/monoidic/rir/rir"
+)
+
+// AsnDelegation is the structured form of an ASN delegation record served by
+// the API.
+type AsnDelegation struct {
+	Registry    string `json:"registry"`
+	Country     string `json:"country,omitempty"`
+	CountryName string `json:"country_name,omitempty"`
+	First       int    `json:"first"`
+	Last        int    `json:"last"`
+	Count       int    `json:"count"`
+	Date        string `json:"date,omitempty"`
+	Status      string `json:"status"`
+	OpaqueId    string `json:"opaque_id,omitempty"`
+}
+
+func (d AsnDelegation) String() string {
+	asns := fmt.Sprintf("AS%d", d.First)
+	if d.Last != d.First {
+		asns += fmt.Sprintf("-AS%d", d.Last)
+	}
+	if d.CountryName != "" {
+		return tsvLine(d.Country, asns, d.Registry, d.Date, d.Status, d.CountryName)
+	}
+	return tsvLine(d.Country, asns, d.Registry, d.Date, d.Status)
+}
+
+func newAsnDelegation(r rir.AsnRecord) AsnDelegation {
+	return AsnDelegation{
+		Registry:    r.Registry,
+		Country:     r.Cc,
+		CountryName: countryName(r.Cc),
+		First:       r.Start,
+		Last:        r.Start + r.Value - 1,
+		Count:       r.Value,
+		Date:        r.Date,
+		Status:      r.Status,
+		OpaqueId:    r.OpaqueId,
+	}
+}
+
+// server answers API requests from the records of every provider, loaded
+// once at startup along with their changes since the previous serial.
+type server struct {
+	dataset *rir.Dataset
+	changes []registryChanges
+	// sources answer address lookups along with the registry data
+	sources []GeoSource
+	// index is the dataset in the binary index format served as
+	// /snapshot.bin, built on first request
+	indexOnce sync.Once
+	index     []byte
+	indexErr  error
+}
+
+// datasetSource is the registry data of the server as a GeoSource.
+type datasetSource struct {
+	dataset *rir.Dataset
+}
+
+func (s datasetSource) Name() string {
+	return "registry"
+}
+
+func (s datasetSource) Kind() CountryKind {
+	return Registration
+}
+
+func (s datasetSource) Country(ctx context.Context, addr netip.Addr) (string, error) {
+	record, _, _ := s.dataset.LookupIP(addr)
+	return record.Cc, nil
+}
+
+// IpLookup is the answer of every source about an address.
+type IpLookup struct {
+	Address  netip.Addr   `json:"address"`
+	Prefix   netip.Prefix `json:"prefix,omitempty"`
+	Registry string       `json:"registry,omitempty"`
+	Answers  []GeoAnswer  `json:"answers"`
+	Verdict  string       `json:"verdict"`
+}
+
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /asn/{number}", s.handleAsn)
+	mux.HandleFunc("GET /ip/{addr}", s.handleIp)
+	mux.HandleFunc("GET /country/{cc}/asns", s.handleCountryAsns)
+	mux.HandleFunc("GET /snapshot.bin", s.handleSnapshot)
+	for _, format := range []string{"atom", "rss"} {
+		mux.HandleFunc("GET /country/{cc}/feed."+format, func(w http.ResponseWriter, r *http.Request) {
+			s.writeCountryFeed(w, r, format)
+		})
+	}
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Writing response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+func (s *server) handleAsn(w http.ResponseWriter, r *http.Request) {
+	asn, err := parseAsn(r.PathValue("number"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid AS number")
+		return
+	}
+
+	if record, ok := s.dataset.Asn(asn); ok {
+		writeJSON(w, http.StatusOK, newAsnDelegation(record))
+		return
+	}
+	writeError(w, http.StatusNotFound, "AS number not delegated")
+}
+
+func (s *server) handleIp(w http.ResponseWriter, r *http.Request) {
+	addr, err := netip.ParseAddr(r.PathValue("addr"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid address")
+		return
+	}
+
+	lookup := IpLookup{Address: addr}
+	if record, prefix, ok := s.dataset.LookupIP(addr); ok {
+		lookup.Prefix = prefix
+		lookup.Registry = record.Registry
+	}
+	sources := append([]GeoSource{datasetSource{s.dataset}}, s.sources...)
+	lookup.Answers = askSources(r.Context(), sources, addr)
+	lookup.Verdict = consensusVerdict(lookup.Answers)
+	writeJSON(w, http.StatusOK, lookup)
+}
+
+func (s *server) handleCountryAsns(w http.ResponseWriter, r *http.Request) {
+	cc := strings.ToUpper(r.PathValue("cc"))
+	if len(cc) != 2 {
+		writeError(w, http.StatusBadRequest, "invalid country code")
+		return
+	}
+
+	delegations := []AsnDelegation{}
+	for _, record := range s.dataset.CountryAsns(cc) {
+		delegations = append(delegations, newAsnDelegation(record))
+	}
+	writeJSON(w, http.StatusOK, delegations)
+}
+
+// handleSnapshot serves the dataset index for clients and replicas to load
+// with -bootstrap. Its ETag changes with the serials of the registry files.
+func (s *server) handleSnapshot(w http.ResponseWriter, r *http.Request) {
+	s.indexOnce.Do(func() {
+		var b bytes.Buffer
+		s.indexErr = s.dataset.WriteIndex(&b)
+		s.index = b.Bytes()
+	})
+	if s.indexErr != nil {
+		log.Printf("Writing index: %v", s.indexErr)
+		writeError(w, http.StatusInternalServerError, "cannot build index")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("ETag", `"`+s.dataset.Serials()+`"`)
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(s.index))
+}
+
+// serveCommand runs the HTTP API.
+func serveCommand(ctx context.Context, args []string) {
+	fset := flag.NewFlagSet("serve", flag.ExitOnError)
+	listen := fset.String("listen", "localhost:8080", "address to listen on")
+	mmdb := fset.String("mmdb", "", "MaxMind format database answering address lookups along with the registry data")
+	check(fset.Parse(args))
+
+	s := &server{}
+	if *mmdb != "" {
+		s.sources = append(s.sources, newMmdbSource(*mmdb))
+	}
+	var all []rir.Records
+	for records := range retrieveData(ctx) {
+		all = append(all, records)
+		if changes, ok := latestChanges(records); ok {
+			s.changes = append(s.changes, changes)
+		}
+	}
+	s.dataset = rir.NewDataset(all...)
+
+	srv := &http.Server{Addr: *listen, Handler: s.handler()}
+	go func() {
+		<-ctx.Done()
+		srv.Shutdown(context.Background())
+	}()
+
+	log.Printf("Listening on %s", *listen)
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		check(err)
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/monoidic/rir/rir"
+)
+
+func TestServerAsns(t *testing.T) {
+	s := &server{dataset: rir.NewDataset(rir.Records{
+		Registry: "ripencc",
+		Asns: []rir.AsnRecord{
+			{Record: rir.Record{Registry: "ripencc", Cc: "FR", Type: rir.ASN, Value: 1, Date: "19940101", Status: "allocated", OpaqueId: "b8f0a8c3"}, Start: 3215},
+			{Record: rir.Record{Registry: "ripencc", Cc: "DE", Type: rir.ASN, Value: 10, Date: "20000101", Status: "assigned"}, Start: 64500},
+		},
+	})}
+	h := s.handler()
+
+	get := func(path string, v any) int {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if v != nil && rec.Code == http.StatusOK {
+			if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+				t.Fatalf("%s: %v", path, err)
+			}
+		}
+		return rec.Code
+	}
+
+	var delegation AsnDelegation
+	if code := get("/asn/AS64505", &delegation); code != http.StatusOK {
+		t.Fatalf("/asn/AS64505: status %d", code)
+	}
+	if delegation.Country != "DE" || delegation.First != 64500 || delegation.Last != 64509 {
+		t.Errorf("/asn/AS64505: got %+v", delegation)
+	}
+
+	if code := get("/asn/1", nil); code != http.StatusNotFound {
+		t.Errorf("/asn/1: status %d, want %d", code, http.StatusNotFound)
+	}
+	if code := get("/asn/nope", nil); code != http.StatusBadRequest {
+		t.Errorf("/asn/nope: status %d, want %d", code, http.StatusBadRequest)
+	}
+
+	var delegations []AsnDelegation
+	if code := get("/country/fr/asns", &delegations); code != http.StatusOK {
+		t.Fatalf("/country/fr/asns: status %d", code)
+	}
+	if len(delegations) != 1 || delegations[0].OpaqueId != "b8f0a8c3" {
+		t.Errorf("/country/fr/asns: got %+v", delegations)
+	}
+}
+
+func TestServerFeeds(t *testing.T) {
+	record := func(cc, start string, value int) rir.IpRecord {
+		return rir.IpRecord{Record: rir.Record{Registry: "ripencc", Cc: cc, Type: rir.IPv4, Value: value, Status: "allocated"}, Start: netip.MustParseAddr(start)}
+	}
+	previous := rir.Records{Registry: "ripencc", Serial: "1", Ips: []rir.IpRecord{
+		record("FR", "2.0.0.0", 1<<20),
+		record("FR", "5.0.0.0", 256),
+		record("DE", "5.1.0.0", 256),
+	}}
+	current := rir.Records{Registry: "ripencc", Serial: "2", Ips: []rir.IpRecord{
+		record("FR", "2.0.0.0", 1<<20),
+		record("FR", "5.2.0.0", 512),
+		record("DE", "5.1.0.0", 256),
+	}}
+	s := &server{dataset: rir.NewDataset(current), changes: []registryChanges{compareSerials(previous, current)}}
+	h := s.handler()
+
+	for path, want := range map[string]string{
+		"/country/fr/feed.atom": "<content>+5.2.0.0/23&#xA;-5.0.0.0/24&#xA;</content>",
+		"/country/FR/feed.rss":  "<title>ripencc 2: 1 new and 1 removed delegations since serial 1</title>",
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("%s: status %d, expected %q in\n%s", path, rec.Code, want, rec.Body)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/country/de/feed.atom", nil))
+	if strings.Contains(rec.Body.String(), "<entry>") {
+		t.Errorf("/country/de/feed.atom: expected no entries, got\n%s", rec.Body)
+	}
+}
+
+// staticSource answers the same country for every address.
+type staticSource string
+
+func (s staticSource) Name() string      { return "static" }
+func (s staticSource) Kind() CountryKind { return Operational }
+func (s staticSource) Country(ctx context.Context, addr netip.Addr) (string, error) {
+	return string(s), nil
+}
+
+func TestServerIp(t *testing.T) {
+	s := &server{
+		dataset: rir.NewDataset(rir.Records{Registry: "ripencc", Ips: []rir.IpRecord{
+			{Record: rir.Record{Registry: "ripencc", Cc: "FR", Type: rir.IPv4, Value: 256, Status: "allocated"}, Start: netip.MustParseAddr("192.0.2.0")},
+		}}),
+		sources: []GeoSource{staticSource("DE")},
+	}
+
+	rec := httptest.NewRecorder()
+	s.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ip/192.0.2.1", nil))
+	var lookup struct {
+		Prefix  string `json:"prefix"`
+		Answers []struct {
+			Source  string `json:"source"`
+			Country string `json:"country"`
+		} `json:"answers"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &lookup); err != nil {
+		t.Fatal(err)
+	}
+	if lookup.Prefix != "192.0.2.0/24" || len(lookup.Answers) != 2 ||
+		lookup.Answers[0].Source != "registry" || lookup.Answers[0].Country != "FR" ||
+		lookup.Answers[1].Source != "static" || lookup.Answers[1].Country != "DE" {
+		t.Errorf("/ip/192.0.2.1: got %s", rec.Body)
+	}
+}
+
+func TestServerSnapshot(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dataset := rir.NewDataset(
+		rir.Records{Registry: "ripencc", Serial: "20250102", Ips: []rir.IpRecord{
+			{Record: rir.Record{Registry: "ripencc", Cc: "FR", Type: rir.IPv4, Value: 1 << 20, Status: "allocated"}, Start: netip.MustParseAddr("2.0.0.0")},
+		}},
+		rir.Records{Registry: "arin", Serial: "20250101"},
+	)
+	s := &server{dataset: dataset}
+	ts := httptest.NewServer(s.handler())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/snapshot.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if etag := resp.Header.Get("ETag"); etag != `"arin-20250101.ripencc-20250102"` {
+		t.Errorf("ETag: got %s", etag)
+	}
+
+	// downloaded, then answered from the cache with a 304
+	for range 2 {
+		records, err := fetchBootstrap(context.Background(), ts.URL+"/snapshot.bin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		loaded := rir.NewDataset(records...)
+		if record, _, ok := loaded.LookupIP(netip.MustParseAddr("2.1.2.3")); !ok || record.Cc != "FR" {
+			t.Errorf("lookup: got %+v", record)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/snapshot.bin", nil)
+	req.Header.Set("If-None-Match", `"arin-20250101.ripencc-20250102"`)
+	rec := httptest.NewRecorder()
+	s.handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("If-None-Match: status %d, want %d", rec.Code, http.StatusNotModified)
+	}
+}
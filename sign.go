@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Artifacts are signed with Ed25519 in the minisign format, so that the
+// machines fetching them can check them with minisign -Vm file -p rir.pub as
+// well as with rir verify.
+
+// signatureAlgorithm is the minisign algorithm of signatures of the whole
+// content; minisign also verifies them.
+const signatureAlgorithm = "Ed"
+
+// A signingKey is an Ed25519 key and its minisign key ID.
+type signingKey struct {
+	id  [8]byte
+	key ed25519.PrivateKey
+}
+
+// artifactKey signs the artifacts written to files when set by -sign-key.
+var artifactKey *signingKey
+
+func loadArtifactKey(path string) error {
+	key, err := readSigningKey(path)
+	artifactKey = key
+	return err
+}
+
+// keyFile parses the base64 line following the untrusted comment of a key or
+// signature file.
+func keyFile(content []byte, size int) ([]byte, error) {
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) < 2 || !strings.HasPrefix(lines[0], "untrusted comment:") {
+		return nil, errors.New("not a minisign file")
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[1]))
+	if err != nil {
+		return nil, err
+	}
+	if len(decoded) != size || string(decoded[:2]) != signatureAlgorithm {
+		return nil, errors.New("unsupported key or signature")
+	}
+	return decoded, nil
+}
+
+func readSigningKey(path string) (*signingKey, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	decoded, err := keyFile(content, 2+8+ed25519.PrivateKeySize)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	k := &signingKey{key: ed25519.PrivateKey(decoded[10:])}
+	copy(k.id[:], decoded[2:10])
+	return k, nil
+}
+
+// publicKey is a minisign public key.
+type publicKey struct {
+	id  [8]byte
+	key ed25519.PublicKey
+}
+
+func readPublicKey(path string) (publicKey, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return publicKey{}, err
+	}
+	decoded, err := keyFile(content, 2+8+ed25519.PublicKeySize)
+	if err != nil {
+		return publicKey{}, fmt.Errorf("%s: %w", path, err)
+	}
+	k := publicKey{key: ed25519.PublicKey(decoded[10:])}
+	copy(k.id[:], decoded[2:10])
+	return k, nil
+}
+
+// generateKeys writes a new secret key and its public key in the minisign
+// format.
+func generateKeys(secretPath, publicPath string) error {
+	public, secret, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	var id [8]byte
+	rand.Read(id[:])
+
+	encode := func(key []byte) string {
+		return base64.StdEncoding.EncodeToString(append(append([]byte(signatureAlgorithm), id[:]...), key...))
+	}
+	// the secret key, unlike minisign ones, is not encrypted: daemons
+	// use it unattended
+	content := fmt.Sprintf("untrusted comment: rir secret key %X\n%s\n", id, encode(secret))
+	if err := writeNewFile(secretPath, content, 0o600); err != nil {
+		return err
+	}
+	content = fmt.Sprintf("untrusted comment: minisign public key %X\n%s\n", id, encode(public))
+	return writeNewFile(publicPath, content, 0o644)
+}
+
+// writeNewFile writes a file that must not exist yet.
+func writeNewFile(path, content string, mode os.FileMode) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(content); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// signature returns the minisign signature of content, named name in its
+// trusted comment.
+func (k *signingKey) signature(name string, content []byte) []byte {
+	sig := ed25519.Sign(k.key, content)
+	trusted := fmt.Sprintf("timestamp:%d\tfile:%s", time.Now().Unix(), name)
+	global := ed25519.Sign(k.key, append(append([]byte(nil), sig...), trusted...))
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "untrusted comment: signature from rir secret key %X\n", k.id)
+	fmt.Fprintln(&b, base64.StdEncoding.EncodeToString(append(append([]byte(signatureAlgorithm), k.id[:]...), sig...)))
+	fmt.Fprintf(&b, "trusted comment: %s\n", trusted)
+	fmt.Fprintln(&b, base64.StdEncoding.EncodeToString(global))
+	return b.Bytes()
+}
+
+var errBadSignature = errors.New("signature verification failed")
+
+// verifySignature checks a minisign signature of content, returning its
+// trusted comment.
+func verifySignature(key publicKey, content, signature []byte) (string, error) {
+	decoded, err := keyFile(signature, 2+8+ed25519.SignatureSize)
+	if err != nil {
+		return "", err
+	}
+	lines := strings.Split(strings.TrimSpace(string(signature)), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[2], "trusted comment: ") {
+		return "", errors.New("not a minisign signature")
+	}
+	if !bytes.Equal(decoded[2:10], key.id[:]) {
+		return "", fmt.Errorf("signed by key %X, not %X", decoded[2:10], key.id)
+	}
+	sig := decoded[10:]
+	if !ed25519.Verify(key.key, content, sig) {
+		return "", errBadSignature
+	}
+	trusted := strings.TrimPrefix(lines[2], "trusted comment: ")
+	global, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[3]))
+	if err != nil || !ed25519.Verify(key.key, append(append([]byte(nil), sig...), trusted...), global) {
+		return "", errBadSignature
+	}
+	return trusted, nil
+}
+
+// signArtifact writes the signature of an artifact file next to it, as
+// path.minisig, when -sign-key is set.
+func signArtifact(path string, content []byte) error {
+	if artifactKey == nil {
+		return nil
+	}
+	tmp := path + ".minisig.tmp"
+	if err := os.WriteFile(tmp, artifactKey.signature(filepath.Base(path), content), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path+".minisig")
+}
+
+const signUsage = `usage:
+	rir sign keygen [-o name]       write name.key and name.pub
+	rir sign -key file file...      sign files as file.minisig
+	rir sign verify -pubkey file file...`
+
+// signCommand generates signing keys, signs files and verifies their
+// signatures.
+func signCommand(_ context.Context, args []string) {
+	if len(args) > 0 && args[0] == "keygen" {
+		fset := flag.NewFlagSet("keygen", flag.ExitOnError)
+		name := fset.String("o", "rir", "path of the keys, without the .key and .pub extensions")
+		check(fset.Parse(args[1:]))
+		check(generateKeys(*name+".key", *name+".pub"))
+		log.Printf("Wrote %s.key, keep it secret, and %s.pub", *name, *name)
+		return
+	}
+
+	if len(args) > 0 && args[0] == "verify" {
+		fset := flag.NewFlagSet("verify", flag.ExitOnError)
+		pubkey := fset.String("pubkey", "rir.pub", "public key of the signer")
+		check(fset.Parse(args[1:]))
+		if fset.NArg() == 0 {
+			usageError(signUsage)
+		}
+		key := check1(readPublicKey(*pubkey))
+		failed := false
+		for _, path := range fset.Args() {
+			signature, err := os.ReadFile(path + ".minisig")
+			var trusted string
+			if err == nil {
+				trusted, err = verifySignature(key, check1(os.ReadFile(path)), signature)
+			}
+			if err != nil {
+				log.Printf("%s: %v", path, err)
+				failed = true
+				continue
+			}
+			fmt.Println(tsvLine(path, "ok", trusted))
+		}
+		if failed {
+			os.Exit(1)
+		}
+		return
+	}
+
+	fset := flag.NewFlagSet("sign", flag.ExitOnError)
+	keyPath := fset.String("key", "rir.key", "secret key of the signer")
+	check(fset.Parse(args))
+	if fset.NArg() == 0 {
+		usageError(signUsage)
+	}
+	check(loadArtifactKey(*keyPath))
+	for _, path := range fset.Args() {
+		check(signArtifact(path, check1(os.ReadFile(path))))
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSignArtifact(t *testing.T) {
+	dir := t.TempDir()
+	secret, public := filepath.Join(dir, "rir.key"), filepath.Join(dir, "rir.pub")
+	if err := generateKeys(secret, public); err != nil {
+		t.Fatal(err)
+	}
+	if err := generateKeys(secret, public); err == nil {
+		t.Error("keygen overwrote an existing key")
+	}
+	defer func() { artifactKey = nil }()
+	if err := loadArtifactKey(secret); err != nil {
+		t.Fatal(err)
+	}
+	key, err := readPublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "fr.nft")
+	content := []byte("add element inet filter fr { 2.0.0.0/12 }\n")
+	if err := signArtifact(path, content); err != nil {
+		t.Fatal(err)
+	}
+	signature, err := os.ReadFile(path + ".minisig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	trusted, err := verifySignature(key, content, signature)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if !strings.HasSuffix(trusted, "\tfile:fr.nft") {
+		t.Errorf("trusted comment: got %q", trusted)
+	}
+
+	if _, err := verifySignature(key, append(content, '#'), signature); err != errBadSignature {
+		t.Errorf("tampered content: expected %v got %v", errBadSignature, err)
+	}
+	tampered := strings.Replace(string(signature), "file:fr.nft", "file:de.nft", 1)
+	if _, err := verifySignature(key, content, []byte(tampered)); err != errBadSignature {
+		t.Errorf("tampered trusted comment: expected %v got %v", errBadSignature, err)
+	}
+
+	other := filepath.Join(dir, "other")
+	if err := generateKeys(other+".key", other+".pub"); err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := readPublicKey(other + ".pub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := verifySignature(otherKey, content, signature); err == nil {
+		t.Error("verified with another key")
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/monoidic/rir/rir"
+)
+
+// SnapshotManifest pins the exact registry files a dataset was built from so
+// that it can be verified or reproduced later.
+type SnapshotManifest struct {
+	Created time.Time       `json:"created"`
+	Entries []SnapshotEntry `json:"entries"`
+}
+
+type SnapshotEntry struct {
+	Provider string `json:"provider"`
+	Serial   string `json:"serial"`
+	URL      string `json:"url"`
+	Size     int    `json:"size"`
+	Sha256   string `json:"sha256"`
+}
+
+func sha256Hex(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func CreateSnapshotManifest(ctx context.Context) SnapshotManifest {
+	manifest := SnapshotManifest{Created: time.Now().UTC()}
+
+	for _, provider := range rir.AllProviders {
+		data := check1(provider.GetData(ctx))
+		content := check1(io.ReadAll(data))
+		data.Close()
+		version := check1(rir.ReadVersion(bytes.NewReader(content)))
+		serial := version.Serial
+		if serial == "" {
+			fatalf("No serial in %s data, it cannot be pinned", provider.Name())
+		}
+		url, err := provider.ArchiveURL(version)
+		check(err)
+		// keep the pinned file around even if it predates snapshot retention
+		if _, err := rir.Store.Stat(provider.SnapshotKey(serial)); err != nil {
+			check(rir.Store.Put(provider.SnapshotKey(serial), content))
+		}
+		manifest.Entries = append(manifest.Entries, SnapshotEntry{
+			Provider: provider.Name(),
+			Serial:   serial,
+			URL:      url,
+			Size:     len(content),
+			Sha256:   sha256Hex(content),
+		})
+	}
+
+	return manifest
+}
+
+func readSnapshotManifest(path string) SnapshotManifest {
+	var manifest SnapshotManifest
+	check(json.Unmarshal(check1(os.ReadFile(path)), &manifest))
+	return manifest
+}
+
+// verifySnapshotEntry reports whether the cache holds the exact file pinned
+// by the entry.
+func verifySnapshotEntry(entry SnapshotEntry) bool {
+	provider, ok := rir.FindProvider(entry.Provider)
+	if !ok {
+		return false
+	}
+	content, err := rir.ReadStored(provider.SnapshotKey(entry.Serial))
+	if err != nil {
+		content, err = rir.ReadStored(provider.LatestKey())
+	}
+	return err == nil && sha256Hex(content) == entry.Sha256
+}
+
+// VerifySnapshot checks the cache against a manifest and returns the entries
+// that are missing or differ.
+func VerifySnapshot(manifest SnapshotManifest) []SnapshotEntry {
+	var failed []SnapshotEntry
+	for _, entry := range manifest.Entries {
+		if !verifySnapshotEntry(entry) {
+			failed = append(failed, entry)
+		}
+	}
+	return failed
+}
+
+// SnapshotMismatch is a manifest entry missing from the cache or differing
+// from it.
+type SnapshotMismatch struct {
+	Provider string `json:"provider"`
+	Serial   string `json:"serial"`
+}
+
+func (m SnapshotMismatch) String() string {
+	return tsvLine(m.Provider, m.Serial, "mismatch")
+}
+
+// FetchSnapshot downloads every file of a manifest that is not already in the
+// cache from the registry archives and stores it as a snapshot after checking
+// its hash.
+func FetchSnapshot(ctx context.Context, manifest SnapshotManifest) {
+	for _, entry := range manifest.Entries {
+		if verifySnapshotEntry(entry) {
+			continue
+		}
+
+		provider, ok := rir.FindProvider(entry.Provider)
+		if !ok {
+			fatalf("Unknown provider %q in manifest", entry.Provider)
+		}
+
+		log.Printf("Fetching %s serial %s", entry.Provider, entry.Serial)
+		// archives are compressed, the manifest pins their content
+		content := check1(provider.FetchArchive(ctx, entry.URL))
+		if sum := sha256Hex(content); sum != entry.Sha256 {
+			fatalf("Hash mismatch for %s: expected %s got %s", entry.URL, entry.Sha256, sum)
+		}
+		check(rir.Store.Put(provider.SnapshotKey(entry.Serial), content))
+	}
+}
+
+func pinSnapshot(manifestPath string) {
+	rir.PinnedSerials = make(map[string]string)
+	for _, entry := range readSnapshotManifest(manifestPath).Entries {
+		rir.PinnedSerials[entry.Provider] = entry.Serial
+	}
+}
+
+const snapshotUsage = `usage:
+	rir snapshot create [manifest.json]
+	rir snapshot verify manifest.json
+	rir snapshot fetch manifest.json`
+
+func snapshotCommand(ctx context.Context, args []string) {
+	if len(args) == 0 || len(args) > 2 || (args[0] != "create" && len(args) != 2) {
+		usageError(snapshotUsage)
+	}
+
+	switch args[0] {
+	case "create":
+		content := check1(json.MarshalIndent(CreateSnapshotManifest(ctx), "", "  "))
+		if len(args) == 2 {
+			content = append(content, '\n')
+			check(os.WriteFile(args[1], content, 0o644))
+			check(signArtifact(args[1], content))
+		} else {
+			fmt.Println(string(content))
+		}
+	case "verify":
+		failed := VerifySnapshot(readSnapshotManifest(args[1]))
+		for _, entry := range failed {
+			emit(SnapshotMismatch{entry.Provider, entry.Serial})
+		}
+		if len(failed) > 0 {
+			os.Exit(1)
+		}
+	case "fetch":
+		FetchSnapshot(ctx, readSnapshotManifest(args[1]))
+	default:
+		usageError(snapshotUsage)
+	}
+}
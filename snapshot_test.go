@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/monoidic/rir/rir"
+)
+
+// TestSnapshotStorage creates, pins and prunes snapshots in a cache directory
+// other than ~/.rir, which must be left alone.
+func TestSnapshotStorage(t *testing.T) {
+	home, dir := t.TempDir(), t.TempDir()
+	t.Setenv("HOME", home)
+	defer func(s rir.Storage) { rir.Store = s }(rir.Store)
+	rir.Store = rir.DirStorage{Dir: dir}
+
+	for _, p := range rir.AllProviders {
+		content := fmt.Sprintf("2|%[1]s|20240102|1|19700101|20240101|+0000\n%[1]s|FR|ipv4|2.0.0.0|256|20100712|allocated\n", p.Name())
+		if err := rir.Store.Put(p.LatestKey(), []byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	manifest := CreateSnapshotManifest(context.Background())
+	if len(manifest.Entries) != len(rir.AllProviders) {
+		t.Fatalf("got %d manifest entries", len(manifest.Entries))
+	}
+	if failed := VerifySnapshot(manifest); len(failed) != 0 {
+		t.Errorf("verify: got %v", failed)
+	}
+
+	defer func() { rir.PinnedSerials = nil }()
+	rir.PinnedSerials = map[string]string{"ripencc": "20240102"}
+	p, _ := rir.FindProvider("ripencc")
+	if want := filepath.Join(dir, "ripencc", "snapshot-20240102"); p.SourcePath() != want {
+		t.Errorf("source: got %s, want %s", p.SourcePath(), want)
+	}
+	data, err := p.GetData(context.Background())
+	if err != nil {
+		t.Fatalf("pinned data: %v", err)
+	}
+	data.Close()
+
+	old := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "ripencc", "snapshot-20240102"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	removed, err := rir.PruneCache(rir.Store, rir.PrunePolicy{MaxAge: time.Hour})
+	if err != nil || len(removed) != 1 || removed[0] != filepath.Join(dir, "ripencc", "snapshot-20240102") {
+		t.Errorf("prune: got %v, %v", removed, err)
+	}
+
+	if entries, err := os.ReadDir(home); err != nil || len(entries) != 0 {
+		t.Errorf("home: got %v, %v", entries, err)
+	}
+}
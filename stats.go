@@ -0,0 +1,315 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/monoidic/rir/rir"
+	"go4.org/netipx"
+)
+
+// HolderStats is the space delegated by a registry to one resource holder,
+// identified by the opaque ID of the extended statistics files.
+type HolderStats struct {
+	Registry  string   `json:"registry"`
+	OpaqueId  string   `json:"opaque_id"`
+	Countries []string `json:"countries"`
+	V4        int      `json:"v4"`
+	V6        *big.Int `json:"v6"`
+	Asns      int      `json:"asns"`
+}
+
+func (s HolderStats) String() string {
+	return tsvLine(s.Registry, s.OpaqueId, strings.Join(s.Countries, ","), s.V4, s.V6, s.Asns)
+}
+
+// holderStats sums the delegations of records by opaque ID.
+func holderStats(records rir.Records) []HolderStats {
+	holders := make(map[string]*HolderStats)
+	holder := func(r rir.Record) *HolderStats {
+		s, ok := holders[r.OpaqueId]
+		if !ok {
+			s = &HolderStats{Registry: records.Registry, OpaqueId: r.OpaqueId, V6: new(big.Int)}
+			holders[r.OpaqueId] = s
+		}
+		if r.Cc != "" && !slices.Contains(s.Countries, r.Cc) {
+			s.Countries = append(s.Countries, r.Cc)
+		}
+		return s
+	}
+	delegated := func(r rir.Record) bool {
+		return r.OpaqueId != "" && (r.Status == "allocated" || r.Status == "assigned")
+	}
+
+	for _, ip := range records.Ips {
+		if !delegated(ip.Record) {
+			continue
+		}
+		s := holder(ip.Record)
+		switch ip.Type {
+		case rir.IPv4:
+			s.V4 += ip.Value
+		case rir.IPv6:
+			s.V6.Add(s.V6, new(big.Int).Lsh(big.NewInt(1), uint(128-ip.Value)))
+		}
+	}
+	for _, asn := range records.Asns {
+		if delegated(asn.Record) {
+			holder(asn.Record).Asns += asn.Value
+		}
+	}
+
+	stats := make([]HolderStats, 0, len(holders))
+	for _, s := range holders {
+		slices.Sort(s.Countries)
+		stats = append(stats, *s)
+	}
+	// largest holders first
+	slices.SortFunc(stats, func(a, b HolderStats) int {
+		return cmp.Or(
+			cmp.Compare(b.V4, a.V4),
+			b.V6.Cmp(a.V6),
+			cmp.Compare(b.Asns, a.Asns),
+			strings.Compare(a.OpaqueId, b.OpaqueId),
+		)
+	})
+	return stats
+}
+
+// Allocation is a single delegation along with its number of addresses.
+type Allocation struct {
+	Registry  string   `json:"registry"`
+	Country   string   `json:"country"`
+	Type      string   `json:"type"`
+	Start     string   `json:"start"`
+	Addresses *big.Int `json:"addresses"`
+	Date      string   `json:"date"`
+	Status    string   `json:"status"`
+}
+
+func (a Allocation) String() string {
+	return tsvLine(a.Registry, a.Type, a.Start, a.Addresses, a.Date, a.Status)
+}
+
+func newAllocation(r rir.IpRecord) Allocation {
+	addresses := big.NewInt(int64(r.Value))
+	if r.Type == rir.IPv6 {
+		addresses = new(big.Int).Lsh(big.NewInt(1), uint(128-r.Value))
+	}
+	return Allocation{
+		Registry:  r.Registry,
+		Country:   r.Cc,
+		Type:      r.Type,
+		Start:     r.Start.String(),
+		Addresses: addresses,
+		Date:      r.Date,
+		Status:    r.Status,
+	}
+}
+
+// largestAllocations returns the n largest IPv4 then the n largest IPv6
+// delegations of a country. The families are ranked apart as any IPv6
+// delegation dwarfs every IPv4 one.
+func largestAllocations(ctx context.Context, country string, n int) []Allocation {
+	var v4, v6 []Allocation
+	for records := range retrieveData(ctx) {
+		for _, r := range records.Ips {
+			if r.Cc != country {
+				continue
+			}
+			switch r.Type {
+			case rir.IPv4:
+				v4 = append(v4, newAllocation(r))
+			case rir.IPv6:
+				v6 = append(v6, newAllocation(r))
+			}
+		}
+	}
+
+	var largest []Allocation
+	for _, family := range [][]Allocation{v4, v6} {
+		slices.SortStableFunc(family, func(a, b Allocation) int {
+			return cmp.Or(b.Addresses.Cmp(a.Addresses), strings.Compare(a.Date, b.Date))
+		})
+		largest = append(largest, family[:min(n, len(family))]...)
+	}
+	return largest
+}
+
+// DualStackHolder is the IPv4 and IPv6 space delegated to a resource holder
+// of a country, classified as v4-only, v6-only or dual-stack.
+type DualStackHolder struct {
+	Registry string   `json:"registry"`
+	OpaqueId string   `json:"opaque_id"`
+	V4       int      `json:"v4"`
+	V6       *big.Int `json:"v6"`
+	Class    string   `json:"class"`
+}
+
+func (h DualStackHolder) String() string {
+	return tsvLine(h.Registry, h.OpaqueId, h.V4, h.V6, h.Class)
+}
+
+// dualStackHolders pairs the IPv4 and IPv6 delegations of the holders of a
+// country by opaque ID. Holders with AS numbers only are left out.
+func dualStackHolders(ctx context.Context, country string) []DualStackHolder {
+	var holders []DualStackHolder
+	for records := range retrieveData(ctx) {
+		var ips []rir.IpRecord
+		for entry := range records.Filter(rir.Filter{Country: country}) {
+			if ip, ok := entry.(rir.IpRecord); ok {
+				ips = append(ips, ip)
+			}
+		}
+		records.Ips, records.Asns = ips, nil
+
+		for _, s := range holderStats(records) {
+			h := DualStackHolder{Registry: s.Registry, OpaqueId: s.OpaqueId, V4: s.V4, V6: s.V6}
+			switch {
+			case s.V4 > 0 && s.V6.Sign() > 0:
+				h.Class = "dual-stack"
+			case s.V4 > 0:
+				h.Class = "v4-only"
+			case s.V6.Sign() > 0:
+				h.Class = "v6-only"
+			default:
+				continue
+			}
+			holders = append(holders, h)
+		}
+	}
+	return holders
+}
+
+// FragmentationStats measures how scattered the space delegated by a
+// registry to a country is in one address family.
+type FragmentationStats struct {
+	Registry string `json:"registry"`
+	Country  string `json:"country"`
+	Family   string `json:"family"`
+	// Blocks is the number of CIDR blocks delegated and AveragePrefix their
+	// mean prefix length
+	Blocks        int     `json:"blocks"`
+	AveragePrefix float64 `json:"average_prefix"`
+	// Aggregated is the number of blocks once adjacent ones are merged, and
+	// Isolated the number of blocks no neighbour merges with
+	Aggregated int `json:"aggregated"`
+	Isolated   int `json:"isolated"`
+	// Potential is the share of blocks aggregation would save
+	Potential float64 `json:"aggregation_potential"`
+}
+
+func (s FragmentationStats) String() string {
+	return tsvLine(s.Registry, s.Country, s.Family, s.Blocks, fmt.Sprintf("%.1f", s.AveragePrefix), s.Aggregated, s.Isolated, fmt.Sprintf("%.1f%%", s.Potential*100))
+}
+
+// fragmentationStats computes the fragmentation of the space delegated to
+// every country of records, or to country alone when set.
+func fragmentationStats(records rir.Records, country string) []FragmentationStats {
+	type group struct{ country, family string }
+	blocks := make(map[group][]netip.Prefix)
+	for _, ip := range records.Ips {
+		if !isDelegated(ip.Record) || ip.Cc == "" || (country != "" && ip.Cc != country) {
+			continue
+		}
+		for prefix, err := range ip.Prefixes() {
+			if err == nil {
+				g := group{ip.Cc, ip.Type}
+				blocks[g] = append(blocks[g], prefix)
+			}
+		}
+	}
+
+	var stats []FragmentationStats
+	for g, prefixes := range blocks {
+		s := FragmentationStats{Registry: records.Registry, Country: g.country, Family: g.family, Blocks: len(prefixes)}
+		var b netipx.IPSetBuilder
+		bits := 0
+		delegated := make(map[netip.Prefix]bool, len(prefixes))
+		for _, prefix := range prefixes {
+			b.AddPrefix(prefix)
+			bits += prefix.Bits()
+			delegated[prefix] = true
+		}
+		s.AveragePrefix = float64(bits) / float64(len(prefixes))
+		aggregated := check1(b.IPSet()).Prefixes()
+		s.Aggregated = len(aggregated)
+		for _, prefix := range aggregated {
+			if delegated[prefix] {
+				s.Isolated++
+			}
+		}
+		s.Potential = 1 - float64(s.Aggregated)/float64(s.Blocks)
+		stats = append(stats, s)
+	}
+	slices.SortFunc(stats, func(a, b FragmentationStats) int {
+		return cmp.Or(strings.Compare(a.Country, b.Country), strings.Compare(a.Family, b.Family))
+	})
+	return stats
+}
+
+// statsCommand prints aggregate statistics of the registry files.
+func statsCommand(ctx context.Context, args []string) {
+	fset := flag.NewFlagSet("stats", flag.ExitOnError)
+	byHolder := fset.Bool("by-holder", false, "sum the delegated space of every opaque ID (resource holder) of each registry")
+	registry := fset.String("registry", "", "only include this registry")
+	top := fset.Int("top", 0, "only print this many holders per registry (0 for all)")
+	country := fset.String("country", "", "2 letters string of the country (ISO 3166) whose largest delegations to list")
+	largest := fset.Int("largest", 50, "number of delegations of each address family to list with -country")
+	dualStack := fset.Bool("dual-stack", false, "with -country, classify the holders of the country as v4-only, v6-only or dual-stack")
+	fragmentation := fset.Bool("fragmentation", false, "measure the fragmentation of the space delegated to each country, or to -country, by each registry")
+	check(fset.Parse(args))
+
+	if *fragmentation {
+		for records := range retrieveData(ctx) {
+			if *registry != "" && records.Registry != *registry {
+				continue
+			}
+			for _, s := range fragmentationStats(records, strings.ToUpper(*country)) {
+				emit(s)
+			}
+		}
+		return
+	}
+
+	if *country != "" && *dualStack {
+		classes := make(map[string]int)
+		for _, h := range dualStackHolders(ctx, strings.ToUpper(*country)) {
+			classes[h.Class]++
+			emit(h)
+		}
+		log.Printf("%d dual-stack, %d v4-only and %d v6-only holders", classes["dual-stack"], classes["v4-only"], classes["v6-only"])
+		return
+	}
+
+	if *country != "" {
+		for _, a := range largestAllocations(ctx, strings.ToUpper(*country), *largest) {
+			emit(a)
+		}
+		return
+	}
+
+	if !*byHolder {
+		usageError("usage: rir stats -by-holder [-registry name] [-top n] | -country CC [-largest n | -dual-stack] | -fragmentation [-registry name] [-country CC]")
+	}
+
+	for records := range retrieveData(ctx) {
+		if *registry != "" && records.Registry != *registry {
+			continue
+		}
+		stats := holderStats(records)
+		if *top > 0 && len(stats) > *top {
+			stats = stats[:*top]
+		}
+		for _, s := range stats {
+			emit(s)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/monoidic/rir/rir"
+)
+
+func TestFragmentationStats(t *testing.T) {
+	ip := func(start string, value int, cc string) rir.IpRecord {
+		return rir.IpRecord{Record: rir.Record{Registry: "ripencc", Cc: cc, Type: rir.IPv4, Value: value, Status: "allocated"}, Start: netip.MustParseAddr(start)}
+	}
+	records := rir.Records{Registry: "ripencc", Ips: []rir.IpRecord{
+		// two adjacent /24 merging into a /23, and an isolated /22
+		ip("192.0.2.0", 256, "FR"),
+		ip("192.0.3.0", 256, "FR"),
+		ip("198.51.100.0", 1024, "FR"),
+		ip("203.0.113.0", 256, "DE"),
+	}}
+
+	stats := fragmentationStats(records, "FR")
+	if len(stats) != 1 {
+		t.Fatalf("got %d groups %+v, want 1", len(stats), stats)
+	}
+	s := stats[0]
+	if s.Blocks != 3 || s.Aggregated != 2 || s.Isolated != 1 || s.AveragePrefix != 70.0/3 {
+		t.Errorf("got %+v", s)
+	}
+	if s.Potential < 0.33 || s.Potential > 0.34 {
+		t.Errorf("potential: got %v, want 1/3", s.Potential)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/netip"
+	"os"
+
+	"go4.org/netipx"
+)
+
+// specialRanges are the special-purpose ranges of the IANA registries
+// (RFC 6890 and updates), which must never be scanned even if a registry
+// file happened to cover them.
+var specialRanges = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // "this network"
+	netip.MustParsePrefix("10.0.0.0/8"),      // RFC 1918
+	netip.MustParsePrefix("100.64.0.0/10"),   // shared address space
+	netip.MustParsePrefix("127.0.0.0/8"),     // loopback
+	netip.MustParsePrefix("169.254.0.0/16"),  // link local
+	netip.MustParsePrefix("172.16.0.0/12"),   // RFC 1918
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+	netip.MustParsePrefix("192.0.2.0/24"),    // TEST-NET-1
+	netip.MustParsePrefix("192.88.99.0/24"),  // 6to4 relay anycast
+	netip.MustParsePrefix("192.168.0.0/16"),  // RFC 1918
+	netip.MustParsePrefix("198.18.0.0/15"),   // benchmarking
+	netip.MustParsePrefix("198.51.100.0/24"), // TEST-NET-2
+	netip.MustParsePrefix("203.0.113.0/24"),  // TEST-NET-3
+	netip.MustParsePrefix("224.0.0.0/4"),     // multicast
+	netip.MustParsePrefix("240.0.0.0/4"),     // reserved and broadcast
+	netip.MustParsePrefix("::/127"),          // unspecified and loopback
+	netip.MustParsePrefix("::ffff:0:0/96"),   // IPv4-mapped
+	netip.MustParsePrefix("64:ff9b::/96"),    // NAT64
+	netip.MustParsePrefix("100::/64"),        // discard only
+	netip.MustParsePrefix("2001::/23"),       // IETF protocol assignments
+	netip.MustParsePrefix("2001:db8::/32"),   // documentation
+	netip.MustParsePrefix("2002::/16"),       // 6to4
+	netip.MustParsePrefix("fc00::/7"),        // unique local addresses
+	netip.MustParsePrefix("fe80::/10"),       // link local
+	netip.MustParsePrefix("ff00::/8"),        // multicast
+}
+
+// exportZmap writes a zmap allowlist file, one IPv4 prefix per line. zmap
+// only scans IPv4, so IPv6 prefixes are left out.
+func exportZmap(w io.Writer, name string, prefixes []netip.Prefix) error {
+	if _, err := fmt.Fprintf(w, "# %s\n", name); err != nil {
+		return err
+	}
+	v4, _ := splitFamilies(prefixes)
+	return exportPlain(w, name, v4)
+}
+
+// exportMasscan writes a masscan include file, with adjacent prefixes merged
+// into address ranges.
+func exportMasscan(w io.Writer, name string, prefixes []netip.Prefix) error {
+	if _, err := fmt.Fprintf(w, "# %s\n", name); err != nil {
+		return err
+	}
+	for _, r := range prefixListSet(prefixes).Ranges() {
+		line := r.String()
+		if prefix, ok := r.Prefix(); ok {
+			line = prefix.String()
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// targetsCommand exports the space of countries as scanner target lists,
+// without the special-purpose ranges and the prefixes of an exclusion file.
+func targetsCommand(ctx context.Context, args []string) {
+	fset := flag.NewFlagSet("targets", flag.ExitOnError)
+	countries := fset.String("country", "", "comma separated 2 letters strings of the countries (ISO 3166)")
+	format := fset.String("format", "zmap", "target list format, zmap or masscan")
+	exclude := fset.String("exclude", "", "file of prefixes never to scan, one per line, on top of the special-purpose ranges")
+	name := fset.String("name", "targets", "name of the target list, written as a comment")
+	check(fset.Parse(args))
+
+	if *countries == "" || (*format != "zmap" && *format != "masscan") {
+		usageError("usage: rir targets -country CC[,CC...] [-format zmap|masscan] [-exclude file] [-name name]")
+	}
+
+	var b netipx.IPSetBuilder
+	b.AddSet(countrySet(ctx, countryList(*countries)...))
+	for _, prefix := range specialRanges {
+		b.RemovePrefix(prefix)
+	}
+	if *exclude != "" {
+		b.RemoveSet(prefixListSet(readPrefixList(*exclude)))
+	}
+
+	prefixes := subtractExcluded(check1(b.IPSet())).Prefixes()
+	if _, v6 := splitFamilies(prefixes); *format == "zmap" && len(v6) > 0 {
+		log.Printf("zmap only scans IPv4, leaving out %d IPv6 prefixes", len(v6))
+	}
+	writeExport(ctx, os.Stdout, *format, *name, prefixes)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"net/netip"
+	"testing"
+)
+
+func TestScannerExports(t *testing.T) {
+	prefixes := []netip.Prefix{
+		netip.MustParsePrefix("193.18.0.0/16"),
+		netip.MustParsePrefix("193.19.0.0/19"),
+		netip.MustParsePrefix("194.146.24.0/23"),
+		netip.MustParsePrefix("2001:600::/32"),
+	}
+	for _, test := range []struct {
+		format string
+		want   string
+	}{
+		{"zmap", "# scan\n193.18.0.0/16\n193.19.0.0/19\n194.146.24.0/23\n"},
+		{"masscan", "# scan\n193.18.0.0-193.19.31.255\n194.146.24.0/23\n2001:600::/32\n"},
+	} {
+		var b bytes.Buffer
+		if err := exporters[test.format](&b, "scan", prefixes); err != nil {
+			t.Fatal(err)
+		}
+		if got := b.String(); got != test.want {
+			t.Errorf("%s export: got %q, want %q", test.format, got, test.want)
+		}
+	}
+}
+
+func TestSpecialRanges(t *testing.T) {
+	for _, prefix := range specialRanges {
+		if prefix != prefix.Masked() {
+			t.Errorf("special range %s is not masked", prefix)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/monoidic/rir/rir"
+)
+
+// genTestdataCommand writes a synthetic registry file, for fixtures and load
+// tests of integrations.
+func genTestdataCommand(ctx context.Context, args []string) {
+	opts := rir.DefaultSynthOptions
+	fset := flag.NewFlagSet("gen-testdata", flag.ExitOnError)
+	fset.StringVar(&opts.Registry, "registry", opts.Registry, "registry name of the file")
+	fset.IntVar(&opts.Ipv4, "ipv4", 1000, "number of IPv4 records")
+	fset.IntVar(&opts.Ipv6, "ipv6", 200, "number of IPv6 records")
+	fset.IntVar(&opts.Asns, "asn", 300, "number of AS number records")
+	countries := fset.String("countries", strings.Join(opts.Countries, ","), "comma separated countries the records are spread over")
+	fset.Func("date", "serial date of the file, as 2006-01-02", dateFlag(&opts.Date))
+	fset.Uint64Var(&opts.Seed, "seed", 0, "seed of the generator, the same seed giving the same file")
+	output := fset.String("o", "", "file to write, instead of the standard output")
+	check(fset.Parse(args))
+
+	if fset.NArg() > 0 || opts.Ipv4 < 0 || opts.Ipv6 < 0 || opts.Asns < 0 || *countries == "" {
+		usageError("usage: rir gen-testdata [-registry name] [-ipv4 n] [-ipv6 n] [-asn n] [-countries CC,...] [-date 2006-01-02] [-seed n] [-o file]")
+	}
+	opts.Countries = strings.Split(strings.ToUpper(*countries), ",")
+
+	if *output == "" {
+		check(rir.WriteSyntheticFile(os.Stdout, opts))
+		return
+	}
+	f := check1(os.Create(*output))
+	check(rir.WriteSyntheticFile(f, opts))
+	check(f.Close())
+	log.Printf("Wrote %d records to %s", opts.Ipv4+opts.Ipv6+opts.Asns, *output)
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math/bits"
+	"net/netip"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/monoidic/rir/rir"
+	"go4.org/netipx"
+)
+
+// IPv4Transfer is an IPv4 block that changed country or registry, seen
+// between two snapshots or in a registry transfer log.
+type IPv4Transfer struct {
+	Source       string `json:"source"`
+	Start        string `json:"start"`
+	Addresses    int    `json:"addresses"`
+	FromCountry  string `json:"from_country"`
+	FromRegistry string `json:"from_registry"`
+	ToCountry    string `json:"to_country"`
+	ToRegistry   string `json:"to_registry"`
+	Date         string `json:"date,omitempty"`
+}
+
+func (t IPv4Transfer) String() string {
+	return tsvLine(t.Source, t.Start, t.Addresses, t.FromCountry, t.FromRegistry, t.ToCountry, t.ToRegistry, orDash(t.Date))
+}
+
+// sizeClass is the prefix length of the largest CIDR block no bigger than
+// the transfer, e.g. "/23" for 768 addresses.
+func (t IPv4Transfer) sizeClass() string {
+	return fmt.Sprintf("/%d", 33-bits.Len(uint(t.Addresses)))
+}
+
+// TransferAggregate sums the transfers of a size class or country pair.
+type TransferAggregate struct {
+	Kind      string `json:"kind"`
+	Key       string `json:"key"`
+	Transfers int    `json:"transfers"`
+	Addresses int    `json:"addresses"`
+}
+
+func (a TransferAggregate) String() string {
+	return tsvLine(a.Kind, a.Key, a.Transfers, a.Addresses)
+}
+
+// aggregateTransfers sums transfers by size class, largest blocks first,
+// then by country pair, most addresses first.
+func aggregateTransfers(transfers []IPv4Transfer) []TransferAggregate {
+	sizes := make(map[string]*TransferAggregate)
+	pairs := make(map[string]*TransferAggregate)
+	add := func(m map[string]*TransferAggregate, kind, key string, t IPv4Transfer) {
+		a, ok := m[key]
+		if !ok {
+			a = &TransferAggregate{Kind: kind, Key: key}
+			m[key] = a
+		}
+		a.Transfers++
+		a.Addresses += t.Addresses
+	}
+	for _, t := range transfers {
+		add(sizes, "size", t.sizeClass(), t)
+		add(pairs, "pair", orDash(t.FromCountry)+">"+orDash(t.ToCountry), t)
+	}
+
+	var bySize, byPair []TransferAggregate
+	for _, a := range sizes {
+		bySize = append(bySize, *a)
+	}
+	for _, a := range pairs {
+		byPair = append(byPair, *a)
+	}
+	slices.SortFunc(bySize, func(a, b TransferAggregate) int {
+		return cmp.Compare(check1(strconv.Atoi(a.Key[1:])), check1(strconv.Atoi(b.Key[1:])))
+	})
+	slices.SortFunc(byPair, func(a, b TransferAggregate) int {
+		return cmp.Or(cmp.Compare(b.Addresses, a.Addresses), strings.Compare(a.Key, b.Key))
+	})
+	return append(bySize, byPair...)
+}
+
+// fileDate is the date of a retained file: its end date, its serial when it
+// is a date, or when it was written.
+func fileDate(f snapshotFile) time.Time {
+	if !f.Version.EndTime.IsZero() {
+		return f.Version.EndTime
+	}
+	if t, err := time.Parse("20060102", f.Version.Serial); err == nil {
+		return t
+	}
+	if info, err := rir.Store.Stat(f.Key); err == nil {
+		return info.ModTime()
+	}
+	return time.Time{}
+}
+
+// periodFiles returns the retained files of a provider closest to the start
+// and end of a period: the last ones dated no later than since and until, or
+// the oldest and latest files.
+func periodFiles(files []snapshotFile, since, until time.Time) (start, end snapshotFile, ok bool) {
+	first, last := 0, len(files)-1
+	if !until.IsZero() {
+		last = -1
+	}
+	for i, f := range files {
+		if !fileDate(f).After(since) {
+			first = i
+		}
+		if !until.IsZero() && !fileDate(f).After(until) {
+			last = i
+		}
+	}
+	if last <= first {
+		return snapshotFile{}, snapshotFile{}, false
+	}
+	return files[first], files[last], true
+}
+
+type ipv4Block struct {
+	start     netip.Addr
+	addresses int
+}
+
+type ipv4Holder struct {
+	country, registry string
+}
+
+// delegatedIPv4 maps the delegated IPv4 blocks of records to their holder.
+func delegatedIPv4(all []rir.Records) map[ipv4Block]ipv4Holder {
+	blocks := make(map[ipv4Block]ipv4Holder)
+	for _, records := range all {
+		for _, ip := range records.Ips {
+			if ip.Type == rir.IPv4 && isDelegated(ip.Record) {
+				blocks[ipv4Block{ip.Start, ip.Value}] = ipv4Holder{ip.Cc, ip.Registry}
+			}
+		}
+	}
+	return blocks
+}
+
+// snapshotTransfers compares the IPv4 blocks of every registry at the start
+// and end of a period, inter-registry transfers included.
+func snapshotTransfers(before, after []rir.Records) []IPv4Transfer {
+	start, end := delegatedIPv4(before), delegatedIPv4(after)
+	var transfers []IPv4Transfer
+	for block, to := range end {
+		from, ok := start[block]
+		if !ok || from == to {
+			continue
+		}
+		transfers = append(transfers, IPv4Transfer{
+			Source:       "snapshot",
+			Start:        block.start.String(),
+			Addresses:    block.addresses,
+			FromCountry:  from.country,
+			FromRegistry: from.registry,
+			ToCountry:    to.country,
+			ToRegistry:   to.registry,
+		})
+	}
+	return transfers
+}
+
+// transferLog is the JSON transfer log format published by the registries.
+type transferLog struct {
+	Transfers []struct {
+		Ip4nets *struct {
+			TransferSet []struct {
+				StartAddress string `json:"start_address"`
+				EndAddress   string `json:"end_address"`
+			} `json:"transfer_set"`
+		} `json:"ip4nets"`
+		SourceOrganization struct {
+			CountryCode string `json:"country_code"`
+		} `json:"source_organization"`
+		RecipientOrganization struct {
+			CountryCode string `json:"country_code"`
+		} `json:"recipient_organization"`
+		SourceRir    string `json:"source_rir"`
+		RecipientRir string `json:"recipient_rir"`
+		TransferDate string `json:"transfer_date"`
+	} `json:"transfers"`
+}
+
+// registryName turns the registry names of transfer logs, e.g. "RIPE NCC",
+// into provider names.
+func registryName(rir string) string {
+	return strings.ToLower(strings.ReplaceAll(rir, " ", ""))
+}
+
+// logTransfers reads the IPv4 transfers of a registry transfer log dated in
+// the period.
+func logTransfers(path string, since, until time.Time) []IPv4Transfer {
+	var tlog transferLog
+	check(json.Unmarshal(check1(os.ReadFile(path)), &tlog))
+
+	var transfers []IPv4Transfer
+	for _, t := range tlog.Transfers {
+		if t.Ip4nets == nil {
+			continue
+		}
+		date, err := time.Parse(time.RFC3339, t.TransferDate)
+		if err != nil {
+			date, err = time.Parse("2006-01-02", t.TransferDate)
+		}
+		if err != nil || date.Before(since) || (!until.IsZero() && date.After(until)) {
+			continue
+		}
+		for _, net := range t.Ip4nets.TransferSet {
+			start, err1 := netip.ParseAddr(net.StartAddress)
+			end, err2 := netip.ParseAddr(net.EndAddress)
+			if err1 != nil || err2 != nil || !start.Is4() || end.Less(start) {
+				log.Printf("%s: invalid range %s-%s", path, net.StartAddress, net.EndAddress)
+				continue
+			}
+			size := 0
+			for _, prefix := range netipx.IPRangeFrom(start, end).Prefixes() {
+				size += 1 << (32 - prefix.Bits())
+			}
+			transfers = append(transfers, IPv4Transfer{
+				Source:       "log",
+				Start:        start.String(),
+				Addresses:    size,
+				FromCountry:  t.SourceOrganization.CountryCode,
+				FromRegistry: registryName(t.SourceRir),
+				ToCountry:    t.RecipientOrganization.CountryCode,
+				ToRegistry:   registryName(t.RecipientRir),
+				Date:         date.Format("2006-01-02"),
+			})
+		}
+	}
+	return transfers
+}
+
+// transfersCommand reports the IPv4 blocks that changed country or registry
+// in a period, from the retained snapshots and registry transfer logs.
+func transfersCommand(ctx context.Context, args []string) {
+	fset := flag.NewFlagSet("transfers", flag.ExitOnError)
+	sinceFlag := fset.String("since", "", "start of the period, as 2006-01-02")
+	untilFlag := fset.String("until", "", "end of the period, as 2006-01-02 (default now)")
+	list := fset.Bool("list", false, "list every transfer instead of the aggregates")
+	var logs []string
+	fset.Func("log", "registry transfer log in JSON, may be repeated", func(path string) error {
+		logs = append(logs, path)
+		return nil
+	})
+	check(fset.Parse(args))
+
+	since, err := time.Parse("2006-01-02", *sinceFlag)
+	if err != nil || fset.NArg() != 0 {
+		usageError("usage: rir transfers -since date [-until date] [-log transfers.json...] [-list]")
+	}
+	var until time.Time
+	if *untilFlag != "" {
+		until = check1(time.Parse("2006-01-02", *untilFlag))
+	}
+
+	var before, after []rir.Records
+	for _, p := range rir.AllProviders {
+		start, end, ok := periodFiles(historyFiles(p), since, until)
+		if !ok {
+			log.Printf("No %s snapshots in the period, see -keep", p.Name())
+			continue
+		}
+		before = append(before, start.records())
+		after = append(after, end.records())
+	}
+	transfers := snapshotTransfers(before, after)
+	for _, path := range logs {
+		transfers = append(transfers, logTransfers(path, since, until)...)
+	}
+
+	if *list {
+		slices.SortFunc(transfers, func(a, b IPv4Transfer) int {
+			return cmp.Or(
+				netip.MustParseAddr(a.Start).Compare(netip.MustParseAddr(b.Start)),
+				strings.Compare(a.Source, b.Source),
+			)
+		})
+		for _, t := range transfers {
+			emit(t)
+		}
+		return
+	}
+	for _, a := range aggregateTransfers(transfers) {
+		emit(a)
+	}
+}
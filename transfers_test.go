@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"net/netip"
+	"testing"
+
+	"github.com/monoidic/rir/rir"
+)
+
+func TestSnapshotTransfers(t *testing.T) {
+	ip := func(registry, start string, value int, cc string) rir.IpRecord {
+		return rir.IpRecord{Record: rir.Record{Registry: registry, Cc: cc, Type: rir.IPv4, Value: value, Status: "allocated"}, Start: netip.MustParseAddr(start)}
+	}
+	before := []rir.Records{
+		{Registry: "arin", Ips: []rir.IpRecord{ip("arin", "192.0.2.0", 256, "US"), ip("arin", "198.51.100.0", 768, "US")}},
+		{Registry: "ripencc", Ips: []rir.IpRecord{ip("ripencc", "203.0.113.0", 256, "FR")}},
+	}
+	after := []rir.Records{
+		{Registry: "arin", Ips: []rir.IpRecord{ip("arin", "192.0.2.0", 256, "US")}},
+		{Registry: "ripencc", Ips: []rir.IpRecord{ip("ripencc", "198.51.100.0", 768, "NL"), ip("ripencc", "203.0.113.0", 256, "DE")}},
+	}
+
+	transfers := snapshotTransfers(before, after)
+	if len(transfers) != 2 {
+		t.Fatalf("got %d transfers %+v, want 2", len(transfers), transfers)
+	}
+	want := map[string]string{
+		"size /23":   "1 768",
+		"size /24":   "1 256",
+		"pair US>NL": "1 768",
+		"pair FR>DE": "1 256",
+	}
+	aggregates := aggregateTransfers(transfers)
+	if len(aggregates) != len(want) {
+		t.Errorf("got %d aggregates %+v, want %d", len(aggregates), aggregates, len(want))
+	}
+	for _, a := range aggregates {
+		if got := fmt.Sprintf("%d %d", a.Transfers, a.Addresses); want[a.Kind+" "+a.Key] != got {
+			t.Errorf("%s %s: got %s, want %s", a.Kind, a.Key, got, want[a.Kind+" "+a.Key])
+		}
+	}
+	if aggregates[0].Key != "/23" || aggregates[2].Key != "US>NL" {
+		t.Errorf("order: got %+v", aggregates)
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"net/netip"
+)
+
+var (
+	sixToFourPrefix = netip.MustParsePrefix("2002::/16")
+	teredoPrefix    = netip.MustParsePrefix("2001::/32")
+	nat64Prefix     = netip.MustParsePrefix("64:ff9b::/96")
+)
+
+// embeddedIPv4 extracts the IPv4 address carried by an IPv6 transition
+// mechanism address and names the mechanism. Looking up the IPv6 address
+// itself would only find the delegation of the mechanism's prefix.
+func embeddedIPv4(addr netip.Addr) (netip.Addr, string, bool) {
+	b := addr.As16()
+
+	switch {
+	case addr.Is4In6():
+		return addr.Unmap(), "ipv4-mapped", true
+	case sixToFourPrefix.Contains(addr):
+		return netip.AddrFrom4([4]byte(b[2:6])), "6to4", true
+	case teredoPrefix.Contains(addr):
+		// the client address is stored with all its bits inverted
+		return netip.AddrFrom4([4]byte{^b[12], ^b[13], ^b[14], ^b[15]}), "teredo", true
+	case nat64Prefix.Contains(addr):
+		return netip.AddrFrom4([4]byte(b[12:16])), "nat64", true
+	}
+
+	return addr, "", false
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestEmbeddedIPv4(t *testing.T) {
+	tests := []struct {
+		addr, expected, mechanism string
+	}{
+		{"::ffff:194.146.24.104", "194.146.24.104", "ipv4-mapped"},
+		{"2002:c292:1868::1", "194.146.24.104", "6to4"},
+		{"2001:0:4136:e378:8000:63bf:3d6d:e797", "194.146.24.104", "teredo"},
+		{"64:ff9b::c292:1868", "194.146.24.104", "nat64"},
+	}
+
+	for _, test := range tests {
+		addr, mechanism, ok := embeddedIPv4(netip.MustParseAddr(test.addr))
+		if !ok || addr.String() != test.expected || mechanism != test.mechanism {
+			t.Errorf("%s: expected %s via %s got %s via %q", test.addr, test.expected, test.mechanism, addr, mechanism)
+		}
+	}
+
+	for _, plain := range []string{"194.146.24.104", "2001:db8::1"} {
+		if _, _, ok := embeddedIPv4(netip.MustParseAddr(plain)); ok {
+			t.Errorf("%s: expected no embedded address", plain)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+)
+
+// whoamiTimeout bounds the discovery of each public address.
+const whoamiTimeout = 10 * time.Second
+
+// echoAddress asks an HTTPS echo service, which answers with the address of
+// the caller as plain text, for the public address of the machine in an
+// address family, "4" or "6".
+func echoAddress(ctx context.Context, endpoint, family string) (netip.Addr, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	var d net.Dialer
+	transport.DialContext = func(ctx context.Context, _, addr string) (net.Conn, error) {
+		return d.DialContext(ctx, "tcp"+family, addr)
+	}
+	client := &http.Client{Transport: transport, Timeout: whoamiTimeout}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return netip.Addr{}, fmt.Errorf("%s returned %s", endpoint, resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 256))
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	addr, err := netip.ParseAddr(strings.TrimSpace(string(body)))
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("%s answered %q, not an address", endpoint, body)
+	}
+	return addr.Unmap(), nil
+}
+
+// STUN (RFC 5389) binding requests and responses.
+const (
+	stunBindingRequest   = 0x0001
+	stunBindingResponse  = 0x0101
+	stunMagicCookie      = 0x2112a442
+	stunMappedAddress    = 0x0001
+	stunXorMappedAddress = 0x0020
+)
+
+var errStunResponse = errors.New("invalid STUN response")
+
+// stunAddress asks a STUN server for the public address of the machine in an
+// address family, "4" or "6".
+func stunAddress(ctx context.Context, server, family string) (netip.Addr, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp"+family, server)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	defer conn.Close()
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(whoamiTimeout)
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		return netip.Addr{}, err
+	}
+
+	req := make([]byte, 20)
+	binary.BigEndian.PutUint16(req, stunBindingRequest)
+	binary.BigEndian.PutUint32(req[4:], stunMagicCookie)
+	rand.Read(req[8:])
+	if _, err := conn.Write(req); err != nil {
+		return netip.Addr{}, err
+	}
+	resp := make([]byte, 1500)
+	n, err := conn.Read(resp)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	return parseStunResponse(resp[:n], req[8:])
+}
+
+// parseStunResponse returns the mapped address of a binding response to the
+// request of transaction ID id.
+func parseStunResponse(resp, id []byte) (netip.Addr, error) {
+	if len(resp) < 20 || binary.BigEndian.Uint16(resp) != stunBindingResponse ||
+		binary.BigEndian.Uint32(resp[4:]) != stunMagicCookie || string(resp[8:20]) != string(id) {
+		return netip.Addr{}, errStunResponse
+	}
+	attrs := resp[20:]
+	if length := int(binary.BigEndian.Uint16(resp[2:])); length <= len(attrs) {
+		attrs = attrs[:length]
+	}
+
+	var mapped netip.Addr
+	for len(attrs) >= 4 {
+		kind, length := binary.BigEndian.Uint16(attrs), int(binary.BigEndian.Uint16(attrs[2:]))
+		if len(attrs) < 4+length {
+			return netip.Addr{}, errStunResponse
+		}
+		value := attrs[4 : 4+length]
+		attrs = attrs[min(4+(length+3)/4*4, len(attrs)):]
+
+		if (kind != stunXorMappedAddress && kind != stunMappedAddress) || len(value) < 8 {
+			continue
+		}
+		ip := append([]byte(nil), value[4:]...)
+		if kind == stunXorMappedAddress {
+			// the address is xored with the magic cookie and the
+			// transaction ID, which follow it in the header
+			for i := range ip {
+				ip[i] ^= resp[4+i]
+			}
+		}
+		addr, ok := netip.AddrFromSlice(ip)
+		if !ok || (value[1] == 1) != addr.Is4() {
+			return netip.Addr{}, errStunResponse
+		}
+		if kind == stunXorMappedAddress {
+			return addr, nil
+		}
+		mapped = addr
+	}
+	if !mapped.IsValid() {
+		return netip.Addr{}, errStunResponse
+	}
+	return mapped, nil
+}
+
+// whoamiCommand looks up the public IPv4 and IPv6 addresses of the machine,
+// as seen by an echo service or a STUN server.
+func whoamiCommand(ctx context.Context, args []string) {
+	fset := flag.NewFlagSet("whoami", flag.ExitOnError)
+	endpoint := fset.String("endpoint", "https://icanhazip.com", "HTTPS service answering with the address of the caller as plain text")
+	stun := fset.String("stun", "", "STUN server to ask instead of -endpoint, as host:port (e.g. stun.l.google.com:19302)")
+	check(fset.Parse(args))
+
+	if fset.NArg() > 0 {
+		usageError("usage: rir whoami [-endpoint url | -stun host:port]")
+	}
+
+	var addrs []netip.Addr
+	for _, family := range []string{"4", "6"} {
+		ctx, cancel := context.WithTimeout(ctx, whoamiTimeout)
+		var addr netip.Addr
+		var err error
+		if *stun != "" {
+			addr, err = stunAddress(ctx, *stun, family)
+		} else {
+			addr, err = echoAddress(ctx, *endpoint, family)
+		}
+		cancel()
+		if err != nil {
+			log.Printf("No public IPv%s address: %v", family, err)
+			continue
+		}
+		addrs = append(addrs, addr)
+	}
+	if len(addrs) == 0 {
+		fatalf("Cannot discover any public address")
+	}
+
+	q := Query{addrs: addrs}
+	if len(addrs) > 1 {
+		q.regions = slices.Collect(retrieveData(ctx))
+	}
+	for _, addr := range addrs {
+		for r := range q.lookup(ctx, addr) {
+			emit(annotate(annotate(r, "registry", r.record.Registry), "address", addr.String()))
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestEchoAddress(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "192.0.2.7")
+	}))
+	defer server.Close()
+
+	addr, err := echoAddress(context.Background(), server.URL, "4")
+	if err != nil || addr != netip.MustParseAddr("192.0.2.7") {
+		t.Errorf("echoAddress: got %s, %v", addr, err)
+	}
+}
+
+// stunResponse builds a binding response to request mapping addr.
+func stunResponse(request []byte, kind uint16, addr netip.Addr) []byte {
+	ip := addr.AsSlice()
+	family := byte(1)
+	if addr.Is6() {
+		family = 2
+	}
+	value := append([]byte{0, family, 0x12, 0x34}, ip...)
+	resp := binary.BigEndian.AppendUint16(nil, stunBindingResponse)
+	resp = binary.BigEndian.AppendUint16(resp, uint16(4+len(value)))
+	resp = append(resp, request[4:20]...)
+	resp = binary.BigEndian.AppendUint16(resp, kind)
+	resp = binary.BigEndian.AppendUint16(resp, uint16(len(value)))
+	resp = append(resp, value...)
+	if kind == stunXorMappedAddress {
+		for i := range ip {
+			resp[24+4+i] ^= resp[4+i]
+		}
+	}
+	return resp
+}
+
+func TestStunAddress(t *testing.T) {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	go func() {
+		request := make([]byte, 1500)
+		n, from, err := conn.ReadFrom(request)
+		if err != nil || n != 20 {
+			return
+		}
+		conn.WriteTo(stunResponse(request, stunXorMappedAddress, netip.MustParseAddr("198.51.100.1")), from)
+	}()
+
+	addr, err := stunAddress(context.Background(), conn.LocalAddr().String(), "4")
+	if err != nil || addr != netip.MustParseAddr("198.51.100.1") {
+		t.Errorf("stunAddress: got %s, %v", addr, err)
+	}
+
+	request := make([]byte, 20)
+	binary.BigEndian.PutUint32(request[4:], stunMagicCookie)
+	copy(request[8:], "transaction!")
+	for _, test := range []struct {
+		kind uint16
+		addr string
+	}{
+		{stunXorMappedAddress, "2001:db8::1"},
+		{stunMappedAddress, "203.0.113.9"},
+	} {
+		want := netip.MustParseAddr(test.addr)
+		got, err := parseStunResponse(stunResponse(request, test.kind, want), request[8:])
+		if err != nil || got != want {
+			t.Errorf("parseStunResponse(%#x %s): got %s, %v", test.kind, want, got, err)
+		}
+	}
+	if _, err := parseStunResponse(stunResponse(request, stunMappedAddress, netip.MustParseAddr("203.0.113.9")), make([]byte, 12)); err == nil {
+		t.Error("parseStunResponse: accepted the response to another transaction")
+	}
+}
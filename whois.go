@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/monoidic/rir/rir"
+	"go4.org/netipx"
+)
+
+// whoisText renders a lookup result as a whois style block of key: value
+// lines, annotations included. Other results are printed as in text output.
+func whoisText(v any) string {
+	var b strings.Builder
+	field := func(key string, value any) {
+		fmt.Fprintf(&b, "%-16s%v\n", key+":", value)
+	}
+
+	switch v := v.(type) {
+	case Annotated:
+		text := whoisText(v.Result)
+		if !strings.HasSuffix(text, "\n") {
+			return fmt.Sprint(v)
+		}
+		b.WriteString(text)
+		for _, annotation := range v.Annotations {
+			// skip fields of the block repeated by -explain
+			if strings.Contains(text, fmt.Sprintf("%-16s%v\n", annotation.Key+":", annotation.Value)) {
+				continue
+			}
+			field(annotation.Key, annotation.Value)
+		}
+	case CountryPrefix:
+		r := v.record
+		if r.Type == rir.IPv6 {
+			field("inet6num", v.Prefix)
+		} else {
+			rng := recordRange(r)
+			field("inetnum", fmt.Sprintf("%s - %s", rng.From(), rng.To()))
+		}
+		field("country", v.Country)
+		if v.CountryName != "" {
+			field("country-name", v.CountryName)
+		}
+		field("status", r.Status)
+		field("registry", r.Registry)
+		if r.OpaqueId != "" {
+			field("opaque-id", r.OpaqueId)
+		}
+		if !r.Time.IsZero() {
+			field("last-modified", r.Time.Format("2006-01-02"))
+		}
+	default:
+		return fmt.Sprint(v)
+	}
+	return b.String()
+}
+
+// recordRange is the range of addresses of an ip record, which unlike its
+// prefixes is what whois shows for IPv4.
+func recordRange(r rir.IpRecord) netipx.IPRange {
+	var first, last netipx.IPRange
+	for prefix := range r.Net() {
+		if !first.IsValid() {
+			first = netipx.RangeOfPrefix(prefix)
+		}
+		last = netipx.RangeOfPrefix(prefix)
+	}
+	return netipx.IPRangeFrom(first.From(), last.To())
+}
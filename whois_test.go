@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/monoidic/rir/rir"
+)
+
+func TestWhoisText(t *testing.T) {
+	records, err := rir.NewReader(strings.NewReader(holderData)).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := Query{regions: []rir.Records{records}}
+
+	var got []string
+	for cp := range q.matchOnIp(context.Background(), netip.MustParseAddr("194.146.25.1")) {
+		got = append(got, whoisText(annotate(cp, "ptr", "host.example")))
+	}
+	want := `inetnum:        194.146.24.0 - 194.146.25.255
+country:        FR
+status:         assigned
+registry:       ripencc
+opaque-id:      b8f0a8c3
+last-modified:  2005-01-01
+ptr:            host.example
+`
+	if len(got) != 1 || got[0] != want {
+		t.Errorf("whoisText: got %q, want %q", got, want)
+	}
+
+	if got := whoisText(netip.MustParsePrefix("2.0.0.0/12")); got != "2.0.0.0/12" {
+		t.Errorf("whoisText(prefix): got %q", got)
+	}
+}
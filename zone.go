@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+
+	"go4.org/netipx"
+)
+
+// A zoneEntry maps the addresses of a prefix to a country.
+type zoneEntry struct {
+	prefix  netip.Prefix
+	country string
+}
+
+// zoneEntries splits prefixes along the delegations of the table, dropping
+// unallocated space. Nested delegations take precedence over the ones
+// containing them, as in lookups.
+func zoneEntries(table *prefixTable[delegation], prefixes []netip.Prefix) []zoneEntry {
+	var b netipx.IPSetBuilder
+	for _, prefix := range prefixes {
+		b.AddPrefix(prefix.Masked())
+	}
+	remaining := check1(b.IPSet())
+
+	var overlapping []delegation
+	for _, d := range table.entries {
+		if remaining.OverlapsPrefix(d.Prefix) {
+			overlapping = append(overlapping, d)
+		}
+	}
+	// most specific first
+	slices.SortFunc(overlapping, func(a, b delegation) int {
+		return cmp.Compare(b.Prefix.Bits(), a.Prefix.Bits())
+	})
+
+	var entries []zoneEntry
+	for _, d := range overlapping {
+		var delegated, in, out netipx.IPSetBuilder
+		delegated.AddPrefix(d.Prefix)
+		in.AddSet(remaining)
+		in.Intersect(check1(delegated.IPSet()))
+		out.AddSet(remaining)
+		out.RemovePrefix(d.Prefix)
+		if d.Record.Status != "available" && d.Record.Cc != "" {
+			for _, prefix := range check1(in.IPSet()).Prefixes() {
+				entries = append(entries, zoneEntry{prefix, d.Record.Cc})
+			}
+		}
+		remaining = check1(out.IPSet())
+	}
+
+	slices.SortFunc(entries, func(a, b zoneEntry) int {
+		return a.prefix.Addr().Compare(b.prefix.Addr())
+	})
+	return entries
+}
+
+// A zoneName is the reversed address of a zone entry, in octets for IPv4 and
+// nibbles for IPv6, as in reverse DNS. Entries not ending on a label boundary
+// are covered by a wildcard.
+type zoneName struct {
+	labels   string
+	wildcard bool
+}
+
+// zoneNames returns the names covering every address of prefix.
+func zoneNames(prefix netip.Prefix) []zoneName {
+	labelBits := 4
+	if prefix.Addr().Is4() {
+		labelBits = 8
+	}
+
+	// round the prefix length up to a label boundary
+	bits := (prefix.Bits() + labelBits - 1) / labelBits * labelBits
+	var names []zoneName
+	for sub := netip.PrefixFrom(prefix.Addr(), bits); prefix.Contains(sub.Addr()); {
+		names = append(names, zoneName{
+			labels:   reversedLabels(sub.Addr(), bits/labelBits),
+			wildcard: bits < prefix.Addr().BitLen(),
+		})
+		next := netipx.PrefixLastIP(sub).Next()
+		if !next.IsValid() {
+			break
+		}
+		sub = netip.PrefixFrom(next, bits)
+	}
+	return names
+}
+
+// reversedLabels returns the first n octets or nibbles of addr, reversed.
+func reversedLabels(addr netip.Addr, n int) string {
+	var labels []string
+	b := addr.AsSlice()
+	if addr.Is4() {
+		for _, octet := range b[:n] {
+			labels = append(labels, fmt.Sprint(octet))
+		}
+	} else {
+		for i := range n {
+			nibble := b[i/2] >> 4
+			if i%2 == 1 {
+				nibble = b[i/2] & 0xf
+			}
+			labels = append(labels, fmt.Sprintf("%x", nibble))
+		}
+	}
+	for i, j := 0, len(labels)-1; i < j; i, j = i+1, j-1 {
+		labels[i], labels[j] = labels[j], labels[i]
+	}
+	return strings.Join(labels, ".")
+}
+
+// writeZone writes a zone file fragment of TXT records.
+func writeZone(w io.Writer, origin string, ttl int, entries []zoneEntry) error {
+	if _, err := fmt.Fprintf(w, "$ORIGIN %s.\n$TTL %d\n", origin, ttl); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		for _, name := range zoneNames(entry.prefix) {
+			owner := name.labels
+			if name.wildcard {
+				owner = "*." + owner
+			}
+			if _, err := fmt.Fprintf(w, "%s\tIN\tTXT\t\"%s\"\n", owner, entry.country); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeUnbound writes unbound local-data. Unbound has no wildcards, so ranges
+// are served by redirect zones answering every name below them.
+func writeUnbound(w io.Writer, origin string, ttl int, entries []zoneEntry) error {
+	for _, entry := range entries {
+		for _, name := range zoneNames(entry.prefix) {
+			fqdn := name.labels + "." + origin + "."
+			if name.wildcard {
+				if _, err := fmt.Fprintf(w, "local-zone: \"%s\" redirect\n", fqdn); err != nil {
+					return err
+				}
+			}
+			if _, err := fmt.Fprintf(w, "local-data: '%s %d IN TXT \"%s\"'\n", fqdn, ttl, entry.country); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// zoneCommand generates DNS data mapping the addresses of a prefix list to
+// their country.
+func zoneCommand(ctx context.Context, args []string) {
+	fset := flag.NewFlagSet("zone", flag.ExitOnError)
+	origin := fset.String("origin", "", "domain under which the reversed addresses are published, e.g. cc.example")
+	format := fset.String("format", "zone", "output format, zone or unbound")
+	ttl := fset.Int("ttl", 3600, "TTL of the records")
+	check(fset.Parse(args))
+
+	writers := map[string]func(io.Writer, string, int, []zoneEntry) error{
+		"zone":    writeZone,
+		"unbound": writeUnbound,
+	}
+	write, ok := writers[*format]
+	if *origin == "" || !ok || fset.NArg() != 1 {
+		usageError("usage: rir zone -origin domain [-format zone|unbound] [-ttl seconds] prefixes.txt (- for stdin)")
+	}
+
+	prefixes := readPrefixList(fset.Arg(0))
+	entries := zoneEntries(loadPrefixTable(ctx), prefixes)
+	var b bytes.Buffer
+	b.Write(artifactHeader(*format, loaded()))
+	check(write(&b, strings.TrimSuffix(*origin, "."), *ttl, entries))
+	check1(os.Stdout.Write(b.Bytes()))
+	exportWritten(*format, *origin, "", len(entries))
+	runExportHook(ctx, *format, *origin, false, len(entries), b.Bytes())
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/netip"
+	"slices"
+	"testing"
+)
+
+func TestZoneNames(t *testing.T) {
+	for prefix, want := range map[string][]zoneName{
+		"192.0.2.0/24":    {{"2.0.192", true}},
+		"192.0.2.7/32":    {{"7.2.0.192", false}},
+		"10.0.0.0/7":      {{"10", true}, {"11", true}},
+		"198.51.100.0/23": {{"100.51.198", true}, {"101.51.198", true}},
+		"2001:db8::/31":   {{"8.b.d.0.1.0.0.2", true}, {"9.b.d.0.1.0.0.2", true}},
+	} {
+		got := zoneNames(netip.MustParsePrefix(prefix))
+		if !slices.Equal(got, want) {
+			t.Errorf("zoneNames(%s) = %v, want %v", prefix, got, want)
+		}
+	}
+}
+
+func TestZoneEntries(t *testing.T) {
+	table := newPrefixTable[delegation]()
+	for _, d := range []struct {
+		prefix, cc, status string
+	}{
+		{"10.0.0.0/8", "FR", "allocated"},
+		{"10.1.0.0/16", "DE", "assigned"},
+		{"10.2.0.0/16", "", "available"},
+	} {
+		prefix := netip.MustParsePrefix(d.prefix)
+		var r delegation
+		r.Prefix = prefix
+		r.Record.Cc = d.cc
+		r.Record.Status = d.status
+		table.add(prefix, r)
+	}
+
+	got := zoneEntries(table, []netip.Prefix{netip.MustParsePrefix("10.0.0.0/14"), netip.MustParsePrefix("192.0.2.1/32")})
+	want := []zoneEntry{
+		{netip.MustParsePrefix("10.0.0.0/16"), "FR"},
+		{netip.MustParsePrefix("10.1.0.0/16"), "DE"},
+		{netip.MustParsePrefix("10.3.0.0/16"), "FR"},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("zoneEntries = %v, want %v", got, want)
+	}
+}